@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/profile"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func newApplyCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "apply [flags] profile.yaml",
+		Short: "Converge the system to the desired state of a profile.",
+		Long: `Converge the system to the desired state of a profile.
+
+Example of profile:
+
+  nvidia:
+    version: "550.107.02"
+    gbm_lib: true
+  vulkan:
+    icd:
+      disabled:
+        - nouveau_icd.x86_64.json
+    layers:
+      enabled:
+        - nvidia_layers.json
+  egl:
+    enabled:
+      - 10_nvidia_wayland.json
+  opencl:
+    disabled:
+      - rusticl.icd
+  environment:
+    target: envd
+    variables:
+      GBM_BACKEND: nvidia-drm
+  kernel_modules:
+    nvidia_drm:
+      modeset: "1"
+      fbdev: "1"`,
+		Args: cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			p, err := specs.ProfileFromFile(args[0])
+			if err != nil {
+				fmt.Println("Error on load profile:", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			err = profile.Apply(analyzer, p)
+			if err != nil {
+				fmt.Println("Error on apply profile:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(
+					analyzer.GetBackend().GetExecutor().GetPlan(), output)
+			} else {
+				err = executor.PrintReport(
+					analyzer.GetBackend().GetExecutor().GetPlan(), output)
+			}
+			if err != nil {
+				fmt.Println("Error on print operations:", err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
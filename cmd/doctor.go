@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/doctor"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "doctor [flags]",
+		Short: "Check the GPU setup for common issues.",
+		Long: `Check the GPU setup for common issues.
+
+Every check reports the severity, the message and the
+command suggested to fix the issue.
+
+Exit codes:
+
+  0: no issues found
+  1: warnings found
+  2: errors found
+  3: the command failed`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			checks, _ := cmd.Flags().GetStringSlice("check")
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(doctor.ExitFailure)
+			}
+
+			for _, c := range checks {
+				if doctor.GetCheck(c) == nil {
+					fmt.Println(fmt.Sprintf("Invalid check %s.", c))
+					os.Exit(doctor.ExitFailure)
+				}
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			checks, _ := cmd.Flags().GetStringSlice("check")
+			list, _ := cmd.Flags().GetBool("list")
+			procModules, _ := cmd.Flags().GetString("proc-modules")
+
+			if list {
+				for _, c := range doctor.GetChecks() {
+					fmt.Println(fmt.Sprintf("%-25s %s", c.Name, c.Description))
+				}
+				return
+			}
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(doctor.ExitFailure)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(doctor.ExitFailure)
+			}
+
+			report, err := doctor.Run(
+				doctor.NewContext(analyzer, procModules), checks)
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(doctor.ExitFailure)
+			}
+
+			err = report.Print(output)
+			if err != nil {
+				fmt.Println("Error on print report:", err.Error())
+				os.Exit(doctor.ExitFailure)
+			}
+
+			os.Exit(report.ExitCode())
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.StringSlice("check", []string{}, "Run only the selected checks.")
+	flags.Bool("list", false, "List the available checks.")
+	flags.String("proc-modules", doctor.DefaultProcModulesFile,
+		"Path of the file with the loaded kernel modules.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the report (terminal,yaml,json).")
+
+	return cmd
+}
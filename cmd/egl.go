@@ -7,9 +7,10 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
+	. "github.com/macaroni-os/gpu-configurator/cmd/egl"
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -41,17 +42,25 @@ func newEglCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			enableJsonLoader, _ := cmd.Flags().GetBool("enable-json-loader")
 			disableJsonLoader, _ := cmd.Flags().GetBool("disable-json-loader")
 			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
 
 			jsonLoader := args[0]
 
-			analyzer, err := analyzer.NewAnalyzer(
-				config.GetGeneral().GetBackendType(),
-			)
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
 			if err != nil {
 				fmt.Println("ERROR", err.Error())
 				os.Exit(1)
@@ -63,6 +72,12 @@ func newEglCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
 			eglfiles, jsonfile := analyzer.GetSystem().GetEglLoader(jsonLoader)
 			if jsonfile == nil {
 				if purge {
@@ -74,45 +89,31 @@ func newEglCommand(config *specs.Config) *cobra.Command {
 			}
 
 			if enableJsonLoader {
-				if !jsonfile.Disabled {
+				if !plan.EnableFile(eglfiles.Path, jsonfile.Name, jsonfile.Disabled) {
 					fmt.Println("Json loader file", jsonLoader, "already enabled.")
 					return
 				}
 
-				fileabs := filepath.Join(eglfiles.Path, jsonfile.Name)
-				fileabsDisabled := fileabs + ".disabled"
-				err := os.Rename(fileabsDisabled, fileabs)
-				if err != nil {
-					fmt.Println("Error on rename file:", err.Error())
-					os.Exit(1)
+			} else if disableJsonLoader {
+				if purge {
+					plan.PurgeFile(eglfiles.Path, jsonfile.Name, jsonfile.Disabled)
+				} else if !plan.DisableFile(eglfiles.Path, jsonfile.Name, jsonfile.Disabled) {
+					fmt.Println("Json loader file", jsonLoader, "already disabled.")
+					return
 				}
+			}
 
-			} else if disableJsonLoader {
-				fileabs := filepath.Join(eglfiles.Path, jsonfile.Name)
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
 
-				if purge {
-					if jsonfile.Disabled {
-						fileabs = fileabs + ".disabled"
-					}
-
-					err := os.Remove(fileabs)
-					if err != nil {
-						fmt.Println("Error on remove file:", err.Error())
-						os.Exit(1)
-					}
-				} else {
-
-					if jsonfile.Disabled {
-						fmt.Println("Json loader file", jsonLoader, "already disabled.")
-						return
-					}
-
-					fileabsDisabled := fileabs + ".disabled"
-					err := os.Rename(fileabs, fileabsDisabled)
-					if err != nil {
-						fmt.Println("Error on rename file:", err.Error())
-						os.Exit(1)
-					}
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
 				}
 			}
 		},
@@ -121,7 +122,14 @@ func newEglCommand(config *specs.Config) *cobra.Command {
 	var flags = cmd.Flags()
 	flags.Bool("enable-json-loader", false, "Enable EGL JSON loader.")
 	flags.Bool("disable-json-loader", false, "Disable EGL JSON loader.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the dry-run operations (terminal,yaml,json).")
 	flags.Bool("purge", false, "To use with --disable-json-loader to remove the JSON file.")
 
+	cmd.AddCommand(
+		NewEglVendorCommand(config),
+	)
+
 	return cmd
 }
@@ -0,0 +1,362 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package egl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+type eglVendorsList struct {
+	Vendors  []*specs.EglVendor `json:"vendors" yaml:"vendors"`
+	Disabled []string           `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
+
+func printEglVendors(list *eglVendorsList, output string) error {
+	switch output {
+	case "json":
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		if len(list.Vendors) == 0 {
+			fmt.Println("EGL vendors:\tNo vendors available.")
+		} else {
+			fmt.Println("EGL vendors (priority order):")
+			for idx, v := range list.Vendors {
+				fmt.Println(fmt.Sprintf("\t%d. %s %s (%s) [%s]",
+					idx+1, v.Name, v.File, v.LibraryPath, v.Source))
+			}
+		}
+		if len(list.Disabled) > 0 {
+			fmt.Println("Disabled vendors:")
+			for _, f := range list.Disabled {
+				fmt.Println("\t-", f)
+			}
+		}
+	}
+
+	return nil
+}
+
+func NewEglVendorCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "vendor [options] [vendor.json|vendor-name]",
+		Short: "Manage the glvnd EGL vendors.",
+		Long: `Manage the glvnd EGL vendors.
+
+Without options the vendors are listed in the order used by
+libglvnd to select the EGL vendor library. The order follows
+the __EGL_VENDOR_LIBRARY_FILENAMES and __EGL_VENDOR_LIBRARY_DIRS
+variables if defined. The vendor could be selected by file name
+or by the name without the priority prefix (ex. nvidia for
+10_nvidia.json).
+
+The disable status is managed with the rename of the selected file
+to the same file but with the suffix .disabled. The priority is
+the numeric prefix of the file, the vendors with a lower value
+are used first. The vendor with the new priority is copied under
+/etc/glvnd/egl_vendor.d, searched before /usr/share, together with
+the vendors that must be used before it. The files of the packages
+are not modified.
+
+$> gpu-configurator egl vendor --priority 60 nvidia`,
+		Args: cobra.MaximumNArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			enable, _ := cmd.Flags().GetBool("enable")
+			disable, _ := cmd.Flags().GetBool("disable")
+			purge, _ := cmd.Flags().GetBool("purge")
+			priority, _ := cmd.Flags().GetInt("priority")
+			withPriority := cmd.Flags().Changed("priority")
+
+			if enable && disable {
+				fmt.Println("Using both --disable and --enable not admitted.")
+				os.Exit(1)
+			}
+
+			if purge && !disable {
+				fmt.Println("--purge flag to use with --disable.")
+				os.Exit(1)
+			}
+
+			if withPriority && (enable || disable) {
+				fmt.Println("--priority flag not admitted with --enable or --disable.")
+				os.Exit(1)
+			}
+
+			if withPriority && (priority < 0 || priority > 99) {
+				fmt.Println(fmt.Sprintf("Invalid priority %d.", priority))
+				os.Exit(1)
+			}
+
+			if (enable || disable || withPriority) && len(args) == 0 {
+				fmt.Println("Missing vendor name")
+				os.Exit(1)
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			enable, _ := cmd.Flags().GetBool("enable")
+			disable, _ := cmd.Flags().GetBool("disable")
+			purge, _ := cmd.Flags().GetBool("purge")
+			priority, _ := cmd.Flags().GetInt("priority")
+			withPriority := cmd.Flags().Changed("priority")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			if !enable && !disable && !withPriority {
+				list := &eglVendorsList{
+					Vendors:  analyzer.GetEglVendors(os.Getenv),
+					Disabled: []string{},
+				}
+				for _, dir := range analyzer.GetSystem().EglVendorDirs {
+					for f, jsonfile := range dir.Files {
+						if jsonfile.Disabled && (len(args) == 0 ||
+							jsonfile.Name == args[0] ||
+							specs.GetEglVendorName(f) == args[0]) {
+							list.Disabled = append(list.Disabled,
+								filepath.Join(dir.Path, f))
+						}
+					}
+				}
+				sort.Strings(list.Disabled)
+				if len(args) > 0 {
+					vendors := []*specs.EglVendor{}
+					for _, v := range list.Vendors {
+						if v.Name == args[0] || filepath.Base(v.File) == args[0] {
+							vendors = append(vendors, v)
+						}
+					}
+					list.Vendors = vendors
+				}
+
+				err = printEglVendors(list, output)
+				if err != nil {
+					fmt.Println("Error on print vendors:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			vendor := args[0]
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
+			if withPriority {
+				manifest, err := analyzer.GetBackend().GetExecutor().GetManifest()
+				if err != nil {
+					fmt.Println("Error on read state:", err.Error())
+					os.Exit(1)
+				}
+
+				err = planEglVendorPriority(analyzer, manifest, plan, vendor, priority)
+				if err != nil {
+					fmt.Println("Error on set priority:", err.Error())
+					os.Exit(1)
+				}
+			} else {
+				matches := 0
+				for _, dir := range analyzer.GetSystem().EglVendorDirs {
+					for _, jsonfile := range dir.GetVendorFiles(vendor) {
+						matches++
+
+						if enable {
+							plan.EnableFile(dir.Path, jsonfile.Name, jsonfile.Disabled)
+						} else if purge {
+							plan.PurgeFile(dir.Path, jsonfile.Name, jsonfile.Disabled)
+						} else {
+							plan.DisableFile(dir.Path, jsonfile.Name, jsonfile.Disabled)
+						}
+					}
+				}
+
+				if matches == 0 {
+					if purge {
+						// POST: ignore error if the file is not present
+						return
+					}
+					fmt.Println("No EGL vendor with name", vendor, "found.")
+					os.Exit(1)
+				}
+			}
+
+			if len(plan.Operations) == 0 {
+				if withPriority {
+					fmt.Println("EGL vendor", vendor, "already with priority",
+						fmt.Sprintf("%02d.", priority))
+				} else if enable {
+					fmt.Println("EGL vendor", vendor, "already enabled.")
+				} else {
+					fmt.Println("EGL vendor", vendor, "already disabled.")
+				}
+				return
+			}
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if withPriority &&
+				plan.CountByStatus()[specs.StatusUnchanged] == len(plan.Operations) {
+				fmt.Println("EGL vendor", vendor, "already with priority",
+					fmt.Sprintf("%02d.", priority))
+				return
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.Bool("enable", false, "Enable the EGL vendor.")
+	flags.Bool("disable", false, "Disable the EGL vendor.")
+	flags.Bool("purge", false, "To use with --disable to remove the vendor file.")
+	flags.Int("priority", 50,
+		"Set the priority of the EGL vendor (0-99). A lower value has a higher priority.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
+
+// Add the operations to copy the vendor with the new priority under
+// the configuration directory. libglvnd searches the directories in
+// order, so the vendors of the other directories with a lower priority
+// are copied too. The vendor library loaded twice is skipped by libglvnd.
+// The previous copy of the vendor managed by gpu-configurator is renamed.
+func planEglVendorPriority(a *analyzer.Analyzer, manifest *specs.StateManifest,
+	plan *specs.OperationsPlan, vendor string, priority int) error {
+
+	vendorName := specs.GetEglVendorName(vendor)
+	name := fmt.Sprintf("%02d_%s.json", priority, vendorName)
+	target := filepath.Join(analyzer.EglVendorConfigDir, name)
+
+	var configDir *specs.EglExternalPlatformFiles
+	source := ""
+	for _, dir := range a.GetSystem().EglVendorDirs {
+		if dir.Path == analyzer.EglVendorConfigDir {
+			configDir = dir
+			continue
+		}
+		for _, jsonfile := range dir.GetVendorFiles(vendorName) {
+			if !jsonfile.Disabled && source == "" {
+				source = filepath.Join(dir.Path, jsonfile.Name)
+			}
+		}
+	}
+
+	copies := []string{}
+	if configDir == nil {
+		plan.Mkdir(analyzer.EglVendorConfigDir)
+	} else {
+		if _, present := configDir.Files[name]; present &&
+			manifest.GetArtifact(target) == nil {
+			return fmt.Errorf("the file %s already exists", target)
+		}
+
+		for _, jsonfile := range configDir.GetVendorFiles(vendorName) {
+			p := filepath.Join(configDir.Path, jsonfile.Name)
+			if manifest.GetArtifact(p) == nil {
+				return fmt.Errorf("the file %s is not managed by gpu-configurator", p)
+			}
+			if jsonfile.Disabled {
+				return fmt.Errorf("the vendor %s is disabled", vendor)
+			}
+			copies = append(copies, p)
+		}
+	}
+
+	if source == "" && len(copies) == 0 {
+		return fmt.Errorf("no EGL vendor with name %s found", vendor)
+	}
+
+	for _, p := range copies {
+		if p == target && source == "" {
+			source = target
+		}
+	}
+	for _, p := range copies {
+		if p == target {
+			continue
+		}
+		if source == "" {
+			// POST: the file of the package is been removed.
+			plan.Rename(p, target)
+			source = target
+		} else {
+			plan.Remove(p)
+		}
+	}
+	if source != "" && source != target {
+		plan.CopyFile(source, target)
+	}
+
+	// The vendors used before the new priority.
+	for _, v := range a.GetEglVendors(func(string) string { return "" }) {
+		f := filepath.Base(v.File)
+		if v.Name == vendorName || f >= name ||
+			filepath.Dir(v.File) == analyzer.EglVendorConfigDir {
+			continue
+		}
+		if configDir != nil {
+			if _, present := configDir.Files[f]; present {
+				continue
+			}
+		}
+		plan.CopyFile(v.File, filepath.Join(analyzer.EglVendorConfigDir, f))
+	}
+
+	return nil
+}
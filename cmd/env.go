@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/environment"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func newEnvCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "env [flags]",
+		Short: "Generate the session environment for Wayland compositors.",
+		Long: `Generate the session environment for Wayland compositors.
+
+The variables are computed for the GPU that drives the outputs
+and could be printed for eval or written to:
+
+  envd:         /etc/env.d/08gpu-configurator (env-update)
+  environmentd: /etc/environment.d/20-gpu-configurator.conf
+  user:         ~/.config/environment.d/gpu-configurator.conf
+
+The DRM devices order (WLR_DRM_DEVICES, KWIN_DRM_DEVICES) uses
+the card numbering that could change on the next boot and it's
+only printed.
+
+$> eval $(gpu-configurator env)`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			target, _ := cmd.Flags().GetString("target")
+			output, _ := cmd.Flags().GetString("output")
+
+			if !environment.ValidTarget(target) {
+				fmt.Println(fmt.Sprintf("Invalid target %s.", target))
+				os.Exit(1)
+			}
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			target, _ := cmd.Flags().GetString("target")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			env, err := environment.GetSessionEnvironment(
+				analyzer.GetSystem().Topology, analyzer.GetSystem().Video)
+			if err != nil {
+				fmt.Println("Error on prepare environment:", err.Error())
+				os.Exit(1)
+			}
+
+			if target == environment.TargetPrint {
+				fmt.Print(env.Shell())
+				return
+			}
+
+			homeDir, _ := os.UserHomeDir()
+			file, err := environment.GetTargetFile(target,
+				analyzer.GetBackend().GetEnvironmentDir(), homeDir)
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			plan := specs.NewOperationsPlan()
+			if !utils.Exists(rootfs.RealPath(
+				config.GetGeneral().GetRootDir(), filepath.Dir(file))) {
+				plan.Mkdir(filepath.Dir(file))
+			}
+			plan.WriteFile(file, env.File(), 0644)
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+			} else {
+				err = executor.PrintReport(plan, output)
+			}
+			if err != nil {
+				fmt.Println("Error on print operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if output == "terminal" {
+				for _, v := range env.GetRuntimeVariables() {
+					fmt.Println(fmt.Sprintf(
+						"%s not written: the card numbering could change on the next boot.",
+						v.Name))
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.String("target", environment.TargetPrint,
+		"Where to put the variables (print,envd,environmentd,user).")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
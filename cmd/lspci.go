@@ -5,10 +5,12 @@ See AUTHORS and LICENSE for the license details and contributors.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer/pci"
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -32,8 +34,29 @@ func newLsPciCommand(config *specs.Config) *cobra.Command {
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			output, _ := cmd.Flags().GetString("output")
+			useLspci, _ := cmd.Flags().GetBool("use-lspci")
+			aliasFile, _ := cmd.Flags().GetString("modules-alias")
+			pciIdsPath, _ := cmd.Flags().GetString("pciids")
+			if pciIdsPath == "" {
+				pciIdsPath = config.GetGeneral().GetPciIdsPath()
+			}
+
+			var lspci *pci.SystemDevices
+			var err error
 
-			lspci, err := pci.GetDevices()
+			if useLspci {
+				lspci, err = pci.GetDevicesFromLspci()
+			} else {
+				lspci, err = pci.GetDevices(config.GetGeneral().GetSysfsDir(),
+					aliasFile, pciIdsPath)
+				var scanErr *specs.ScanError
+				if errors.As(err, &scanErr) {
+					// POST: the devices are printed without names.
+					logger.GetDefaultLogger().Debug(
+						"Error on load pci.ids:", scanErr.Err.Error())
+					err = nil
+				}
+			}
 			if err != nil {
 				fmt.Println("Error on read pci data:", err.Error())
 				os.Exit(1)
@@ -65,6 +88,11 @@ func newLsPciCommand(config *specs.Config) *cobra.Command {
 	var flags = cmd.Flags()
 	flags.StringP("output", "o", "yaml",
 		"Modify output format (terminal,yaml,json).")
+	flags.Bool("use-lspci", false, "Read PCI devices from lspci command instead of sysfs.")
+	flags.String("modules-alias", "",
+		"Path of the modules.alias file used to resolve kernel modules (default of the running kernel).")
+	flags.String("pciids", "",
+		"Path of the pci.ids file used to resolve the devices names.")
 
 	return cmd
 }
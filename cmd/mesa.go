@@ -0,0 +1,26 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	. "github.com/macaroni-os/gpu-configurator/cmd/mesa"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func newMesaCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "mesa",
+		Short: "Mesa setup commands.",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		NewMesaDrircCommand(config),
+	)
+
+	return cmd
+}
@@ -0,0 +1,298 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package mesa
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+type drircList struct {
+	Files   []*specs.DrircFile   `json:"files" yaml:"files"`
+	Options []*specs.DrircOption `json:"options" yaml:"options"`
+}
+
+func printDrirc(list *drircList, output string) error {
+	switch output {
+	case "json":
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		if len(list.Files) == 0 {
+			fmt.Println("Mesa drirc files:\tNo files available.")
+		} else {
+			fmt.Println("Mesa drirc files:")
+			for _, f := range list.Files {
+				tags := []string{fmt.Sprintf("%d options", len(f.Options))}
+				if f.Default {
+					tags = append([]string{"default"}, tags...)
+				}
+				fmt.Println("\t-", f.Path, "("+strings.Join(tags, ", ")+")")
+			}
+		}
+
+		if len(list.Options) == 0 {
+			fmt.Println("Mesa drirc options:\tNo options available.")
+		} else {
+			fmt.Println("Mesa drirc options:")
+			for _, o := range list.Options {
+				fmt.Println(fmt.Sprintf("\t- %s=%s [%s] (%s)",
+					o.Name, o.Value, o.DrircScope.String(), o.File))
+			}
+		}
+	}
+
+	return nil
+}
+
+func NewMesaDrircCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "drirc [options]",
+		Short: "Manage the Mesa drirc options.",
+		Long: `Manage the Mesa drirc options.
+
+Without options the effective options of /etc/drirc and of the
+drirc file of the user (~/.drirc) are listed. With --all the
+defaults of the distribution (/usr/share/drirc.d) are listed too.
+
+The options are defined in an application or in an engine of
+a device. Without --driver, --kernel-driver and --device the
+options are used with all the devices. Without --application,
+--executable and --engine the options are defined in the
+application "all" used with all the applications.
+
+The content of the file not managed (comments, other
+sections and attributes) is preserved.
+
+$> gpu-configurator mesa drirc --driver radeonsi --set vblank_mode=0
+$> gpu-configurator mesa drirc --executable game.exe --set force_glsl_extensions_warn=true
+$> gpu-configurator mesa drirc --target user --executable game.exe --unset vblank_mode`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			set, _ := cmd.Flags().GetStringArray("set")
+			unset, _ := cmd.Flags().GetStringArray("unset")
+			application, _ := cmd.Flags().GetString("application")
+			executable, _ := cmd.Flags().GetString("executable")
+			engine, _ := cmd.Flags().GetString("engine")
+			target, _ := cmd.Flags().GetString("target")
+			all, _ := cmd.Flags().GetBool("all")
+
+			for _, o := range set {
+				if idx := strings.Index(o, "="); idx <= 0 {
+					fmt.Println(fmt.Sprintf("Invalid option %s. Use name=value.", o))
+					os.Exit(1)
+				}
+			}
+
+			for _, o := range unset {
+				if o == "" || strings.Contains(o, "=") {
+					fmt.Println(fmt.Sprintf("Invalid option name %s.", o))
+					os.Exit(1)
+				}
+			}
+
+			if engine != "" && (application != "" || executable != "") {
+				fmt.Println(
+					"Using --engine with --application or --executable not admitted.")
+				os.Exit(1)
+			}
+
+			if all && (len(set) > 0 || len(unset) > 0) {
+				fmt.Println("--all flag not admitted with --set or --unset.")
+				os.Exit(1)
+			}
+
+			if !specs.ValidDrircTarget(target) {
+				fmt.Println(fmt.Sprintf("Invalid target %s.", target))
+				os.Exit(1)
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			set, _ := cmd.Flags().GetStringArray("set")
+			unset, _ := cmd.Flags().GetStringArray("unset")
+			driver, _ := cmd.Flags().GetString("driver")
+			kernelDriver, _ := cmd.Flags().GetString("kernel-driver")
+			device, _ := cmd.Flags().GetString("device")
+			application, _ := cmd.Flags().GetString("application")
+			executable, _ := cmd.Flags().GetString("executable")
+			engine, _ := cmd.Flags().GetString("engine")
+			target, _ := cmd.Flags().GetString("target")
+			all, _ := cmd.Flags().GetBool("all")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			homeDir, _ := os.UserHomeDir()
+			userFile := ""
+			if homeDir != "" {
+				userFile = filepath.Join(homeDir, specs.DrircUserFile)
+			}
+
+			if len(set) == 0 && len(unset) == 0 {
+				if userFile != "" {
+					analyzer.AddDrircFile(userFile, false)
+				}
+
+				drirc := analyzer.GetSystem().Drirc
+				list := &drircList{
+					Files:   drirc.Files,
+					Options: drirc.GetEffectiveOptions(all),
+				}
+				if !all {
+					files := []*specs.DrircFile{}
+					for _, f := range drirc.Files {
+						if !f.Default {
+							files = append(files, f)
+						}
+					}
+					list.Files = files
+				}
+
+				err = printDrirc(list, output)
+				if err != nil {
+					fmt.Println("Error on print drirc options:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			file := analyzer.GetBackend().GetDrircFile()
+			if target == specs.DrircTargetUser {
+				if userFile == "" {
+					fmt.Println("ERROR home directory not available")
+					os.Exit(1)
+				}
+				file = userFile
+			}
+
+			scope := &specs.DrircScope{
+				Device:      make(map[string]string),
+				Application: make(map[string]string),
+				Engine:      make(map[string]string),
+			}
+			for attr, value := range map[string]string{
+				"driver":        driver,
+				"kernel_driver": kernelDriver,
+				"device":        device,
+			} {
+				if value != "" {
+					scope.Device[attr] = value
+				}
+			}
+			if engine != "" {
+				scope.Engine["engine_name_match"] = engine
+			} else {
+				if application == "" {
+					application = "all"
+					if executable != "" {
+						application = executable
+					}
+				}
+				scope.Application["name"] = application
+				if executable != "" {
+					scope.Application["executable"] = executable
+				}
+			}
+
+			options := []*specs.DrircOption{}
+			for _, o := range set {
+				idx := strings.Index(o, "=")
+				options = append(options, &specs.DrircOption{
+					Name:  o[:idx],
+					Value: o[idx+1:],
+				})
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
+			changed, err := analyzer.PrepareDrirc(plan, file, scope, options, unset)
+			if err != nil {
+				fmt.Println("Error on prepare drirc:", err.Error())
+				os.Exit(1)
+			}
+
+			if !changed {
+				fmt.Println("Mesa drirc file", file, "already updated.")
+				return
+			}
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+			} else {
+				err = executor.PrintReport(plan, output)
+			}
+			if err != nil {
+				fmt.Println("Error on print operations:", err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.StringArray("set", []string{}, "Set the option with the format name=value.")
+	flags.StringArray("unset", []string{}, "Remove the option with the name.")
+	flags.String("driver", "", "Define the options for the Mesa driver (ex. radeonsi, iris).")
+	flags.String("kernel-driver", "", "Define the options for the kernel driver (ex. amdgpu, i915).")
+	flags.String("device", "", "Define the options for the PCI device id (ex. 0x73bf).")
+	flags.String("application", "", "Name of the application section.")
+	flags.String("executable", "", "Define the options for the executable.")
+	flags.String("engine", "", "Define the options for the engine (engine_name_match).")
+	flags.String("target", specs.DrircTargetSystem,
+		"Where to write the options (system,user).")
+	flags.Bool("all", false, "List also the defaults of the distribution.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -20,16 +22,133 @@ func NewConfigureCommand(config *specs.Config) *cobra.Command {
 		Short:   "Configure a specific version of NVIDIA driver.",
 		Aliases: []string{"c", "conf", "set"},
 		PreRun: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+
 			if len(args) == 0 {
 				fmt.Println(
 					"Missing nvidia driver version argument.")
 				os.Exit(1)
 			}
+			if len(args) > 1 {
+				fmt.Println(
+					"Only one nvidia driver version is admitted.")
+				os.Exit(1)
+			}
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+			version := args[0]
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
 
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			nvidiaSetup := analyzer.GetSystem().Nvidia
+			if !nvidiaSetup.HasVersion(version) {
+				fmt.Println("NVIDIA driver version", version, "not available.")
+				if len(nvidiaSetup.Drivers) > 0 {
+					fmt.Println("Available versions:")
+					for idx := range nvidiaSetup.Drivers {
+						fmt.Println("\t-", nvidiaSetup.Drivers[idx].Version)
+					}
+				} else {
+					fmt.Println("Check if the package with NVIDIA drivers is installed.")
+				}
+				os.Exit(1)
+			}
+
+			// The purge of the active version and the setup of the
+			// new version are reverted together on failure.
+			err = analyzer.GetBackend().GetExecutor().Begin()
+			if err != nil {
+				fmt.Println("Error on start transaction:", err.Error())
+				os.Exit(1)
+			}
+
+			// POST: the setup of the active version is reconciled
+			//       without purge.
+			if nvidiaSetup.VersionActive != "" && nvidiaSetup.VersionActive != version {
+				if !dryRun {
+					fmt.Println(fmt.Sprintf(
+						"Purging active NVIDIA driver %s...",
+						nvidiaSetup.VersionActive))
+				}
+
+				err = analyzer.GetBackend().PurgeNVIDIADriver(nvidiaSetup,
+					specs.NewNVIDIAPurgeOpts())
+				if err != nil {
+					fmt.Println("Error on purge active NVIDIA driver:", err.Error())
+					os.Exit(1)
+				}
+			}
+
+			if !dryRun {
+				fmt.Println(fmt.Sprintf("Configuring NVIDIA driver %s...", version))
+			}
+			err = analyzer.GetBackend().SetNVIDIAVersion(nvidiaSetup, version)
+			if err != nil {
+				fmt.Println("Error on configure NVIDIA driver:", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.GetBackend().GetExecutor().Commit()
+			if err != nil {
+				fmt.Println("WARNING: error on commit operations:", err.Error())
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(
+					analyzer.GetBackend().GetExecutor().GetPlan(), output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			err = executor.PrintReport(
+				analyzer.GetBackend().GetExecutor().GetPlan(), output)
+			if err != nil {
+				fmt.Println("Error on print report:", err.Error())
+				os.Exit(1)
+			}
+
+			nvidiaSetup.SetVersion(version)
+			if !nvidiaSetup.GetDriver(version).WithKernelModules {
+				fmt.Println(fmt.Sprintf(
+					"WARNING: no NVIDIA %s kernel module found for the running kernel.",
+					version))
+			}
+
+			fmt.Println("Operation done. Run env-update and ldconfig to refresh the environment.")
 		},
 	}
 
+	var flags = cmd.Flags()
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
 	return cmd
 }
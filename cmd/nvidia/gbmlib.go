@@ -7,11 +7,10 @@ package nvidia
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
-	"github.com/macaroni-os/macaronictl/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -24,6 +23,7 @@ func NewGbmLibCommand(config *specs.Config) *cobra.Command {
 			enableDriver, _ := cmd.Flags().GetBool("enable-driver")
 			disableDriver, _ := cmd.Flags().GetBool("disable-driver")
 			purge, _ := cmd.Flags().GetBool("purge")
+			output, _ := cmd.Flags().GetString("output")
 
 			if enableDriver && disableDriver {
 				fmt.Println(
@@ -37,15 +37,22 @@ func NewGbmLibCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			enableDriver, _ := cmd.Flags().GetBool("enable-driver")
 			disableDriver, _ := cmd.Flags().GetBool("disable-driver")
 			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
 
-			analyzer, err := analyzer.NewAnalyzer(
-				config.GetGeneral().GetBackendType(),
-			)
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
 			if err != nil {
 				fmt.Println("ERROR", err.Error())
 				os.Exit(1)
@@ -57,14 +64,12 @@ func NewGbmLibCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			libName := "nvidia-drm_gbm.so"
-			// Some applications search for nvidia_gbm.so (for
-			// example the electron applications).
-			libNameShort := "nvidia_gbm.so"
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
 
-			nvidiagbmlib := analyzer.GetSystem().GetGBMLibrary(
-				libName,
-			)
 			if analyzer.GetSystem().Nvidia == nil ||
 				analyzer.GetSystem().Nvidia.VersionActive == "" {
 				fmt.Println("No NVIDIA version active available.")
@@ -72,133 +77,39 @@ func NewGbmLibCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			if enableDriver {
-				if nvidiagbmlib == nil {
-					// POST: The library link is not present.
-
-					nvidiaDriver := analyzer.GetSystem().Nvidia.GetDriver(
-						analyzer.GetSystem().Nvidia.VersionActive,
-					)
-					if nvidiaDriver == nil {
-						fmt.Println("Unexpected error on retrieve nvidia driver data.")
-						os.Exit(1)
-					}
-
-					linkedFile := filepath.Join(
-						nvidiaDriver.Path, "lib64", libName,
-					)
-					linkFile := filepath.Join(
-						analyzer.GetBackend().GetGBMLibDir(),
-						libName,
-					)
-
-					err := os.Symlink(linkedFile, linkFile)
-					if err != nil {
-						fmt.Println(fmt.Sprintf(
-							"error on create symlink on %s: %s",
-							linkFile, err.Error()))
-						os.Exit(1)
-					}
-
-					linkShortFile := filepath.Join(
-						analyzer.GetBackend().GetGBMLibDir(),
-						libNameShort,
-					)
-					// Create the short lib name link
-					err = os.Symlink(linkedFile, linkShortFile)
-					if err != nil {
-						fmt.Println(fmt.Sprintf(
-							"error on create symlink on %s: %s",
-							linkShortFile, err.Error()))
-						os.Exit(1)
-					}
-
-				} else {
-
-					if !nvidiagbmlib.Disabled {
-						fmt.Println("Library", libName, "already active.")
-						fmt.Println("Nothing to do.")
-						return
-					}
-
-					libpath := filepath.Join(
-						analyzer.GetBackend().GetGBMLibDir(),
-						nvidiagbmlib.Name,
-					)
-					libpathDisabled := libpath + ".disabled"
-
-					err := os.Rename(libpathDisabled, libpath)
-					if err != nil {
-						fmt.Println("Error on rename link:", err.Error())
-						os.Exit(1)
-					}
-
+			if enableDriver || disableDriver {
+				changed, err := analyzer.PrepareNVIDIAGbmLib(plan, enableDriver, purge)
+				if err != nil {
+					fmt.Println("Error on prepare operations:", err.Error())
+					os.Exit(1)
 				}
 
-				fmt.Println("Operation done.")
-
-			} else if disableDriver {
-
-				libpath := filepath.Join(
-					analyzer.GetBackend().GetGBMLibDir(),
-					nvidiagbmlib.Name,
-				)
-				libpathDisabled := libpath + ".disabled"
-
-				if purge && nvidiagbmlib != nil {
-					if nvidiagbmlib.Disabled {
-						err = os.Remove(libpathDisabled)
+				if !changed {
+					if enableDriver {
+						fmt.Println("Library", specs.NVIDIAGbmLibName, "already active.")
 					} else {
-						err = os.Remove(libpath)
-					}
-
-					if err != nil {
-						fmt.Println("Error on remove link:", err.Error())
-						os.Exit(1)
+						fmt.Println("Library", specs.NVIDIAGbmLibName, "not present or already disable.")
 					}
-
-					nvidiagbmlibShort := filepath.Join(
-						analyzer.GetBackend().GetGBMLibDir(),
-						libNameShort,
-					)
-					if utils.Exists(nvidiagbmlibShort) {
-						err := os.Remove(nvidiagbmlibShort)
-						if err != nil {
-							fmt.Println(fmt.Sprintf("Error on remove file %s: %s",
-								nvidiagbmlibShort, err.Error()))
-							os.Exit(1)
-						}
-					}
-
-				} else if nvidiagbmlib == nil || nvidiagbmlib.Disabled {
-					fmt.Println("Library", libName, "not present or already disable.")
 					fmt.Println("Nothing to do.")
 					return
-				} else {
+				}
+			}
 
-					err := os.Rename(libpath, libpathDisabled)
-					if err != nil {
-						fmt.Println("Error on rename link:", err.Error())
-						os.Exit(1)
-					}
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
 
-					nvidiagbmlibShort := filepath.Join(
-						analyzer.GetBackend().GetGBMLibDir(),
-						libNameShort,
-					)
-					if utils.Exists(nvidiagbmlibShort) {
-						err := os.Remove(nvidiagbmlibShort)
-						if err != nil {
-							fmt.Println(fmt.Sprintf("Error on remove file %s: %s",
-								nvidiagbmlibShort, err.Error()))
-						}
-						os.Exit(1)
-					}
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
 				}
-
+			} else if enableDriver || disableDriver {
 				fmt.Println("Operation done.")
 			}
-
 		},
 	}
 
@@ -206,6 +117,9 @@ func NewGbmLibCommand(config *specs.Config) *cobra.Command {
 	flags.Bool("enable-driver", false, "Enable NVIDIA GBM library.")
 	flags.Bool("disable-driver", false, "Disable NVIDIA GBM library.")
 	flags.Bool("purge", false, "To use with --disable-driver to remove the link library.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the dry-run operations (terminal,yaml,json).")
 
 	return cmd
 }
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package nvidia
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func NewUnconfigureCommand(config *specs.Config) *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:     "unconfigure",
+		Short:   "Remove the configuration of the active NVIDIA driver.",
+		Aliases: []string{"u", "unset", "purge"},
+		Args:    cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			keepConfd, _ := cmd.Flags().GetBool("keep-confd")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			nvidiaSetup := analyzer.GetSystem().Nvidia
+			if dryRun {
+				// POST: nothing to print before the plan.
+			} else if nvidiaSetup.VersionActive == "" {
+				fmt.Println("No active NVIDIA driver found. Cleaning leftover files...")
+			} else {
+				fmt.Println(fmt.Sprintf(
+					"Purging active NVIDIA driver %s...",
+					nvidiaSetup.VersionActive))
+			}
+
+			opts := specs.NewNVIDIAPurgeOpts()
+			opts.KeepConfd = keepConfd
+
+			err = analyzer.GetBackend().PurgeNVIDIADriver(nvidiaSetup, opts)
+			if err != nil {
+				fmt.Println("Error on purge NVIDIA driver:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(
+					analyzer.GetBackend().GetExecutor().GetPlan(), output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			err = executor.PrintReport(
+				analyzer.GetBackend().GetExecutor().GetPlan(), output)
+			if err != nil {
+				fmt.Println("Error on print report:", err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Println("Operation done. Run env-update and ldconfig to refresh the environment.")
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.Bool("keep-confd", true,
+		"Maintain /etc/conf.d files. Use --keep-confd=false to remove them.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
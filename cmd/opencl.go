@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func newOpenCLCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "opencl [options] vendor.icd|vendor-name",
+		Short: "Enable/Disable OpenCL ICD vendors.",
+		Long: `Enable/Disable OpenCL ICD vendors.
+
+The vendors are the .icd files under /etc/OpenCL/vendors used
+by the OpenCL ICD loader to find the platforms (ex. nvidia.icd,
+rusticl.icd, mesa.icd, amdocl64.icd). The vendor could be selected
+by file name or by the name without the .icd extension.
+
+The disable status is managed with the rename of the selected file
+to the same file but with the suffix .disabled.`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			enableIcdFile, _ := cmd.Flags().GetBool("enable-icd-file")
+			disableIcdFile, _ := cmd.Flags().GetBool("disable-icd-file")
+			purge, _ := cmd.Flags().GetBool("purge")
+
+			if enableIcdFile && disableIcdFile {
+				fmt.Println(
+					"Using both --disable-icd-file and --enable-icd-file not admitted.")
+				os.Exit(1)
+			}
+
+			if purge && !disableIcdFile {
+				fmt.Println(
+					"--purge flag to use with --disable-icd-file.")
+				os.Exit(1)
+			}
+
+			if len(args) == 0 {
+				fmt.Println("Missing icd filename")
+				os.Exit(1)
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			enableIcdFile, _ := cmd.Flags().GetBool("enable-icd-file")
+			disableIcdFile, _ := cmd.Flags().GetBool("disable-icd-file")
+			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			icdfile := args[0]
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
+			vendors := []*specs.OpenCLVendorFile{}
+			vendorsDir := analyzer.GetSystem().OpenCLVendors
+			if vendorsDir != nil {
+				vendors = vendorsDir.GetFiles(icdfile)
+			}
+
+			if len(vendors) == 0 {
+				if purge {
+					// POST: ignore error if the file is not present
+					return
+				}
+				fmt.Println("No OpenCL icd file with name", icdfile, "found.")
+				os.Exit(1)
+			}
+
+			for _, vendor := range vendors {
+				if enableIcdFile {
+					plan.EnableFile(vendorsDir.Path, vendor.Name, vendor.Disabled)
+				} else if disableIcdFile {
+					if purge {
+						plan.PurgeFile(vendorsDir.Path, vendor.Name, vendor.Disabled)
+					} else {
+						plan.DisableFile(vendorsDir.Path, vendor.Name, vendor.Disabled)
+					}
+				}
+			}
+
+			if len(plan.Operations) == 0 {
+				if enableIcdFile {
+					fmt.Println("OpenCL icd file", icdfile, "already enabled.")
+				} else if disableIcdFile {
+					fmt.Println("OpenCL icd file", icdfile, "already disabled.")
+				}
+				return
+			}
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.Bool("enable-icd-file", false, "Enable OpenCL ICD file.")
+	flags.Bool("disable-icd-file", false, "Disable OpenCL ICD file.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the dry-run operations (terminal,yaml,json).")
+	flags.Bool("purge", false, "To use with --disable-icd-file to remove the ICD file.")
+
+	return cmd
+}
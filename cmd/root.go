@@ -9,6 +9,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
 	specs "github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -48,9 +49,15 @@ func initCommand(rootCmd *cobra.Command, config *specs.Config) {
 	pflags.StringP("config", "c", "", "Gpu Configurator configfile")
 	pflags.BoolP("debug", "d", config.Viper.GetBool("general.debug"),
 		"Enable debug output.")
+	pflags.String("root", config.Viper.GetString("general.root"),
+		"Alternate root directory of the system to configure.")
+	pflags.String("sysfs-dir", config.Viper.GetString("general.sysfs_dir"),
+		"Read the GPU topology from an alternative sysfs directory (default /sys).")
 
 	config.Viper.BindPFlag("config", pflags.Lookup("config"))
 	config.Viper.BindPFlag("general.debug", pflags.Lookup("debug"))
+	config.Viper.BindPFlag("general.root", pflags.Lookup("root"))
+	config.Viper.BindPFlag("general.sysfs_dir", pflags.Lookup("sysfs-dir"))
 
 	rootCmd.AddCommand(
 		newConfigCommand(config),
@@ -59,6 +66,14 @@ func initCommand(rootCmd *cobra.Command, config *specs.Config) {
 		newNvidiaCommand(config),
 		newEglCommand(config),
 		newVulkanCommand(config),
+		newOpenCLCommand(config),
+		newMesaCommand(config),
+		newVideoCommand(config),
+		newRunCommand(config),
+		newXorgCommand(config),
+		newEnvCommand(config),
+		newApplyCommand(config),
+		newDoctorCommand(config),
 	)
 }
 
@@ -93,6 +108,10 @@ func Execute() {
 			if err != nil {
 				panic(err)
 			}
+
+			// Initialize logger
+			log := logger.NewLogger(config)
+			log.SetAsDefault()
 		},
 	}
 
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/prime"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func newRunCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "run [--gpu <id>] -- command [args]",
+		Short: "Run a program with a specific GPU (PRIME render offload).",
+		Long: `Run a program with a specific GPU (PRIME render offload).
+
+The GPU could be selected by index, PCI bus id or vendor:
+
+$> gpu-configurator run --gpu nvidia -- glxinfo -B
+$> gpu-configurator run --gpu 01:00.0 -- vkcube
+
+Without --gpu the first discrete GPU that doesn't drive
+the outputs is used.`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			printEnv, _ := cmd.Flags().GetBool("print-env")
+			installWrapper, _ := cmd.Flags().GetBool("install-wrapper")
+			output, _ := cmd.Flags().GetString("output")
+
+			if len(args) == 0 && !printEnv && !installWrapper {
+				fmt.Println("Missing command to execute.")
+				os.Exit(1)
+			}
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			gpuSelector, _ := cmd.Flags().GetString("gpu")
+			printEnv, _ := cmd.Flags().GetBool("print-env")
+			installWrapper, _ := cmd.Flags().GetBool("install-wrapper")
+			wrapperPath, _ := cmd.Flags().GetString("wrapper-path")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+
+			gpu, err := prime.SelectGPU(analyzer.GetSystem().Topology, gpuSelector)
+			if err != nil {
+				fmt.Println("Error on select GPU:", err.Error())
+				os.Exit(1)
+			}
+
+			env, err := prime.GetEnvironment(gpu,
+				analyzer.GetSystem().Video)
+			if err != nil {
+				fmt.Println("Error on prepare environment:", err.Error())
+				os.Exit(1)
+			}
+
+			if printEnv {
+				for _, e := range prime.EnvironmentToList(env) {
+					fmt.Println("export " + e)
+				}
+				return
+			}
+
+			if installWrapper {
+				analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+					config.GetGeneral().GetRootDir(),
+					config.GetGeneral().GetStateDir(), dryRun,
+				))
+
+				plan := specs.NewOperationsPlan()
+				if !utils.Exists(rootfs.RealPath(
+					config.GetGeneral().GetRootDir(), filepath.Dir(wrapperPath))) {
+					plan.Mkdir(filepath.Dir(wrapperPath))
+				}
+				plan.WriteFile(wrapperPath, prime.GetWrapperScript(gpu, env), 0755)
+
+				err = analyzer.GetBackend().GetExecutor().Apply(plan)
+				if err != nil {
+					fmt.Println("Error on apply operations:", err.Error())
+					os.Exit(1)
+				}
+
+				if dryRun {
+					err = executor.PrintPlan(plan, output)
+				} else {
+					err = executor.PrintReport(plan, output)
+				}
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			binary, err := exec.LookPath(args[0])
+			if err != nil {
+				fmt.Println("Error on resolve command", args[0], err.Error())
+				os.Exit(1)
+			}
+
+			err = syscall.Exec(binary, args,
+				prime.GetCommandEnvironment(os.Environ(), env))
+			if err != nil {
+				fmt.Println("Error on exec command", args[0], err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.String("gpu", "", "Select the GPU by index, bus id or vendor.")
+	flags.Bool("print-env", false, "Print the environment variables in shell format.")
+	flags.Bool("install-wrapper", false,
+		"Install a prime-run wrapper script for the selected GPU.")
+	flags.String("wrapper-path", "/usr/local/bin/prime-run",
+		"Path of the wrapper script.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
@@ -7,9 +7,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
-	"github.com/macaroni-os/gpu-configurator/pkg/analyzer/pci"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -22,24 +24,47 @@ func printSummary(s *specs.System) error {
 		hostname = "N/A"
 	}
 
-	lspci, err := pci.GetDevices()
-	if err != nil {
-		return err
-	}
-
-	vgaDevices := lspci.GetVGADevices()
-
 	fmt.Println(fmt.Sprintf(
 		`Copyright (c) 2024 - Macaroni OS - gpu-configurator - %s`,
 		specs.GPUCONF_VERSION,
 	))
 	fmt.Println("---------------------------------------------------------------------")
 	fmt.Println(fmt.Sprintf("Hostname:\t\t\t\t\t%s", hostname))
-	fmt.Println(fmt.Sprintf("GPUs:\t\t\t\t\t\t%d", len(*vgaDevices)))
-	for _, gpu := range *vgaDevices {
-		fmt.Println("\t-", fmt.Sprintf("%s [%s]", gpu.Name, gpu.Id))
-		if gpu.KernelDriverInUse != "" {
-			fmt.Println("\t\tkernel driver in use:", gpu.KernelDriverInUse)
+	if s.Topology == nil {
+		fmt.Println("GPUs:\t\t\t\t\t\tN/A")
+	} else {
+		fmt.Println(fmt.Sprintf("GPUs:\t\t\t\t\t\t%d", len(s.Topology.GPUs)))
+		for _, gpu := range s.Topology.GPUs {
+			tags := []string{gpu.Vendor, gpu.Role}
+			if gpu.BootVGA {
+				tags = append(tags, "boot vga")
+			}
+			name := gpu.Name
+			if name == "" {
+				name = "Unknown device"
+			}
+			fmt.Println("\t-", fmt.Sprintf("%s [%s] (%s)", name, gpu.Id,
+				strings.Join(tags, ", ")))
+			if gpu.KernelDriver != "" {
+				fmt.Println("\t\tkernel driver in use:", gpu.KernelDriver)
+			}
+			if gpu.Card != "" {
+				fmt.Println("\t\tDRM devices:", strings.TrimSuffix(
+					gpu.Card+", "+gpu.RenderNode, ", "))
+			}
+			if len(gpu.Outputs) > 0 {
+				outputs := []string{}
+				for _, o := range gpu.Outputs {
+					outputs = append(outputs, fmt.Sprintf("%s (%s)", o.Name, o.Status))
+				}
+				fmt.Println("\t\toutputs:", strings.Join(outputs, ", "))
+			}
+		}
+
+		if s.Topology.Hybrid {
+			fmt.Println(fmt.Sprintf(
+				"Hybrid graphics:\t\t\t\tyes (display GPU: %s, suggested mode: %s)",
+				s.Topology.DisplayGPU, s.Topology.SuggestedMode))
 		}
 	}
 	fmt.Println("")
@@ -58,6 +83,36 @@ func printSummary(s *specs.System) error {
 
 	fmt.Println("")
 
+	if len(s.EglVendorDirs) == 0 {
+		fmt.Println("EGL Vendors Directories:\tNo directories available.")
+	} else {
+		// POST: libglvnd uses the vendors in the order of
+		//       the directories and of the file names.
+		fmt.Println("EGL Vendors Directories (priority order):")
+		priority := 1
+		for idx := range s.EglVendorDirs {
+			fmt.Println("\t-", s.EglVendorDirs[idx].Path)
+			files := []string{}
+			for file := range s.EglVendorDirs[idx].Files {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+
+			for _, file := range files {
+				f := s.EglVendorDirs[idx].Files[file]
+				if f.Disabled {
+					fmt.Println("\t\t*", file, "(disabled)")
+					continue
+				}
+				fmt.Println(fmt.Sprintf("\t\t%d. %s (%s)",
+					priority, file, f.File.ICD.LibraryPath))
+				priority++
+			}
+		}
+	}
+
+	fmt.Println("")
+
 	if len(s.VulkanLayersDirs) == 0 {
 		fmt.Println("Vulkan Layers Configs Directories:\tNo directories available.")
 	} else {
@@ -70,6 +125,31 @@ func printSummary(s *specs.System) error {
 				} else {
 					fmt.Println("\t\t*", file)
 				}
+				if f.Manifest == nil {
+					continue
+				}
+				for _, l := range f.Manifest.GetLayers() {
+					if l.IsMetaLayer() {
+						fmt.Println(fmt.Sprintf("\t\t\t- %s %s (meta layer: %s)",
+							l.Name, l.ApiVersion, strings.Join(l.ComponentLayers, ", ")))
+					} else {
+						fmt.Println(fmt.Sprintf("\t\t\t- %s %s (implementation %s)",
+							l.Name, l.ApiVersion, l.ImplementationVersion))
+					}
+				}
+			}
+		}
+	}
+
+	if s.VulkanLoaderSettings != nil {
+		fmt.Println("Vulkan Loader Settings:", s.VulkanLoaderSettings.Path)
+		if e := s.VulkanLoaderSettings.Settings.GetGlobalSettings(false); e != nil {
+			for _, l := range e.Layers {
+				if l.Control == specs.VulkanLayerControlUnordered {
+					fmt.Println("\t- <unordered layers>")
+				} else {
+					fmt.Println(fmt.Sprintf("\t- %s (%s)", l.Name, l.Control))
+				}
 			}
 		}
 	}
@@ -83,8 +163,18 @@ func printSummary(s *specs.System) error {
 		for idx := range s.VulkanICDDirs {
 			fmt.Println("\t-", s.VulkanICDDirs[idx].Path)
 			for file, f := range s.VulkanICDDirs[idx].Files {
+				tags := []string{}
+				if f.Arch != "" {
+					tags = append(tags, f.Arch+"bit")
+				}
+				if f.File != nil && f.File.ICD.IsPortabilityDriver {
+					tags = append(tags, "portability")
+				}
 				if f.Disabled {
-					fmt.Println("\t\t*", file, "(disabled)")
+					tags = append(tags, "disabled")
+				}
+				if len(tags) > 0 {
+					fmt.Println("\t\t*", file, "("+strings.Join(tags, ", ")+")")
 				} else {
 					fmt.Println("\t\t*", file)
 				}
@@ -94,6 +184,77 @@ func printSummary(s *specs.System) error {
 
 	fmt.Println("")
 
+	if s.OpenCLVendors == nil {
+		fmt.Println("OpenCL Vendors Directory:\tNo directory available.")
+	} else {
+		fmt.Println("OpenCL Vendors Directory:")
+		fmt.Println("\t-", s.OpenCLVendors.Path)
+		for file, f := range s.OpenCLVendors.Files {
+			tags := []string{}
+			if f.LibraryPath != "" {
+				tags = append(tags, f.LibraryPath)
+				if !f.LibraryAvailable {
+					tags = append(tags, "library not found")
+				}
+			}
+			if f.Disabled {
+				tags = append(tags, "disabled")
+			}
+			if len(tags) > 0 {
+				fmt.Println("\t\t*", file, "("+strings.Join(tags, ", ")+")")
+			} else {
+				fmt.Println("\t\t*", file)
+			}
+		}
+	}
+
+	fmt.Println("")
+
+	if s.Video == nil || len(s.Video.VADrivers) == 0 {
+		fmt.Println("VA-API Drivers:\tNo drivers available.")
+	} else {
+		fmt.Println("VA-API Drivers:")
+		for _, d := range s.Video.VADrivers {
+			fmt.Println("\t-", d.Name, "("+d.Path+")")
+		}
+	}
+
+	if s.Video == nil || len(s.Video.VDPAUDrivers) == 0 {
+		fmt.Println("VDPAU Drivers:\tNo drivers available.")
+	} else {
+		fmt.Println("VDPAU Drivers:")
+		for _, d := range s.Video.VDPAUDrivers {
+			fmt.Println("\t-", d.Name, "("+d.Path+")")
+		}
+	}
+
+	fmt.Println("")
+
+	if s.Drirc == nil || len(s.Drirc.Files) == 0 {
+		fmt.Println("Mesa drirc Files:\tNo files available.")
+	} else {
+		fmt.Println("Mesa drirc Files:")
+		for _, f := range s.Drirc.Files {
+			if f.Default {
+				fmt.Println("\t-", f.Path, fmt.Sprintf("(default, %d options)", len(f.Options)))
+			} else {
+				fmt.Println("\t-", f.Path, fmt.Sprintf("(%d options)", len(f.Options)))
+			}
+		}
+
+		// POST: the defaults of the distribution are not displayed.
+		options := s.Drirc.GetEffectiveOptions(false)
+		if len(options) > 0 {
+			fmt.Println("Mesa drirc Options:")
+			for _, o := range options {
+				fmt.Println(fmt.Sprintf("\t- %s=%s [%s] (%s)",
+					o.Name, o.Value, o.DrircScope.String(), o.File))
+			}
+		}
+	}
+
+	fmt.Println("")
+
 	if len(s.GbmLibraries) == 0 {
 		fmt.Println("GBM Backend Libraries:\tNo libraries available.")
 	} else {
@@ -133,6 +294,14 @@ func printSummary(s *specs.System) error {
 		}
 	}
 
+	if len(s.Diagnostics) > 0 {
+		fmt.Println("")
+		fmt.Println("Diagnostics:")
+		for _, d := range s.Diagnostics {
+			fmt.Println(fmt.Sprintf("\t- [%s] %s: %s", d.Kind, d.Path, d.Error))
+		}
+	}
+
 	return nil
 }
 
@@ -155,9 +324,7 @@ func newShowCommand(config *specs.Config) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			output, _ := cmd.Flags().GetString("output")
 
-			analyzer, err := analyzer.NewAnalyzer(
-				config.GetGeneral().GetBackendType(),
-			)
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
 			if err != nil {
 				fmt.Println("ERROR", err.Error())
 				os.Exit(1)
@@ -169,6 +336,10 @@ func newShowCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
+			if homeDir, _ := os.UserHomeDir(); homeDir != "" {
+				analyzer.AddDrircFile(filepath.Join(homeDir, specs.DrircUserFile), false)
+			}
+
 			if output == "terminal" {
 				err := printSummary(analyzer.GetSystem())
 				if err != nil {
@@ -0,0 +1,302 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/environment"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/prime"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/video"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+type videoGPU struct {
+	BusId        string         `json:"bus_id" yaml:"bus_id"`
+	Name         string         `json:"name,omitempty" yaml:"name,omitempty"`
+	KernelDriver string         `json:"kernel_driver,omitempty" yaml:"kernel_driver,omitempty"`
+	Drivers      *video.Drivers `json:"drivers" yaml:"drivers"`
+}
+
+type videoList struct {
+	specs.VideoSetup `yaml:",inline"`
+	GPUs             []*videoGPU `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+}
+
+func printVideoDrivers(list *videoList, output string) error {
+	switch output {
+	case "json":
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(list)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		for _, t := range []struct {
+			Title   string
+			Drivers []*specs.VideoDriver
+		}{
+			{"VA-API drivers", list.VADrivers},
+			{"VDPAU drivers", list.VDPAUDrivers},
+		} {
+			if len(t.Drivers) == 0 {
+				fmt.Println(t.Title + ":\tNo drivers available.")
+				continue
+			}
+			fmt.Println(t.Title + ":")
+			for _, d := range t.Drivers {
+				fmt.Println(fmt.Sprintf("\t- %s (%s)", d.Name, d.Path))
+			}
+		}
+
+		if len(list.GPUs) > 0 {
+			fmt.Println("Suggested drivers:")
+			for _, gpu := range list.GPUs {
+				va, vdpau := gpu.Drivers.VADriver, gpu.Drivers.VDPAUDriver
+				if va == "" {
+					va = "-"
+				}
+				if vdpau == "" {
+					vdpau = "-"
+				}
+				fmt.Println(fmt.Sprintf("\t%s %s [%s]: VA-API %s, VDPAU %s",
+					gpu.BusId, gpu.Name, gpu.KernelDriver, va, vdpau))
+			}
+		}
+	}
+
+	return nil
+}
+
+func newVideoCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "video [flags]",
+		Short: "Configure the VA-API and VDPAU drivers.",
+		Long: `Configure the VA-API and VDPAU drivers.
+
+The drivers installed (*_drv_video.so and libvdpau_*.so) are
+mapped to the kernel driver of the selected GPU to set the
+LIBVA_DRIVER_NAME and VDPAU_DRIVER variables. With the NVIDIA
+driver nvidia-vaapi-driver is used with the NVD_BACKEND variable.
+
+Without --gpu the GPU that drives the outputs is used. The
+variables could be printed for eval or written to:
+
+  envd:         /etc/env.d/08gpu-configurator_video (env-update)
+  environmentd: /etc/environment.d/20-gpu-configurator_video.conf
+  user:         ~/.config/environment.d/gpu-configurator_video.conf
+
+The files are read after the files of the env command.
+
+$> gpu-configurator video --list
+$> gpu-configurator video --target envd --va-driver i965`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			target, _ := cmd.Flags().GetString("target")
+			nvdBackend, _ := cmd.Flags().GetString("nvd-backend")
+			output, _ := cmd.Flags().GetString("output")
+
+			if !environment.ValidTarget(target) {
+				fmt.Println(fmt.Sprintf("Invalid target %s.", target))
+				os.Exit(1)
+			}
+
+			if nvdBackend != "" && !video.ValidNvdBackend(nvdBackend) {
+				fmt.Println(fmt.Sprintf("Invalid NVD backend %s.", nvdBackend))
+				os.Exit(1)
+			}
+
+			vaDriver, _ := cmd.Flags().GetString("va-driver")
+			if nvdBackend != "" && vaDriver != "" && vaDriver != video.NvidiaVADriver {
+				fmt.Println(fmt.Sprintf(
+					"--nvd-backend flag not admitted with the VA-API driver %s.",
+					vaDriver))
+				os.Exit(1)
+			}
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			list, _ := cmd.Flags().GetBool("list")
+			gpuSelector, _ := cmd.Flags().GetString("gpu")
+			vaDriver, _ := cmd.Flags().GetString("va-driver")
+			vdpauDriver, _ := cmd.Flags().GetString("vdpau-driver")
+			nvdBackend, _ := cmd.Flags().GetString("nvd-backend")
+			target, _ := cmd.Flags().GetString("target")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+			setup := analyzer.GetSystem().Video
+			// POST: with --list the drivers are listed also without GPUs.
+			topology := analyzer.GetSystem().Topology
+
+			if list {
+				l := &videoList{VideoSetup: *setup, GPUs: []*videoGPU{}}
+				if topology != nil {
+					for _, gpu := range topology.GPUs {
+						l.GPUs = append(l.GPUs, &videoGPU{
+							BusId:        gpu.BusId,
+							Name:         gpu.Name,
+							KernelDriver: gpu.KernelDriver,
+							Drivers:      video.GetDrivers(gpu.KernelDriver, setup),
+						})
+					}
+				}
+
+				err = printVideoDrivers(l, output)
+				if err != nil {
+					fmt.Println("Error on print video drivers:", err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			var gpu *specs.GPUNode
+			if gpuSelector != "" {
+				gpu, err = prime.SelectGPU(topology, gpuSelector)
+			} else if topology != nil && len(topology.GPUs) > 0 {
+				gpu = topology.GetGPU(topology.DisplayGPU)
+				if gpu == nil {
+					gpu = topology.GPUs[0]
+				}
+			} else {
+				err = fmt.Errorf("no GPUs available")
+			}
+			if err != nil {
+				fmt.Println("Error on select GPU:", err.Error())
+				os.Exit(1)
+			}
+
+			drivers := video.GetDrivers(gpu.KernelDriver, setup)
+
+			if vaDriver != "" {
+				if setup.GetVADriver(vaDriver) == nil {
+					fmt.Println(fmt.Sprintf("VA-API driver %s not installed.", vaDriver))
+					os.Exit(1)
+				}
+				drivers.VADriver = vaDriver
+			}
+			if vdpauDriver != "" {
+				if setup.GetVDPAUDriver(vdpauDriver) == nil {
+					fmt.Println(fmt.Sprintf("VDPAU driver %s not installed.", vdpauDriver))
+					os.Exit(1)
+				}
+				drivers.VDPAUDriver = vdpauDriver
+			}
+			if drivers.VADriver == video.NvidiaVADriver {
+				if nvdBackend != "" {
+					drivers.NvdBackend = nvdBackend
+				} else if drivers.NvdBackend == "" {
+					drivers.NvdBackend = video.NvdBackendDirect
+				}
+			} else if nvdBackend != "" {
+				// POST: the suggested driver of the GPU isn't nvidia.
+				fmt.Println(fmt.Sprintf(
+					"--nvd-backend flag not admitted with the VA-API driver %s of the GPU %s.",
+					drivers.VADriver, gpu.BusId))
+				os.Exit(1)
+			}
+
+			if drivers.VADriver == "" && drivers.VDPAUDriver == "" {
+				fmt.Println(fmt.Sprintf(
+					"No video drivers installed for the GPU %s (%s).",
+					gpu.BusId, gpu.KernelDriver))
+				os.Exit(1)
+			}
+
+			env := environment.NewEnvironment()
+			env.SetVideoDrivers(drivers)
+
+			if target == environment.TargetPrint {
+				fmt.Print(env.Shell())
+				return
+			}
+
+			homeDir, _ := os.UserHomeDir()
+			file, err := environment.GetVideoTargetFile(target,
+				analyzer.GetBackend().GetEnvironmentDir(), homeDir)
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			plan := specs.NewOperationsPlan()
+			if !utils.Exists(rootfs.RealPath(
+				config.GetGeneral().GetRootDir(), filepath.Dir(file))) {
+				plan.Mkdir(filepath.Dir(file))
+			}
+			plan.WriteFile(file, env.File(), 0644)
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+			} else {
+				err = executor.PrintReport(plan, output)
+			}
+			if err != nil {
+				fmt.Println("Error on print operations:", err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.Bool("list", false,
+		"List the drivers installed and the drivers suggested for every GPU.")
+	flags.String("gpu", "", "Select the GPU by index, bus id or vendor.")
+	flags.String("va-driver", "", "Use the VA-API driver instead of the suggested driver.")
+	flags.String("vdpau-driver", "", "Use the VDPAU driver instead of the suggested driver.")
+	flags.String("nvd-backend", "",
+		"Backend of nvidia-vaapi-driver (direct,egl). Only with the nvidia VA-API driver. Default direct.")
+	flags.String("target", environment.TargetPrint,
+		"Where to put the variables (print,envd,environmentd,user).")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
@@ -21,6 +21,7 @@ func newVulkanCommand(config *specs.Config) *cobra.Command {
 	cmd.AddCommand(
 		NewVulkanIcdCommand(config),
 		NewVulkanLayerCommand(config),
+		NewVulkanResolveCommand(config),
 	)
 
 	return cmd
@@ -7,9 +7,9 @@ package vulkan
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/spf13/cobra"
@@ -17,7 +17,7 @@ import (
 
 func NewVulkanIcdCommand(config *specs.Config) *cobra.Command {
 	var cmd = &cobra.Command{
-		Use:   "icd [options] icd.json",
+		Use:   "icd [options] icd.json|icd-name",
 		Short: "Enable/Disable Vulcan ICD JSON configurations.",
 		PreRun: func(cmd *cobra.Command, args []string) {
 			enableIcdFile, _ := cmd.Flags().GetBool("enable-icd-file")
@@ -41,17 +41,34 @@ func NewVulkanIcdCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
+			arch, _ := cmd.Flags().GetString("arch")
+			switch arch {
+			case "", specs.IcdArch32, specs.IcdArch64:
+			default:
+				fmt.Println(fmt.Sprintf("Invalid value %s for arch.", arch))
+				os.Exit(1)
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			enableIcdFile, _ := cmd.Flags().GetBool("enable-icd-file")
 			disableIcdFile, _ := cmd.Flags().GetBool("disable-icd-file")
 			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+			arch, _ := cmd.Flags().GetString("arch")
 
 			icdfile := args[0]
 
-			analyzer, err := analyzer.NewAnalyzer(
-				config.GetGeneral().GetBackendType(),
-			)
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
 			if err != nil {
 				fmt.Println("ERROR", err.Error())
 				os.Exit(1)
@@ -63,8 +80,32 @@ func NewVulkanIcdCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			icdfiles, jsonfile := analyzer.GetSystem().GetVulkanIcdFile(icdfile)
-			if jsonfile == nil {
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
+			// POST: the file is selected by name or by base name
+			//       and architecture (ex. radeon_icd --arch 32).
+			matches := 0
+			for _, icdfiles := range analyzer.GetSystem().VulkanICDDirs {
+				for _, jsonfile := range icdfiles.GetFiles(icdfile, arch) {
+					matches++
+
+					if enableIcdFile {
+						plan.EnableFile(icdfiles.Path, jsonfile.Name, jsonfile.Disabled)
+					} else if disableIcdFile {
+						if purge {
+							plan.PurgeFile(icdfiles.Path, jsonfile.Name, jsonfile.Disabled)
+						} else {
+							plan.DisableFile(icdfiles.Path, jsonfile.Name, jsonfile.Disabled)
+						}
+					}
+				}
+			}
+
+			if matches == 0 {
 				if purge {
 					// POST: ignore error if the file is not present
 					return
@@ -73,47 +114,27 @@ func NewVulkanIcdCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			if enableIcdFile {
-				if !jsonfile.Disabled {
+			if len(plan.Operations) == 0 {
+				if enableIcdFile {
 					fmt.Println("Json icd file", icdfile, "already enabled.")
-					return
+				} else if disableIcdFile {
+					fmt.Println("Json icd file", icdfile, "already disabled.")
 				}
+				return
+			}
 
-				fileabs := filepath.Join(icdfiles.Path, jsonfile.Name)
-				fileabsDisabled := fileabs + ".disabled"
-				err := os.Rename(fileabsDisabled, fileabs)
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
 				if err != nil {
-					fmt.Println("Error on rename file:", err.Error())
+					fmt.Println("Error on print operations:", err.Error())
 					os.Exit(1)
 				}
-
-			} else if disableIcdFile {
-				fileabs := filepath.Join(icdfiles.Path, jsonfile.Name)
-
-				if purge {
-					if jsonfile.Disabled {
-						fileabs = fileabs + ".disabled"
-					}
-
-					err := os.Remove(fileabs)
-					if err != nil {
-						fmt.Println("Error on remove file:", err.Error())
-						os.Exit(1)
-					}
-				} else {
-
-					if jsonfile.Disabled {
-						fmt.Println("Json loader file", icdfile, "already disabled.")
-						return
-					}
-
-					fileabsDisabled := fileabs + ".disabled"
-					err := os.Rename(fileabs, fileabsDisabled)
-					if err != nil {
-						fmt.Println("Error on rename file:", err.Error())
-						os.Exit(1)
-					}
-				}
 			}
 		},
 	}
@@ -121,7 +142,12 @@ func NewVulkanIcdCommand(config *specs.Config) *cobra.Command {
 	var flags = cmd.Flags()
 	flags.Bool("enable-icd-file", false, "Enable ICD JSON file.")
 	flags.Bool("disable-icd-file", false, "Disable ICD JSON file.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the dry-run operations (terminal,yaml,json).")
 	flags.Bool("purge", false, "To use with --disable-icd-file to remove the ICD file.")
+	flags.String("arch", "",
+		"Select the ICD files of the architecture (32,64). Default all.")
 
 	return cmd
 }
@@ -7,18 +7,33 @@ package vulkan
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/vulkan"
 
 	"github.com/spf13/cobra"
 )
 
 func NewVulkanLayerCommand(config *specs.Config) *cobra.Command {
 	var cmd = &cobra.Command{
-		Use:   "layers [options] layers.json",
+		Use:   "layers [options] layers.json|layer-name",
 		Short: "Enable/Disable Vulcan Layers JSON configurations.",
+		Long: `Enable/Disable Vulcan Layers JSON configurations.
+
+By default the layers are disabled with the rename of the manifest.
+With --loader-settings the layers are forced on/off and ordered through
+the loader settings file (Vulkan loader >= 1.3.284) without touch the
+files owned by the packages:
+
+  system: /etc/vulkan/loader_settings.d/vk_loader_settings.json
+  user:   ~/.local/share/vulkan/loader_settings.d/vk_loader_settings.json
+
+The per-user file, if present, replaces the system file.
+
+$> gpu-configurator vulkan layers --loader-settings --disable-layers-file VK_LAYER_MESA_overlay
+$> gpu-configurator vulkan layers --loader-settings --order VK_LAYER_KHRONOS_validation,VK_LAYER_MESA_overlay`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			enableLayersFile, _ := cmd.Flags().GetBool("enable-layers-file")
 			disableLayersFile, _ := cmd.Flags().GetBool("disable-layers-file")
@@ -36,22 +51,70 @@ func NewVulkanLayerCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			if len(args) == 0 {
+			loaderSettings, _ := cmd.Flags().GetBool("loader-settings")
+			reset, _ := cmd.Flags().GetBool("reset")
+			order, _ := cmd.Flags().GetStringSlice("order")
+			target, _ := cmd.Flags().GetString("target")
+
+			if loaderSettings {
+				if purge {
+					fmt.Println("--purge flag not admitted with --loader-settings.")
+					os.Exit(1)
+				}
+
+				if reset && (enableLayersFile || disableLayersFile) {
+					fmt.Println(
+						"--reset flag not admitted with --enable-layers-file or --disable-layers-file.")
+					os.Exit(1)
+				}
+
+				if !vulkan.ValidSettingsTarget(target) {
+					fmt.Println(fmt.Sprintf("Invalid target %s.", target))
+					os.Exit(1)
+				}
+
+				if !enableLayersFile && !disableLayersFile && !reset && len(order) == 0 {
+					fmt.Println("No operation defined.")
+					os.Exit(1)
+				}
+
+			} else if reset || len(order) > 0 || cmd.Flags().Changed("target") {
+				fmt.Println(
+					"--reset, --order and --target flags to use with --loader-settings.")
+				os.Exit(1)
+			}
+
+			if len(args) == 0 && (!loaderSettings || enableLayersFile || disableLayersFile || reset) {
 				fmt.Println("Missing json filename")
 				os.Exit(1)
 			}
 
+			output, _ := cmd.Flags().GetString("output")
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			enableLayersFile, _ := cmd.Flags().GetBool("enable-layers-file")
 			disableLayersFile, _ := cmd.Flags().GetBool("disable-layers-file")
 			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+			loaderSettings, _ := cmd.Flags().GetBool("loader-settings")
+			reset, _ := cmd.Flags().GetBool("reset")
+			order, _ := cmd.Flags().GetStringSlice("order")
+			target, _ := cmd.Flags().GetString("target")
+
+			jfile := ""
+			if len(args) > 0 {
+				jfile = args[0]
+			}
 
-			jfile := args[0]
-
-			analyzer, err := analyzer.NewAnalyzer(
-				config.GetGeneral().GetBackendType(),
-			)
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
 			if err != nil {
 				fmt.Println("ERROR", err.Error())
 				os.Exit(1)
@@ -63,56 +126,85 @@ func NewVulkanLayerCommand(config *specs.Config) *cobra.Command {
 				os.Exit(1)
 			}
 
-			vulkanldir, jsonfile := analyzer.GetSystem().GetVulkanLayerFile(jfile)
-			if jsonfile == nil {
-				if purge {
-					// POST: ignore error if the file is not present
-					return
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+			plan := specs.NewOperationsPlan()
+
+			if loaderSettings {
+				// POST: the layers are forced on/off through the
+				//       loader settings without touch the manifests
+				//       owned by the packages.
+				file := analyzer.GetBackend().GetVulkanLoaderSettingsFile()
+				if target == vulkan.SettingsTargetUser {
+					file, err = vulkan.GetUserLoaderSettingsFile(vulkan.GetEnv(os.Environ()))
+					if err != nil {
+						fmt.Println("ERROR", err.Error())
+						os.Exit(1)
+					}
 				}
-				fmt.Println("No json icd file with name", jfile, "found.")
-				os.Exit(1)
-			}
 
-			if enableLayersFile {
-				if !jsonfile.Disabled {
-					fmt.Println("Json layer file", jfile, "already enabled.")
-					return
+				control := ""
+				if enableLayersFile {
+					control = specs.VulkanLayerControlOn
+				} else if disableLayersFile {
+					control = specs.VulkanLayerControlOff
+				} else if !reset {
+					// POST: only the order of the layers is changed.
+					jfile = ""
 				}
 
-				fileabs := filepath.Join(vulkanldir.Path, jsonfile.Name)
-				fileabsDisabled := fileabs + ".disabled"
-				err := os.Rename(fileabsDisabled, fileabs)
+				changed, err := analyzer.PrepareVulkanLoaderSettings(plan,
+					file, jfile, control, order)
 				if err != nil {
-					fmt.Println("Error on rename file:", err.Error())
+					fmt.Println("Error on prepare loader settings:", err.Error())
 					os.Exit(1)
 				}
 
-			} else if disableLayersFile {
-				fileabs := filepath.Join(vulkanldir.Path, jsonfile.Name)
+				if !changed {
+					fmt.Println("Vulkan loader settings", file, "already up to date.")
+					return
+				}
 
-				if purge {
-					if jsonfile.Disabled {
-						fileabs = fileabs + ".disabled"
+			} else {
+				vulkanldir, jsonfile := analyzer.GetSystem().GetVulkanLayerFile(jfile)
+				if jsonfile == nil {
+					if purge {
+						// POST: ignore error if the file is not present
+						return
 					}
+					fmt.Println("No json icd file with name", jfile, "found.")
+					os.Exit(1)
+				}
 
-					err := os.Remove(fileabs)
-					if err != nil {
-						fmt.Println("Error on remove file:", err.Error())
-						os.Exit(1)
+				if enableLayersFile {
+					if !plan.EnableFile(vulkanldir.Path, jsonfile.Name, jsonfile.Disabled) {
+						fmt.Println("Json layer file", jfile, "already enabled.")
+						return
 					}
-				} else {
 
-					if jsonfile.Disabled {
+				} else if disableLayersFile {
+					if purge {
+						plan.PurgeFile(vulkanldir.Path, jsonfile.Name, jsonfile.Disabled)
+					} else if !plan.DisableFile(vulkanldir.Path, jsonfile.Name, jsonfile.Disabled) {
 						fmt.Println("Json layer file", jfile, "already disabled.")
 						return
 					}
+				}
+			}
 
-					fileabsDisabled := fileabs + ".disabled"
-					err := os.Rename(fileabs, fileabsDisabled)
-					if err != nil {
-						fmt.Println("Error on rename file:", err.Error())
-						os.Exit(1)
-					}
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+				if err != nil {
+					fmt.Println("Error on print operations:", err.Error())
+					os.Exit(1)
 				}
 			}
 		},
@@ -121,7 +213,18 @@ func NewVulkanLayerCommand(config *specs.Config) *cobra.Command {
 	var flags = cmd.Flags()
 	flags.Bool("enable-layers-file", false, "Enable Vulkan Layers JSON file.")
 	flags.Bool("disable-layers-file", false, "Disable Vulkan Layers JSON file.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the dry-run operations (terminal,yaml,json).")
 	flags.Bool("purge", false, "To use with --disable-layers-file to remove the file.")
+	flags.Bool("loader-settings", false,
+		"Force the layers on/off with the Vulkan loader settings instead of rename the files.")
+	flags.String("target", vulkan.SettingsTargetSystem,
+		"The loader settings to modify (system,user).")
+	flags.Bool("reset", false,
+		"To use with --loader-settings to remove the layer from the loader settings.")
+	flags.StringSlice("order", []string{},
+		"To use with --loader-settings to define the order of the layers (comma separated).")
 
 	return cmd
 }
@@ -0,0 +1,146 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package vulkan
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/vulkan"
+
+	"github.com/spf13/cobra"
+)
+
+func printResolution(res *vulkan.Resolution) {
+	fmt.Println("ICD search paths:")
+	for _, d := range res.IcdDirs {
+		fmt.Println("\t-", d)
+	}
+	fmt.Println("Implicit layers search paths:")
+	for _, d := range res.ImplicitLayerDirs {
+		fmt.Println("\t-", d)
+	}
+	fmt.Println("Explicit layers search paths:")
+	for _, d := range res.ExplicitLayerDirs {
+		fmt.Println("\t-", d)
+	}
+	if res.LoaderSettings != "" {
+		if res.LoaderSettingsError != "" {
+			fmt.Println("Loader settings:", res.LoaderSettings,
+				"(ignored: "+res.LoaderSettingsError+")")
+		} else {
+			fmt.Println("Loader settings:", res.LoaderSettings)
+		}
+	}
+	fmt.Println("")
+
+	fmt.Println(fmt.Sprintf("ICDs (%d loaded):", len(res.GetEnabledIcds())))
+	for _, icd := range res.Icds {
+		if icd.Enabled {
+			fmt.Println(fmt.Sprintf("\t* %s (%s) [%s]",
+				icd.Path, icd.LibraryPath, icd.Source))
+		} else {
+			fmt.Println(fmt.Sprintf("\t- %s [%s]: %s",
+				icd.Path, icd.Source, icd.Reason))
+		}
+	}
+	fmt.Println("")
+
+	fmt.Println(fmt.Sprintf("Layers (%d enabled):", len(res.GetEnabledLayers())))
+	for _, l := range res.Layers {
+		name := l.Name
+		if name == "" {
+			name = "N/A"
+		}
+		if l.Enabled {
+			fmt.Println(fmt.Sprintf("\t* %s (%s) %s [%s]",
+				name, l.Type, l.Path, l.Source))
+		} else {
+			fmt.Println(fmt.Sprintf("\t- %s (%s) %s [%s]: %s",
+				name, l.Type, l.Path, l.Source, l.Reason))
+		}
+	}
+}
+
+func NewVulkanResolveCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "resolve [flags]",
+		Short: "Show the ICD and layers loaded by the Vulkan loader.",
+		Long: `Show the ICD and layers loaded by the Vulkan loader.
+
+The discovery of the loader is simulated with the variables of the
+current environment or with the variables defined with --env:
+
+$> gpu-configurator vulkan resolve --clean-env \
+     --env VK_DRIVER_FILES=/usr/share/vulkan/icd.d/nvidia_icd.json`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			envs, _ := cmd.Flags().GetStringArray("env")
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+
+			for _, e := range envs {
+				if !strings.Contains(e, "=") {
+					fmt.Println(fmt.Sprintf("Invalid variable %s. Use KEY=VALUE.", e))
+					os.Exit(1)
+				}
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			envs, _ := cmd.Flags().GetStringArray("env")
+			cleanEnv, _ := cmd.Flags().GetBool("clean-env")
+
+			environ := []string{}
+			if !cleanEnv {
+				environ = os.Environ()
+			}
+			env := vulkan.GetEnv(environ)
+			for _, e := range envs {
+				words := strings.SplitN(e, "=", 2)
+				env[words[0]] = words[1]
+			}
+
+			res := vulkan.NewResolver(
+				config.GetGeneral().GetRootDir(), env).Resolve()
+
+			var err error
+			var data []byte
+			switch output {
+			case "json":
+				data, err = res.Json()
+			case "yaml":
+				data, err = res.Yaml()
+			default:
+				printResolution(res)
+				return
+			}
+			if err != nil {
+				fmt.Println("Error on convert resolution:", err.Error())
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.StringArray("env", []string{},
+		"Define a variable of the environment to simulate (KEY=VALUE).")
+	flags.Bool("clean-env", false,
+		"Ignore the variables of the current environment.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format (terminal,yaml,json).")
+
+	return cmd
+}
@@ -0,0 +1,26 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	. "github.com/macaroni-os/gpu-configurator/cmd/xorg"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/spf13/cobra"
+)
+
+func newXorgCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "xorg",
+		Short: "Xorg setup commands.",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		NewXorgGenerateCommand(config),
+	)
+
+	return cmd
+}
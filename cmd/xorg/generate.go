@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package xorg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/prime"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/xorg"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func selectGPU(topology *specs.GPUTopology, selector, role string) (*specs.GPUNode, error) {
+	if selector != "" {
+		return prime.SelectGPU(topology, selector)
+	}
+
+	gpus := topology.GetGPUsByRole(role)
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("no %s GPU found", role)
+	}
+	return gpus[0], nil
+}
+
+func NewXorgGenerateCommand(config *specs.Config) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "generate [flags]",
+		Short: "Generate Xorg configuration for hybrid graphics.",
+		Long: `Generate Xorg configuration for hybrid graphics.
+
+Available modes:
+
+  nvidia-primary: the NVIDIA GPU renders and drives the outputs.
+  offload:        the integrated GPU drives the outputs and the
+                  discrete GPU is used for render offload.
+  reverse-prime:  like offload, but the outputs of the discrete GPU
+                  are available to the integrated GPU.`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			mode, _ := cmd.Flags().GetString("mode")
+			output, _ := cmd.Flags().GetString("output")
+
+			if mode != "" && !xorg.ValidMode(mode) {
+				fmt.Println(fmt.Sprintf("Invalid mode %s.", mode))
+				os.Exit(1)
+			}
+
+			if !executor.ValidOutput(output) {
+				fmt.Println(fmt.Sprintf("Invalid value %s for output.",
+					output,
+				))
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			mode, _ := cmd.Flags().GetString("mode")
+			igpuSelector, _ := cmd.Flags().GetString("igpu")
+			dgpuSelector, _ := cmd.Flags().GetString("dgpu")
+			write, _ := cmd.Flags().GetBool("write")
+			file, _ := cmd.Flags().GetString("file")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			output, _ := cmd.Flags().GetString("output")
+
+			analyzer, err := analyzer.NewAnalyzer(config.GetGeneral())
+			if err != nil {
+				fmt.Println("ERROR", err.Error())
+				os.Exit(1)
+			}
+
+			err = analyzer.Read()
+			if err != nil {
+				fmt.Println("Error on analyze system", err.Error())
+				os.Exit(1)
+			}
+			topology := analyzer.GetSystem().Topology
+
+			igpu, err := selectGPU(topology, igpuSelector, specs.GPURoleIntegrated)
+			if err != nil {
+				fmt.Println("Error on select integrated GPU:", err.Error())
+				os.Exit(1)
+			}
+			dgpu, err := selectGPU(topology, dgpuSelector, specs.GPURoleDiscrete)
+			if err != nil {
+				fmt.Println("Error on select discrete GPU:", err.Error())
+				os.Exit(1)
+			}
+
+			if mode == "" {
+				// POST: use the suggested mode of the topology.
+				mode = xorg.ModeOffload
+				if topology.SuggestedMode == specs.PrimeModePrimary &&
+					dgpu.KernelDriver == "nvidia" {
+					mode = xorg.ModeNvidiaPrimary
+				}
+			}
+
+			xconf, err := xorg.Generate(mode, igpu, dgpu)
+			if err != nil {
+				fmt.Println("Error on generate Xorg configuration:", err.Error())
+				os.Exit(1)
+			}
+
+			if !write {
+				fmt.Print(xconf.String())
+				return
+			}
+
+			analyzer.GetBackend().SetExecutor(executor.NewExecutor(
+				config.GetGeneral().GetRootDir(),
+				config.GetGeneral().GetStateDir(), dryRun,
+			))
+
+			plan := specs.NewOperationsPlan()
+			if !utils.Exists(rootfs.RealPath(
+				config.GetGeneral().GetRootDir(), xorg.DefaultXorgConfdDir)) {
+				plan.Mkdir(xorg.DefaultXorgConfdDir)
+			}
+			plan.WriteFile(filepath.Join(xorg.DefaultXorgConfdDir, file),
+				xconf.String(), 0644)
+
+			err = analyzer.GetBackend().GetExecutor().Apply(plan)
+			if err != nil {
+				fmt.Println("Error on apply operations:", err.Error())
+				os.Exit(1)
+			}
+
+			if dryRun {
+				err = executor.PrintPlan(plan, output)
+			} else {
+				err = executor.PrintReport(plan, output)
+			}
+			if err != nil {
+				fmt.Println("Error on print operations:", err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.String("mode", "",
+		"Xorg setup mode (nvidia-primary,offload,reverse-prime). Default based on the GPU topology.")
+	flags.String("igpu", "", "Select the integrated GPU by index, bus id or vendor.")
+	flags.String("dgpu", "", "Select the discrete GPU by index, bus id or vendor.")
+	flags.Bool("write", false,
+		"Write the configuration under /etc/X11/xorg.conf.d instead of print it.")
+	flags.String("file", xorg.DefaultXorgConfFile,
+		"Name of the file to write under /etc/X11/xorg.conf.d.")
+	flags.Bool("dry-run", false, "Show the operations without apply them.")
+	flags.StringP("output", "o", "terminal",
+		"Modify output format of the operations (terminal,yaml,json).")
+
+	return cmd
+}
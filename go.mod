@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/kyokomi/emoji v2.2.4+incompatible
 	github.com/logrusorgru/aurora v2.0.3+incompatible
+	github.com/macaroni-os/macaronictl v0.9.2
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/viper v1.19.0
 	go.uber.org/zap v1.27.0
@@ -19,7 +20,6 @@ require (
 	github.com/google/pprof v0.0.0-20240424215950-a892ee059fd6 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/macaroni-os/macaronictl v0.9.2 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/onsi/ginkgo/v2 v2.19.0 // indirect
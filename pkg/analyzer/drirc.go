@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+// Read the drirc files in the order used by Mesa: the files
+// of the drirc.d directory sorted by name and /etc/drirc.
+func (a *Analyzer) readDrirc() {
+	a.System.Drirc = &specs.DrircSetup{Files: []*specs.DrircFile{}}
+
+	dir := a.Backend.GetDrircConfDir()
+	entries, err := os.ReadDir(a.GetRealPath(dir))
+	if err != nil && !os.IsNotExist(err) {
+		a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+	}
+	// POST: ReadDir returns the entries sorted by name.
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".conf") {
+			a.AddDrircFile(filepath.Join(dir, e.Name()), true)
+		}
+	}
+
+	a.AddDrircFile(a.Backend.GetDrircFile(), false)
+}
+
+// Add the drirc file to the files of the system if present.
+// The user drirc file must be added after the system files.
+func (a *Analyzer) AddDrircFile(file string, isDefault bool) {
+	if !utils.Exists(a.GetRealPath(file)) {
+		return
+	}
+
+	config, err := a.ReadDrircFile(file)
+	if err != nil {
+		a.System.AddDiagnostic(file, specs.DiagnosticMalformedFile, err)
+		return
+	}
+
+	if a.System.Drirc == nil {
+		a.System.Drirc = &specs.DrircSetup{Files: []*specs.DrircFile{}}
+	}
+	a.System.Drirc.Files = append(a.System.Drirc.Files,
+		specs.NewDrircFile(file, config, isDefault))
+}
+
+// Read the drirc file. If the file is not present an empty
+// configuration is returned.
+func (a *Analyzer) ReadDrircFile(file string) (*specs.DrircConfig, error) {
+	if !utils.Exists(a.GetRealPath(file)) {
+		return specs.NewDrircConfig(), nil
+	}
+
+	content, err := a.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return specs.NewDrircConfigFromXml(content)
+}
+
+// Add to the plan the operations to write the drirc file with
+// the options to set and to remove in the sections of the scope.
+// The content not managed of the file is preserved. Return false
+// if the file is already in the expected state.
+func (a *Analyzer) PrepareDrirc(plan *specs.OperationsPlan, file string,
+	scope *specs.DrircScope, set []*specs.DrircOption, unset []string) (bool, error) {
+
+	config, err := a.ReadDrircFile(file)
+	if err != nil {
+		// POST: the file is not overwritten to avoid the loss
+		//       of the user settings.
+		return false, fmt.Errorf("error on read %s: %s", file, err.Error())
+	}
+
+	before := config.Xml()
+
+	for _, o := range set {
+		if _, err := config.SetOption(scope, o.Name, o.Value); err != nil {
+			return false, err
+		}
+	}
+
+	for _, name := range unset {
+		if _, err := config.UnsetOption(scope, name); err != nil {
+			return false, err
+		}
+	}
+
+	after := config.Xml()
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	if !utils.Exists(a.GetRealPath(filepath.Dir(file))) {
+		plan.Mkdir(filepath.Dir(file))
+	}
+	plan.WriteFile(file, string(after), 0644)
+
+	return true, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const (
+	EglVendorSourceSearchPath = "search path"
+	EglVendorFilenamesEnv     = "__EGL_VENDOR_LIBRARY_FILENAMES"
+	EglVendorDirsEnv          = "__EGL_VENDOR_LIBRARY_DIRS"
+	// The directory searched by libglvnd before /usr/share
+	// where the vendors with a custom priority are written.
+	EglVendorConfigDir = "/etc/glvnd/egl_vendor.d"
+)
+
+func (a *Analyzer) readEglVendorDirs() error {
+	dirs, err := a.Backend.GetEglVendorDirs()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		dirEntries, err := os.ReadDir(a.GetRealPath(dir))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+			}
+			continue
+		}
+
+		vendordir := specs.NewEglExternalPlatformFiles(dir)
+		a.System.EglVendorDirs = append(a.System.EglVendorDirs, vendordir)
+
+		for _, file := range dirEntries {
+			if file.IsDir() {
+				continue
+			}
+
+			if !strings.HasSuffix(file.Name(), ".json") &&
+				!strings.HasSuffix(file.Name(), ".json"+specs.DisabledSuffix) {
+				continue
+			}
+
+			content, err := a.ReadFile(path.Join(dir, file.Name()))
+			if err != nil {
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticUnreadableFile, err)
+				continue
+			}
+
+			vendorjson, err := specs.NewICDJson(content)
+			if err != nil {
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticMalformedFile, err)
+				continue
+			}
+
+			vendordir.AddFile(file.Name(), specs.NewJsonFile(file.Name(), vendorjson))
+		}
+	}
+
+	return nil
+}
+
+func (a *Analyzer) newEglVendor(file, source string) *specs.EglVendor {
+	ans := &specs.EglVendor{
+		Name:   specs.GetEglVendorName(path.Base(file)),
+		File:   file,
+		Source: source,
+	}
+
+	if content, err := a.ReadFile(file); err == nil {
+		if vendorjson, err := specs.NewICDJson(content); err == nil {
+			ans.LibraryPath = vendorjson.ICD.LibraryPath
+		}
+	}
+
+	return ans
+}
+
+// Return the enabled EGL vendors in the order used by libglvnd.
+// The vendors of __EGL_VENDOR_LIBRARY_FILENAMES replace the
+// vendors of the directories. The directories of
+// __EGL_VENDOR_LIBRARY_DIRS replace the default directories.
+// The files of every directory are sorted by name. Like libglvnd
+// a vendor library already loaded by a previous file is skipped.
+func (a *Analyzer) GetEglVendors(getenv func(string) string) []*specs.EglVendor {
+	vendors := a.getEglVendors(getenv)
+	ans := []*specs.EglVendor{}
+	libs := make(map[string]bool, 0)
+	for _, v := range vendors {
+		if v.LibraryPath != "" {
+			if _, present := libs[v.LibraryPath]; present {
+				continue
+			}
+			libs[v.LibraryPath] = true
+		}
+		ans = append(ans, v)
+	}
+	return ans
+}
+
+func (a *Analyzer) getEglVendors(getenv func(string) string) []*specs.EglVendor {
+	ans := []*specs.EglVendor{}
+
+	if files := getenv(EglVendorFilenamesEnv); files != "" {
+		for _, f := range strings.Split(files, ":") {
+			if f != "" {
+				ans = append(ans, a.newEglVendor(f, EglVendorFilenamesEnv))
+			}
+		}
+		return ans
+	}
+
+	if dirs := getenv(EglVendorDirsEnv); dirs != "" {
+		for _, dir := range strings.Split(dirs, ":") {
+			if dir == "" {
+				continue
+			}
+			entries, err := os.ReadDir(a.GetRealPath(dir))
+			if err != nil {
+				continue
+			}
+			// POST: ReadDir returns the entries sorted by name.
+			for _, e := range entries {
+				if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+					ans = append(ans,
+						a.newEglVendor(path.Join(dir, e.Name()), EglVendorDirsEnv))
+				}
+			}
+		}
+		return ans
+	}
+
+	for _, dir := range a.System.EglVendorDirs {
+		files := []string{}
+		for f, jsonfile := range dir.Files {
+			if !jsonfile.Disabled {
+				files = append(files, f)
+			}
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			ans = append(ans, &specs.EglVendor{
+				Name:        specs.GetEglVendorName(f),
+				File:        path.Join(dir.Path, f),
+				LibraryPath: dir.Files[f].File.ICD.LibraryPath,
+				Source:      EglVendorSourceSearchPath,
+			})
+		}
+	}
+
+	return ans
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Add to the plan the operations to enable or disable the NVIDIA
+// GBM library of the active driver. With purge the library link is
+// removed. Return false if the library is already in the expected
+// state.
+func (a *Analyzer) PrepareNVIDIAGbmLib(plan *specs.OperationsPlan, enable, purge bool) (bool, error) {
+	nvidiaSetup := a.System.Nvidia
+	if nvidiaSetup == nil || nvidiaSetup.VersionActive == "" {
+		return false, fmt.Errorf("no NVIDIA version active available")
+	}
+
+	nvidiagbmlib := a.System.GetGBMLibrary(specs.NVIDIAGbmLibName)
+	libpath := filepath.Join(a.Backend.GetGBMLibDir(), specs.NVIDIAGbmLibName)
+	libpathShort := filepath.Join(a.Backend.GetGBMLibDir(), specs.NVIDIAGbmLibNameShort)
+
+	if enable {
+		nvidiaDriver := nvidiaSetup.GetDriver(nvidiaSetup.VersionActive)
+		if nvidiaDriver == nil {
+			return false, fmt.Errorf("unexpected error on retrieve nvidia driver data")
+		}
+		linkedFile := filepath.Join(nvidiaDriver.Path, "lib64", specs.NVIDIAGbmLibName)
+
+		if nvidiagbmlib == nil {
+			// POST: The library link is not present.
+			plan.CreateLink(libpath, linkedFile)
+			// Create the short lib name link
+			plan.CreateLink(libpathShort, linkedFile)
+			return true, nil
+		}
+
+		if nvidiagbmlib.LinkedFile != "" && nvidiagbmlib.LinkedFile != linkedFile {
+			// POST: the link points to another driver version.
+			if nvidiagbmlib.Disabled {
+				plan.Remove(libpath + specs.DisabledSuffix)
+			}
+			plan.CreateLink(libpath, linkedFile)
+			plan.CreateLink(libpathShort, linkedFile)
+			return true, nil
+		}
+
+		if nvidiagbmlib.Disabled {
+			plan.Rename(libpath+specs.DisabledSuffix, libpath)
+			return true, nil
+		}
+
+		return false, nil
+	}
+
+	if purge && nvidiagbmlib != nil {
+		plan.PurgeFile(a.Backend.GetGBMLibDir(), specs.NVIDIAGbmLibName,
+			nvidiagbmlib.Disabled)
+	} else if nvidiagbmlib == nil || nvidiagbmlib.Disabled {
+		return false, nil
+	} else {
+		plan.DisableFile(a.Backend.GetGBMLibDir(), specs.NVIDIAGbmLibName, false)
+	}
+
+	if rootfs.Lexists(a.GetRealPath(libpathShort)) {
+		plan.Remove(libpathShort)
+	}
+
+	return true, nil
+}
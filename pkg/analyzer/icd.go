@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"debug/elf"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Classify the ICD file as 32 or 64 bit. The architecture is
+// retrieved from:
+//  1. the library_arch field of the manifest.
+//  2. the ELF class of the library if defined with a path.
+//  3. the suffix of the file name (ex. radeon_icd.i686.json).
+func (a *Analyzer) getIcdArch(dir string, f *specs.JsonFile) string {
+	if f.File == nil {
+		return ""
+	}
+
+	switch f.File.ICD.LibraryArch {
+	case specs.IcdArch32, specs.IcdArch64:
+		return f.File.ICD.LibraryArch
+	}
+
+	lib := f.File.ICD.LibraryPath
+	if strings.Contains(lib, "/") {
+		if !filepath.IsAbs(lib) {
+			lib = filepath.Join(dir, lib)
+		}
+		if arch := a.getElfArch(lib); arch != "" {
+			return arch
+		}
+	}
+
+	return specs.GetIcdArchFromName(f.Name)
+}
+
+func (a *Analyzer) getElfArch(lib string) string {
+	resolved, err := rootfs.ResolvePath(a.Backend.GetRootDir(), lib)
+	if err != nil {
+		return ""
+	}
+
+	fd, err := elf.Open(resolved)
+	if err != nil {
+		return ""
+	}
+	defer fd.Close()
+
+	switch fd.Class {
+	case elf.ELFCLASS32:
+		return specs.IcdArch32
+	case elf.ELFCLASS64:
+		return specs.IcdArch64
+	default:
+		return ""
+	}
+}
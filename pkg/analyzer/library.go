@@ -0,0 +1,72 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+// The directories where the loader searches the libraries
+// defined without path.
+var libraryDirs = []string{
+	"/usr/lib64", "/usr/lib", "/lib64", "/lib", "/usr/lib32",
+}
+
+// Check if the library of a configuration file is available.
+// The library could be an absolute path, a path relative to the
+// directory of the file or a name searched by the dynamic loader.
+func (a *Analyzer) LibraryExists(dir, lib string) bool {
+	candidates := []string{}
+	if filepath.IsAbs(lib) {
+		candidates = append(candidates, lib)
+	} else if strings.Contains(lib, "/") {
+		candidates = append(candidates, filepath.Join(dir, lib))
+	} else {
+		for _, d := range append(a.GetLdsoconfDirs(), libraryDirs...) {
+			candidates = append(candidates, filepath.Join(d, lib))
+		}
+	}
+
+	for _, c := range candidates {
+		resolved, err := rootfs.ResolvePath(a.Backend.GetRootDir(), c)
+		if err == nil && utils.Exists(resolved) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the directories defined in the /etc/ld.so.conf.d files.
+func (a *Analyzer) GetLdsoconfDirs() []string {
+	ans := []string{}
+
+	files, err := filepath.Glob(a.GetRealPath("/etc/ld.so.conf.d/*"))
+	if err != nil {
+		return ans
+	}
+
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") ||
+				strings.HasPrefix(line, "include") {
+				continue
+			}
+			ans = append(ans, line)
+		}
+	}
+
+	return ans
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+type vulkanLayerRef struct {
+	Name     string
+	Path     string
+	Implicit bool
+}
+
+func (a *Analyzer) readVulkanLoaderSettings() {
+	file := a.Backend.GetVulkanLoaderSettingsFile()
+	if !utils.Exists(a.GetRealPath(file)) {
+		return
+	}
+
+	settings, err := a.ReadVulkanLoaderSettings(file)
+	if err != nil {
+		a.System.AddDiagnostic(file, specs.DiagnosticMalformedFile, err)
+		return
+	}
+
+	a.System.VulkanLoaderSettings = &specs.VulkanLoaderSettingsFile{
+		Path:     file,
+		Settings: settings,
+	}
+}
+
+// Read the Vulkan loader settings file. If the file is not
+// present empty settings are returned.
+func (a *Analyzer) ReadVulkanLoaderSettings(file string) (*specs.VulkanLoaderSettings, error) {
+	if !utils.Exists(a.GetRealPath(file)) {
+		return specs.NewVulkanLoaderSettings(), nil
+	}
+
+	content, err := a.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return specs.NewVulkanLoaderSettingsFromJson(content)
+}
+
+// Return the enabled layers with the name or defined in the
+// manifest with the file name. The first layer found with a
+// name is used.
+func (a *Analyzer) getVulkanLayerRefs(name string) []*vulkanLayerRef {
+	ans := []*vulkanLayerRef{}
+	names := make(map[string]bool)
+
+	for _, dir := range a.System.VulkanLayersDirs {
+		files := []string{}
+		for f := range dir.Files {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			file := dir.Files[f]
+			if file.Disabled || file.Manifest == nil {
+				continue
+			}
+
+			for _, l := range file.Manifest.GetLayers() {
+				if l.Name == "" || names[l.Name] {
+					continue
+				}
+				if file.Name != name && l.Name != name {
+					continue
+				}
+				names[l.Name] = true
+				ans = append(ans, &vulkanLayerRef{
+					Name:     l.Name,
+					Path:     filepath.Join(dir.Path, file.Name),
+					Implicit: file.Implicit,
+				})
+			}
+		}
+	}
+
+	return ans
+}
+
+// Add to the plan the operations to write the loader settings file
+// with the control of the layer (or of the layers of the manifest
+// file) and with the order of the layers. An empty control removes
+// the layer from the settings. Return false if the settings are
+// already in the expected state.
+func (a *Analyzer) PrepareVulkanLoaderSettings(plan *specs.OperationsPlan,
+	file, layer, control string, order []string) (bool, error) {
+
+	settings, err := a.ReadVulkanLoaderSettings(file)
+	if err != nil {
+		// POST: the file is not overwritten to avoid the loss
+		//       of the user settings.
+		return false, fmt.Errorf("error on read %s: %s", file, err.Error())
+	}
+
+	entry := settings.GetGlobalSettings(true)
+
+	before, err := settings.Json()
+	if err != nil {
+		return false, err
+	}
+
+	if layer != "" {
+		refs := a.getVulkanLayerRefs(layer)
+		if control == "" {
+			if len(refs) == 0 {
+				entry.RemoveLayer(layer)
+			}
+			for _, r := range refs {
+				entry.RemoveLayer(r.Name)
+			}
+		} else {
+			if len(refs) == 0 {
+				return false, fmt.Errorf("no vulkan layer with name %s found", layer)
+			}
+			for _, r := range refs {
+				entry.SetLayer(r.Name, r.Path, control, r.Implicit)
+			}
+		}
+	}
+
+	if len(order) > 0 {
+		for _, n := range order {
+			if entry.GetLayer(n) != nil {
+				continue
+			}
+			// POST: the layers not configured are added with
+			//       the auto control to define the order.
+			refs := a.getVulkanLayerRefs(n)
+			if len(refs) == 0 {
+				return false, fmt.Errorf("no vulkan layer with name %s found", n)
+			}
+			for _, r := range refs {
+				entry.SetLayer(r.Name, r.Path, specs.VulkanLayerControlAuto, r.Implicit)
+			}
+		}
+
+		if err := entry.SetLayersOrder(order); err != nil {
+			return false, err
+		}
+	}
+
+	after, err := settings.Json()
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	if !utils.Exists(a.GetRealPath(filepath.Dir(file))) {
+		plan.Mkdir(filepath.Dir(file))
+	}
+	plan.WriteFile(file, string(after)+"\n", 0644)
+
+	return true, nil
+}
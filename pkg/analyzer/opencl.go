@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Read the OpenCL ICD registry. Every .icd file defines
+// the library of an OpenCL platform.
+func (a *Analyzer) readOpenCLVendors() {
+	dir := a.Backend.GetOpenCLVendorsDir()
+
+	dirEntries, err := os.ReadDir(a.GetRealPath(dir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+		}
+		return
+	}
+
+	a.System.OpenCLVendors = specs.NewOpenCLVendorFiles(dir)
+
+	for _, file := range dirEntries {
+		if file.IsDir() {
+			continue
+		}
+
+		if !strings.HasSuffix(file.Name(), specs.OpenCLVendorSuffix) &&
+			!strings.HasSuffix(file.Name(), specs.OpenCLVendorSuffix+specs.DisabledSuffix) {
+			continue
+		}
+
+		content, err := a.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			a.System.AddDiagnostic(path.Join(dir, file.Name()),
+				specs.DiagnosticUnreadableFile, err)
+			continue
+		}
+
+		vendor := specs.NewOpenCLVendorFile(file.Name())
+		vendor.LibraryPath = specs.GetOpenCLVendorLibrary(content)
+		if vendor.LibraryPath == "" {
+			a.System.AddDiagnostic(path.Join(dir, file.Name()),
+				specs.DiagnosticMalformedFile, fmt.Errorf("no library defined"))
+		} else {
+			vendor.LibraryAvailable = a.LibraryExists(dir, vendor.LibraryPath)
+		}
+
+		a.System.OpenCLVendors.Files[file.Name()] = vendor
+	}
+}
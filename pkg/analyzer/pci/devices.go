@@ -6,28 +6,70 @@ package pci
 
 import (
 	"encoding/json"
-	"strings"
+	"path/filepath"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/kernel"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
 	"gopkg.in/yaml.v2"
 )
 
-func (s *SystemDevices) Yaml() ([]byte, error) {
-	return yaml.Marshal(s)
-}
+// Read the PCI devices from the sysfs directory (normally /sys).
+// The lspci command is used only when sysfs is not available. The
+// kernel modules are resolved with the aliasFile or with the
+// modules.alias file of the running kernel if empty. The names of
+// the devices are resolved with the pci.ids file pciIdsPath or with
+// the default pci.ids file if empty. An unreadable pci.ids file is
+// returned as *specs.ScanError together with the devices.
+func GetDevices(sysfsDir, aliasFile, pciIdsPath string) (*SystemDevices, error) {
+	if sysfsDir == "" {
+		sysfsDir = DefaultSysfsDir
+	}
 
-func (s *SystemDevices) Json() ([]byte, error) {
-	return json.Marshal(s)
-}
+	pciDir := filepath.Join(sysfsDir, SysfsPciDevicesDir)
+	if !utils.Exists(pciDir) {
+		return GetDevicesFromLspci()
+	}
 
-func (s *SystemDevices) GetVGADevices() *[]*PCIDevice {
-	ans := []*PCIDevice{}
+	if aliasFile == "" {
+		kernelVersion, err := kernel.GetRuntimeKernelVersion()
+		if err == nil {
+			aliasFile = filepath.Join("/lib/modules", kernelVersion, "modules.alias")
+		}
+	}
 
-	for _, device := range *s {
-		words := strings.Split(device.ClassName, " ")
-		if len(words) > 0 && words[0] == "VGA" {
-			ans = append(ans, device)
+	ans, err := GetDevicesFromSysfs(pciDir, aliasFile)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := LoadPciIds(pciIdsPath)
+	if err != nil {
+		f := pciIdsPath
+		if f == "" {
+			f = "pci.ids"
+		}
+		return ans, &specs.ScanError{
+			Path: f, Kind: specs.DiagnosticUnreadableFile, Err: err,
 		}
 	}
+	ans.ResolveNames(db)
+
+	return ans, nil
+}
+
+// Resolve the names of the devices from the pci.ids database.
+func (s *SystemDevices) ResolveNames(db *PciIds) {
+	for _, device := range *s {
+		db.ResolveNames(device)
+	}
+}
 
-	return &ans
+func (s *SystemDevices) Yaml() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+func (s *SystemDevices) Json() ([]byte, error) {
+	return json.Marshal(s)
 }
@@ -0,0 +1,154 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const (
+	GPURoleIntegrated = specs.GPURoleIntegrated
+	GPURoleDiscrete   = specs.GPURoleDiscrete
+	GPURoleVirtual    = specs.GPURoleVirtual
+	GPURoleUnknown    = specs.GPURoleUnknown
+
+	// The PCI class of the display controllers
+	// (VGA, XGA, 3D, Display).
+	DisplayControllerClass = "03"
+)
+
+var gpuVendors = map[string]string{
+	"10de": "nvidia",
+	"1002": "amd",
+	"8086": "intel",
+	"1af4": "virtio",
+	"15ad": "vmware",
+	"1234": "qemu",
+	"1b36": "qxl",
+	"80ee": "virtualbox",
+	"1414": "hyperv",
+	"1a03": "aspeed",
+	"102b": "matrox",
+}
+
+// The codenames of the AMD APUs used to identify
+// the integrated AMD GPUs.
+var amdApuCodenames = []string{
+	"Barcelo", "Cezanne", "Hawk Point", "Lucienne", "Mendocino",
+	"Phoenix", "Picasso", "Raphael", "Raven", "Rembrandt",
+	"Renoir", "Strix", "Van Gogh", "Kaveri", "Carrizo",
+	"Stoney", "Kabini", "Mullins", "Granite Ridge",
+}
+
+// The device ids of the AMD APUs used to identify the
+// integrated AMD GPUs when the pci.ids names are not available.
+var amdApuDeviceIds = []string{
+	"9874", // Carrizo
+	"98e4", // Stoney
+	"15dd", // Raven
+	"15d8", // Picasso
+	"1636", // Renoir
+	"164c", // Lucienne
+	"1638", // Cezanne
+	"15e7", // Barcelo
+	"163f", // Van Gogh
+	"1681", // Rembrandt
+	"164e", // Raphael
+	"1506", // Mendocino
+	"15bf", // Phoenix
+	"15c8", // Phoenix
+	"150e", // Strix
+	"13c0", // Granite Ridge
+}
+
+// Return the devices with a display controller class (03xx)
+// tagged with vendor and role.
+func (s *SystemDevices) GetGPUDevices() *[]*PCIDevice {
+	ans := []*PCIDevice{}
+
+	for _, device := range *s {
+		if device.IsGPU() {
+			ans = append(ans, device)
+		}
+	}
+
+	for _, device := range ans {
+		device.Vendor = device.GetVendor()
+		device.GPURole = device.guessGPURole()
+	}
+
+	return &ans
+}
+
+func (d *PCIDevice) IsGPU() bool {
+	if len(d.ClassId) == 4 {
+		return d.ClassId[0:2] == DisplayControllerClass
+	}
+	// POST: data without class id
+	words := strings.Split(d.ClassName, " ")
+	return len(words) > 0 && (words[0] == "VGA" || words[0] == "3D" || words[0] == "Display")
+}
+
+// Return the name of the vendor of the device or the
+// vendor id if the vendor is not known.
+func (d *PCIDevice) GetVendor() string {
+	vendorId := strings.Split(d.Id, ":")[0]
+	if v, ok := gpuVendors[strings.ToLower(vendorId)]; ok {
+		return v
+	}
+	return vendorId
+}
+
+func (d *PCIDevice) GetBus() string {
+	return strings.Split(d.BusId, ":")[0]
+}
+
+// Return the device id without the vendor.
+func (d *PCIDevice) GetDeviceId() string {
+	ids := strings.Split(d.Id, ":")
+	if len(ids) != 2 {
+		return ""
+	}
+	return strings.ToLower(ids[1])
+}
+
+// Heuristic to identify integrated and discrete GPUs:
+//   - Intel GPUs on bus 00 are integrated (Arc cards are on a bridge).
+//   - NVIDIA GPUs are discrete.
+//   - AMD GPUs are integrated when the device id or the name
+//     is of an APU. The boot VGA is not used because on desktop
+//     the discrete GPU is normally the boot VGA.
+func (d *PCIDevice) guessGPURole() string {
+	switch d.GetVendor() {
+	case "intel":
+		if d.GetBus() == "00" {
+			return GPURoleIntegrated
+		}
+		return GPURoleDiscrete
+	case "nvidia":
+		return GPURoleDiscrete
+	case "aspeed":
+		// POST: BMC video chip on server motherboards.
+		return GPURoleIntegrated
+	case "amd":
+		for _, id := range amdApuDeviceIds {
+			if d.GetDeviceId() == id {
+				return GPURoleIntegrated
+			}
+		}
+		for _, codename := range amdApuCodenames {
+			if strings.Contains(d.Name, codename) {
+				return GPURoleIntegrated
+			}
+		}
+		return GPURoleDiscrete
+	case "virtio", "vmware", "qemu", "qxl", "virtualbox", "hyperv":
+		return GPURoleVirtual
+	default:
+		return GPURoleUnknown
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"testing"
+)
+
+func TestGetGPUDevicesRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices SystemDevices
+		want    map[string]string
+	}{
+		{
+			name: "AMD desktop with NVIDIA",
+			devices: SystemDevices{
+				{BusId: "03:00.0", ClassId: "0300", Id: "1002:73bf", BootVGA: true,
+					Name: "Advanced Micro Devices, Inc. [AMD/ATI] Navi 21 [Radeon RX 6800/6800 XT / 6900 XT]"},
+				{BusId: "0a:00.0", ClassId: "0300", Id: "10de:2684"},
+			},
+			want: map[string]string{"03:00.0": GPURoleDiscrete, "0a:00.0": GPURoleDiscrete},
+		},
+		{
+			name: "AMD APU with NVIDIA without pci.ids",
+			devices: SystemDevices{
+				{BusId: "01:00.0", ClassId: "0300", Id: "10de:1f15"},
+				{BusId: "05:00.0", ClassId: "0300", Id: "1002:1638", BootVGA: true},
+			},
+			want: map[string]string{"01:00.0": GPURoleDiscrete, "05:00.0": GPURoleIntegrated},
+		},
+		{
+			name: "AMD APU by name",
+			devices: SystemDevices{
+				{BusId: "c1:00.0", ClassId: "0300", Id: "1002:ffff",
+					Name: "Advanced Micro Devices, Inc. [AMD/ATI] Phoenix3"},
+			},
+			want: map[string]string{"c1:00.0": GPURoleIntegrated},
+		},
+		{
+			name: "Intel with NVIDIA",
+			devices: SystemDevices{
+				{BusId: "00:02.0", ClassId: "0300", Id: "8086:3e9b", BootVGA: true},
+				{BusId: "01:00.0", ClassId: "0302", Id: "10de:1f15"},
+				{BusId: "00:1f.3", ClassId: "0403", Id: "8086:a348"},
+			},
+			want: map[string]string{"00:02.0": GPURoleIntegrated, "01:00.0": GPURoleDiscrete},
+		},
+		{
+			name: "virtual",
+			devices: SystemDevices{
+				{BusId: "00:01.0", ClassId: "0300", Id: "1af4:1050"},
+			},
+			want: map[string]string{"00:01.0": GPURoleVirtual},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gpus := tt.devices.GetGPUDevices()
+			if len(*gpus) != len(tt.want) {
+				t.Fatalf("gpus = %d, want %d", len(*gpus), len(tt.want))
+			}
+			for _, gpu := range *gpus {
+				if gpu.GPURole != tt.want[gpu.BusId] {
+					t.Errorf("%s role = %s, want %s", gpu.BusId, gpu.GPURole, tt.want[gpu.BusId])
+				}
+			}
+		})
+	}
+}
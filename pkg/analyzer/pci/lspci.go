@@ -21,14 +21,21 @@ type PCIDevice struct {
 	Name              string   `json:"name,omitempty" yaml:"name,omitempty"`
 	Id                string   `json:"id,omitempty" yaml:"id,omitempty"`
 	Subsystem         string   `json:"subsystem,omitempty" yaml:"subsystem,omitempty"`
+	SubsystemId       string   `json:"subsystem_id,omitempty" yaml:"subsystem_id,omitempty"`
 	DeviceName        string   `json:"device_name,omitempty" yaml:"device_name,omitempty"`
 	KernelDriverInUse string   `json:"kernel_driver_inuse,omitempty" yaml:"kernel_driver_inuse,omitempty"`
 	KernelModules     []string `json:"kernel_modules,omitempty" yaml:"kernel_modules,omitempty"`
+	Modalias          string   `json:"modalias,omitempty" yaml:"modalias,omitempty"`
+	BootVGA           bool     `json:"boot_vga,omitempty" yaml:"boot_vga,omitempty"`
+	// Vendor and role of the device. Only for GPU devices.
+	Vendor  string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	GPURole string `json:"gpu_role,omitempty" yaml:"gpu_role,omitempty"`
 }
 
 type SystemDevices []*PCIDevice
 
-func GetDevices() (*SystemDevices, error) {
+// Read the PCI devices from the output of the lspci command.
+func GetDevicesFromLspci() (*SystemDevices, error) {
 	var errBuffer bytes.Buffer
 	var outBuffer bytes.Buffer
 
@@ -53,7 +60,7 @@ func GetDevices() (*SystemDevices, error) {
 	}
 
 	if cmd.ProcessState.ExitCode() != 0 {
-		return nil, fmt.Errorf("lspci exiting with %s: %s",
+		return nil, fmt.Errorf("lspci exiting with %d: %s",
 			cmd.ProcessState.ExitCode(),
 			errBuffer.String())
 	}
@@ -93,6 +100,9 @@ func parseLspciOutput(output string) (*[]*PCIDevice, error) {
 
 			if strings.HasPrefix(line, "\tSubsystem") {
 				lastPCIDevice.Subsystem = line[len("Subsystem:")+2:]
+				if strings.HasSuffix(lastPCIDevice.Subsystem, "]") {
+					lastPCIDevice.SubsystemId = lastPCIDevice.Subsystem[strings.LastIndex(lastPCIDevice.Subsystem, "[")+1 : len(lastPCIDevice.Subsystem)-1]
+				}
 			} else if strings.HasPrefix(line, "\tKernel driver") {
 				lastPCIDevice.KernelDriverInUse = words[4]
 			} else if strings.HasPrefix(line, "\tDeviceName") {
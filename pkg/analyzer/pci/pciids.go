@@ -0,0 +1,244 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+// The default paths of the pci.ids file installed by hwdata or pciutils.
+var DefaultPciIdsPaths = []string{
+	"/usr/share/hwdata/pci.ids",
+	"/usr/share/misc/pci.ids",
+	"/usr/share/pci.ids",
+}
+
+type PciIdsDevice struct {
+	Id   string
+	Name string
+	// The subsystem names indexed by subvendor:subdevice.
+	Subsystems map[string]string
+}
+
+type PciIdsVendor struct {
+	Id      string
+	Name    string
+	Devices map[string]*PciIdsDevice
+}
+
+type PciIdsClass struct {
+	Id         string
+	Name       string
+	Subclasses map[string]string
+}
+
+// The pci.ids database indexed by ids.
+type PciIds struct {
+	File    string
+	Vendors map[string]*PciIdsVendor
+	Classes map[string]*PciIdsClass
+}
+
+func NewPciIds() *PciIds {
+	return &PciIds{
+		Vendors: make(map[string]*PciIdsVendor),
+		Classes: make(map[string]*PciIdsClass),
+	}
+}
+
+// Load the pci.ids file. If the path is empty the first
+// available file between the default paths is used.
+func LoadPciIds(f string) (*PciIds, error) {
+	if f == "" {
+		for _, p := range DefaultPciIdsPaths {
+			if utils.Exists(p) {
+				f = p
+				break
+			}
+		}
+		if f == "" {
+			return nil, fmt.Errorf("no pci.ids file found")
+		}
+	}
+
+	fd, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	ans := NewPciIds()
+	ans.File = f
+	err = ans.Parse(fd)
+	if err != nil {
+		return nil, fmt.Errorf("error on parse %s: %s", f, err.Error())
+	}
+
+	return ans, nil
+}
+
+// Parse the pci.ids content. Example of the syntax:
+//
+//	10de  NVIDIA Corporation
+//		1f15  TU106M [GeForce RTX 2060 Mobile]
+//			1043 18f1  GeForce RTX 2060 Mobile
+//	C 03  Display controller
+//		00  VGA compatible controller
+func (p *PciIds) Parse(r io.Reader) error {
+	var lastVendor *PciIdsVendor
+	var lastDevice *PciIdsDevice
+	var lastClass *PciIdsClass
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\t\t"):
+			// POST: subsystem or prog-if line. The prog-if
+			//       names are not used.
+			if lastDevice == nil {
+				continue
+			}
+			words := strings.SplitN(strings.TrimSpace(line), " ", 3)
+			if len(words) < 3 {
+				continue
+			}
+			lastDevice.Subsystems[words[0]+":"+words[1]] =
+				strings.TrimSpace(words[2])
+
+		case strings.HasPrefix(line, "\t"):
+			id, name := splitPciIdsLine(line[1:])
+			if lastClass != nil {
+				lastClass.Subclasses[id] = name
+			} else if lastVendor != nil {
+				lastDevice = &PciIdsDevice{
+					Id:         id,
+					Name:       name,
+					Subsystems: make(map[string]string),
+				}
+				lastVendor.Devices[id] = lastDevice
+			}
+
+		case strings.HasPrefix(line, "C "):
+			id, name := splitPciIdsLine(line[2:])
+			lastClass = &PciIdsClass{
+				Id:         id,
+				Name:       name,
+				Subclasses: make(map[string]string),
+			}
+			p.Classes[id] = lastClass
+			lastVendor = nil
+			lastDevice = nil
+
+		default:
+			id, name := splitPciIdsLine(line)
+			lastVendor = &PciIdsVendor{
+				Id:      id,
+				Name:    name,
+				Devices: make(map[string]*PciIdsDevice),
+			}
+			p.Vendors[id] = lastVendor
+			lastDevice = nil
+			lastClass = nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+func splitPciIdsLine(line string) (string, string) {
+	words := strings.SplitN(line, " ", 2)
+	if len(words) < 2 {
+		return strings.ToLower(words[0]), ""
+	}
+	return strings.ToLower(words[0]), strings.TrimSpace(words[1])
+}
+
+func (p *PciIds) GetVendorName(vendor string) string {
+	if v, ok := p.Vendors[strings.ToLower(vendor)]; ok {
+		return v.Name
+	}
+	return ""
+}
+
+func (p *PciIds) GetDeviceName(vendor, device string) string {
+	if v, ok := p.Vendors[strings.ToLower(vendor)]; ok {
+		if d, ok := v.Devices[strings.ToLower(device)]; ok {
+			return d.Name
+		}
+	}
+	return ""
+}
+
+func (p *PciIds) GetSubsystemName(vendor, device, subvendor, subdevice string) string {
+	if v, ok := p.Vendors[strings.ToLower(vendor)]; ok {
+		if d, ok := v.Devices[strings.ToLower(device)]; ok {
+			return d.Subsystems[strings.ToLower(subvendor+":"+subdevice)]
+		}
+	}
+	return ""
+}
+
+// Return the name of the subclass or of the class
+// if the subclass is not available. The classId is
+// in the format CCSS (ex. 0300).
+func (p *PciIds) GetClassName(classId string) string {
+	classId = strings.ToLower(classId)
+	if len(classId) < 4 {
+		return ""
+	}
+
+	if c, ok := p.Classes[classId[0:2]]; ok {
+		if name, ok := c.Subclasses[classId[2:4]]; ok {
+			return name
+		}
+		return c.Name
+	}
+	return ""
+}
+
+// Set the names of the device like lspci -nn.
+func (p *PciIds) ResolveNames(d *PCIDevice) {
+	ids := strings.Split(d.Id, ":")
+	if len(ids) != 2 {
+		return
+	}
+
+	if name := p.GetClassName(d.ClassId); name != "" {
+		d.ClassName = name
+	}
+
+	vendorName := p.GetVendorName(ids[0])
+	if vendorName != "" {
+		deviceName := p.GetDeviceName(ids[0], ids[1])
+		if deviceName == "" {
+			deviceName = "Device"
+		}
+		d.Name = vendorName + " " + deviceName
+	}
+
+	subIds := strings.Split(d.SubsystemId, ":")
+	if len(subIds) == 2 {
+		subvendorName := p.GetVendorName(subIds[0])
+		if subvendorName != "" {
+			subName := p.GetSubsystemName(ids[0], ids[1], subIds[0], subIds[1])
+			if subName == "" {
+				subName = "Device"
+			}
+			d.Subsystem = fmt.Sprintf("%s %s [%s]",
+				subvendorName, subName, d.SubsystemId)
+		}
+	}
+}
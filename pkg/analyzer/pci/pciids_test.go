@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPciIds = `#
+#	List of PCI ID's
+#
+# Syntax:
+# vendor  vendor_name
+#	device  device_name				<-- single tab
+#		subvendor subdevice  subsystem_name	<-- two tabs
+
+1043  ASUSTeK Computer Inc.
+	0675  ISDNLink P-IN100-ST-D
+10de  NVIDIA Corporation
+	1f15  TU106M [GeForce RTX 2060 Mobile]
+		1043 18f1  GeForce RTX 2060 Mobile
+		1043 1b11  TU106M [GeForce RTX 2060 Max-Q]
+	2684  AD102 [GeForce RTX 4090]
+8086  Intel Corporation
+	3e9b  CoffeeLake-H GT2 [UHD Graphics 630]
+		1043 18f1  UHD Graphics 630 (Mobile)
+
+# List of known device classes, subclasses and programming interfaces
+
+C 03  Display controller
+	00  VGA compatible controller
+		00  VGA controller
+		01  8514 controller
+	02  3D controller
+C 04  Multimedia controller
+	03  Audio device
+`
+
+func TestPciIdsParse(t *testing.T) {
+	db := NewPciIds()
+	if err := db.Parse(strings.NewReader(testPciIds)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"vendor", db.GetVendorName("10de"), "NVIDIA Corporation"},
+		{"vendor uppercase", db.GetVendorName("10DE"), "NVIDIA Corporation"},
+		{"unknown vendor", db.GetVendorName("abcd"), ""},
+		{"device", db.GetDeviceName("10de", "1f15"), "TU106M [GeForce RTX 2060 Mobile]"},
+		{"device without subsystems", db.GetDeviceName("10de", "2684"), "AD102 [GeForce RTX 4090]"},
+		{"unknown device", db.GetDeviceName("10de", "ffff"), ""},
+		{"subsystem", db.GetSubsystemName("10de", "1f15", "1043", "18f1"), "GeForce RTX 2060 Mobile"},
+		{"second subsystem", db.GetSubsystemName("10de", "1f15", "1043", "1b11"), "TU106M [GeForce RTX 2060 Max-Q]"},
+		{"subsystem of another device", db.GetSubsystemName("8086", "3e9b", "1043", "18f1"), "UHD Graphics 630 (Mobile)"},
+		{"unknown subsystem", db.GetSubsystemName("10de", "2684", "1043", "18f1"), ""},
+		{"subclass", db.GetClassName("0302"), "3D controller"},
+		{"class of unknown subclass", db.GetClassName("0380"), "Display controller"},
+		{"subclass of another class", db.GetClassName("0403"), "Audio device"},
+		{"unknown class", db.GetClassName("ff00"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	// POST: the prog-if lines are not parsed as subsystems.
+	if len(db.Vendors["8086"].Devices) != 1 {
+		t.Errorf("devices of 8086 = %d, want 1", len(db.Vendors["8086"].Devices))
+	}
+}
+
+func TestPciIdsResolveNames(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(f, []byte(testPciIds), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := LoadPciIds(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		device        *PCIDevice
+		wantName      string
+		wantClass     string
+		wantSubsystem string
+	}{
+		{
+			&PCIDevice{Id: "10de:1f15", ClassId: "0302", SubsystemId: "1043:18f1"},
+			"NVIDIA Corporation TU106M [GeForce RTX 2060 Mobile]",
+			"3D controller",
+			"ASUSTeK Computer Inc. GeForce RTX 2060 Mobile [1043:18f1]",
+		},
+		{
+			&PCIDevice{Id: "10de:ffff", ClassId: "0300", SubsystemId: "1043:0001"},
+			"NVIDIA Corporation Device",
+			"VGA compatible controller",
+			"ASUSTeK Computer Inc. Device [1043:0001]",
+		},
+		{
+			&PCIDevice{Id: "abcd:0001", ClassId: "ff00", ClassName: "Class ff00"},
+			"",
+			"Class ff00",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		db.ResolveNames(tt.device)
+		if tt.device.Name != tt.wantName {
+			t.Errorf("%s: name %q, want %q", tt.device.Id, tt.device.Name, tt.wantName)
+		}
+		if tt.device.ClassName != tt.wantClass {
+			t.Errorf("%s: class %q, want %q", tt.device.Id, tt.device.ClassName, tt.wantClass)
+		}
+		if tt.device.Subsystem != tt.wantSubsystem {
+			t.Errorf("%s: subsystem %q, want %q", tt.device.Id, tt.device.Subsystem, tt.wantSubsystem)
+		}
+	}
+}
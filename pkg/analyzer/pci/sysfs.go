@@ -0,0 +1,210 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultSysfsDir = "/sys"
+	// The directory of the PCI devices under sysfs.
+	SysfsPciDevicesDir = "bus/pci/devices"
+)
+
+// The PCI classes names as reported by lspci.
+var pciClassNames = map[string]string{
+	"0000": "Non-VGA unclassified device",
+	"0001": "VGA compatible unclassified device",
+	"0100": "SCSI storage controller",
+	"0101": "IDE interface",
+	"0104": "RAID bus controller",
+	"0106": "SATA controller",
+	"0107": "Serial Attached SCSI controller",
+	"0108": "Non-Volatile memory controller",
+	"0180": "Mass storage controller",
+	"0200": "Ethernet controller",
+	"0280": "Network controller",
+	"0300": "VGA compatible controller",
+	"0301": "XGA compatible controller",
+	"0302": "3D controller",
+	"0380": "Display controller",
+	"0400": "Multimedia video controller",
+	"0401": "Multimedia audio controller",
+	"0403": "Audio device",
+	"0480": "Multimedia controller",
+	"0500": "RAM memory",
+	"0580": "Memory controller",
+	"0600": "Host bridge",
+	"0601": "ISA bridge",
+	"0604": "PCI bridge",
+	"0680": "Bridge",
+	"0700": "Serial controller",
+	"0780": "Communication controller",
+	"0800": "PIC",
+	"0805": "SD Host controller",
+	"0806": "IOMMU",
+	"0880": "System peripheral",
+	"0c03": "USB controller",
+	"0c05": "SMBus",
+	"0d11": "Bluetooth",
+	"0d80": "Wireless controller",
+	"1080": "Encryption controller",
+	"1101": "Performance counters",
+	"1180": "Signal processing controller",
+	"1200": "Processing accelerators",
+	"ff00": "Unassigned class",
+}
+
+// Read the PCI devices from the sysfs directory (normally
+// /sys/bus/pci/devices). If the aliasFile is defined the kernel
+// modules are resolved from the modalias of the devices.
+func GetDevicesFromSysfs(sysfsDir, aliasFile string) (*SystemDevices, error) {
+	entries, err := os.ReadDir(sysfsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := []*moduleAlias{}
+	if aliasFile != "" {
+		aliases, err = readModulesAlias(aliasFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error on read file %s: %s",
+				aliasFile, err.Error())
+		}
+	}
+
+	ans := SystemDevices{}
+	for _, entry := range entries {
+		device, err := readSysfsDevice(filepath.Join(sysfsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error on read device %s: %s",
+				entry.Name(), err.Error())
+		}
+
+		if device.Modalias != "" {
+			for _, alias := range aliases {
+				if matched, _ := path.Match(alias.Pattern, device.Modalias); matched {
+					device.addKernelModule(alias.Module)
+				}
+			}
+		}
+
+		ans = append(ans, device)
+	}
+
+	return &ans, nil
+}
+
+func readSysfsDevice(dir string) (*PCIDevice, error) {
+	// POST: the default domain is hidden like lspci.
+	ans := &PCIDevice{
+		BusId:         strings.TrimPrefix(filepath.Base(dir), "0000:"),
+		KernelModules: []string{},
+	}
+
+	class, err := readSysfsAttr(dir, "class")
+	if err != nil {
+		return nil, err
+	}
+	// The class file contains class, subclass and prog-if: 0x030000
+	class = strings.TrimPrefix(class, "0x")
+	if len(class) >= 4 {
+		ans.ClassId = class[0:4]
+	}
+	ans.ClassName = pciClassNames[ans.ClassId]
+	if ans.ClassName == "" {
+		ans.ClassName = "Class " + ans.ClassId
+	}
+
+	vendor, err := readSysfsAttr(dir, "vendor")
+	if err != nil {
+		return nil, err
+	}
+	device, err := readSysfsAttr(dir, "device")
+	if err != nil {
+		return nil, err
+	}
+	ans.Id = fmt.Sprintf("%s:%s",
+		strings.TrimPrefix(vendor, "0x"), strings.TrimPrefix(device, "0x"))
+
+	subVendor, _ := readSysfsAttr(dir, "subsystem_vendor")
+	subDevice, _ := readSysfsAttr(dir, "subsystem_device")
+	if subVendor != "" && subDevice != "" {
+		ans.SubsystemId = fmt.Sprintf("%s:%s",
+			strings.TrimPrefix(subVendor, "0x"),
+			strings.TrimPrefix(subDevice, "0x"))
+		ans.Subsystem = fmt.Sprintf("Device [%s]", ans.SubsystemId)
+	}
+
+	driver, err := os.Readlink(filepath.Join(dir, "driver"))
+	if err == nil {
+		ans.KernelDriverInUse = filepath.Base(driver)
+	}
+
+	ans.Modalias, _ = readSysfsAttr(dir, "modalias")
+	ans.DeviceName, _ = readSysfsAttr(dir, "label")
+
+	bootVga, _ := readSysfsAttr(dir, "boot_vga")
+	ans.BootVGA = bootVga == "1"
+
+	return ans, nil
+}
+
+func readSysfsAttr(dir, attr string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+type moduleAlias struct {
+	Pattern string
+	Module  string
+}
+
+// Read the PCI aliases from the modules.alias file of the kernel.
+// Example of line:
+// alias pci:v000010DEd*sv*sd*bc03sc00i00* nvidia
+func readModulesAlias(f string) ([]*moduleAlias, error) {
+	ans := []*moduleAlias{}
+
+	fd, err := os.Open(f)
+	if err != nil {
+		return ans, err
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		words := strings.Fields(scanner.Text())
+		if len(words) != 3 || words[0] != "alias" ||
+			!strings.HasPrefix(words[1], "pci:") {
+			continue
+		}
+		ans = append(ans, &moduleAlias{
+			Pattern: words[1],
+			Module:  words[2],
+		})
+	}
+
+	return ans, scanner.Err()
+}
+
+func (d *PCIDevice) addKernelModule(m string) {
+	// POST: a module could be matched by multiple aliases.
+	for _, km := range d.KernelModules {
+		if km == m {
+			return
+		}
+	}
+	d.KernelModules = append(d.KernelModules, m)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package pci
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testModulesAlias = `# Aliases extracted from modules themselves.
+alias pci:v00008086d00003E9Bsv*sd*bc03sc*i* i915
+alias pci:v000010DEd*sv*sd*bc03sc02i00* nvidia
+alias pci:v000010DEd*sv*sd*bc03sc*i* nouveau
+alias pci:v000010DEd*sv*sd*bc03sc*i* nouveau
+alias usb:v0B05p1837d*dc*dsc*dp*ic*isc*ip*in* asus_wmi
+alias pci:v00008086d0000A348sv*sd*bc*sc*i* snd_hda_intel extra
+`
+
+// Create a fake sysfs tree with the attributes of the devices.
+// The driver attribute is created as a link to the driver directory.
+func newFakeSysfs(t *testing.T, devices map[string]map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for busId, attrs := range devices {
+		devDir := filepath.Join(dir, busId)
+		if err := os.MkdirAll(devDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		for attr, value := range attrs {
+			var err error
+			if attr == "driver" {
+				err = os.Symlink(
+					filepath.Join("../../../../bus/pci/drivers", value),
+					filepath.Join(devDir, attr))
+			} else {
+				err = os.WriteFile(filepath.Join(devDir, attr),
+					[]byte(value+"\n"), 0444)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return dir
+}
+
+func TestGetDevicesFromSysfs(t *testing.T) {
+	sysfsDir := newFakeSysfs(t, map[string]map[string]string{
+		"0000:00:02.0": {
+			"class":            "0x030000",
+			"vendor":           "0x8086",
+			"device":           "0x3e9b",
+			"subsystem_vendor": "0x1043",
+			"subsystem_device": "0x18f1",
+			"driver":           "i915",
+			"modalias":         "pci:v00008086d00003E9Bsv00001043sd000018F1bc03sc00i00",
+			"boot_vga":         "1",
+		},
+		"0000:01:00.0": {
+			"class":    "0x030200",
+			"vendor":   "0x10de",
+			"device":   "0x1f15",
+			"modalias": "pci:v000010DEd00001F15sv00001043sd000018F1bc03sc02i00",
+			"boot_vga": "0",
+		},
+		"0000:00:1f.3": {
+			"class":  "0x040300",
+			"vendor": "0x8086",
+			"device": "0xa348",
+			"driver": "snd_hda_intel",
+		},
+		"0001:00:00.0": {
+			"class":  "0x123400",
+			"vendor": "0x1234",
+			"device": "0x0001",
+		},
+	})
+
+	aliasFile := filepath.Join(t.TempDir(), "modules.alias")
+	if err := os.WriteFile(aliasFile, []byte(testModulesAlias), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := GetDevicesFromSysfs(sysfsDir, aliasFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*PCIDevice{
+		"00:02.0": {
+			BusId:             "00:02.0",
+			ClassName:         "VGA compatible controller",
+			ClassId:           "0300",
+			Id:                "8086:3e9b",
+			Subsystem:         "Device [1043:18f1]",
+			SubsystemId:       "1043:18f1",
+			KernelDriverInUse: "i915",
+			KernelModules:     []string{"i915"},
+			Modalias:          "pci:v00008086d00003E9Bsv00001043sd000018F1bc03sc00i00",
+			BootVGA:           true,
+		},
+		"01:00.0": {
+			BusId:         "01:00.0",
+			ClassName:     "3D controller",
+			ClassId:       "0302",
+			Id:            "10de:1f15",
+			KernelModules: []string{"nvidia", "nouveau"},
+			Modalias:      "pci:v000010DEd00001F15sv00001043sd000018F1bc03sc02i00",
+		},
+		"00:1f.3": {
+			BusId:             "00:1f.3",
+			ClassName:         "Audio device",
+			ClassId:           "0403",
+			Id:                "8086:a348",
+			KernelDriverInUse: "snd_hda_intel",
+			KernelModules:     []string{},
+		},
+		"0001:00:00.0": {
+			BusId:         "0001:00:00.0",
+			ClassName:     "Class 1234",
+			ClassId:       "1234",
+			Id:            "1234:0001",
+			KernelModules: []string{},
+		},
+	}
+
+	if len(*devices) != len(want) {
+		t.Fatalf("devices = %d, want %d", len(*devices), len(want))
+	}
+	for _, d := range *devices {
+		w, ok := want[d.BusId]
+		if !ok {
+			t.Errorf("unexpected device %s", d.BusId)
+			continue
+		}
+		if !reflect.DeepEqual(d, w) {
+			t.Errorf("device %s = %+v, want %+v", d.BusId, d, w)
+		}
+	}
+}
+
+func TestGetDevicesFromSysfsWithoutAliases(t *testing.T) {
+	sysfsDir := newFakeSysfs(t, map[string]map[string]string{
+		"0000:01:00.0": {
+			"class":    "0x030000",
+			"vendor":   "0x10de",
+			"device":   "0x1f15",
+			"modalias": "pci:v000010DEd00001F15sv00001043sd000018F1bc03sc00i00",
+		},
+	})
+
+	for _, aliasFile := range []string{"", filepath.Join(sysfsDir, "missing.alias")} {
+		devices, err := GetDevicesFromSysfs(sysfsDir, aliasFile)
+		if err != nil {
+			t.Fatalf("alias file %q: %s", aliasFile, err.Error())
+		}
+		if len(*devices) != 1 || len((*devices)[0].KernelModules) != 0 {
+			t.Errorf("alias file %q: devices %+v", aliasFile, *devices)
+		}
+	}
+}
+
+func TestGetDevicesFromSysfsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs map[string]string
+	}{
+		{"missing class", map[string]string{"vendor": "0x10de", "device": "0x1f15"}},
+		{"missing vendor", map[string]string{"class": "0x030000", "device": "0x1f15"}},
+		{"missing device", map[string]string{"class": "0x030000", "vendor": "0x10de"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysfsDir := newFakeSysfs(t, map[string]map[string]string{
+				"0000:01:00.0": tt.attrs,
+			})
+			if _, err := GetDevicesFromSysfs(sysfsDir, ""); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+
+	if _, err := GetDevicesFromSysfs(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Errorf("expected error on missing sysfs directory")
+	}
+}
@@ -5,7 +5,8 @@ See AUTHORS and LICENSE for the license details and contributors.
 package analyzer
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"strings"
 
 	bmacaroni "github.com/macaroni-os/gpu-configurator/pkg/backend"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/macaroni-os/macaronictl/pkg/utils"
@@ -22,15 +24,24 @@ type Analyzer struct {
 	Backend bmacaroni.SystemBackend
 
 	System *specs.System
+
+	// The sysfs directory used to read the GPU topology.
+	SysfsDir string
+	// The pci.ids file used to resolve the GPU names.
+	PciIdsPath string
 }
 
-func NewAnalyzer(btype string) (*Analyzer, error) {
+func NewAnalyzer(general *specs.CGeneral) (*Analyzer, error) {
 	var err error
 
-	ans := &Analyzer{}
+	ans := &Analyzer{
+		SysfsDir:   general.GetSysfsDir(),
+		PciIdsPath: general.GetPciIdsPath(),
+	}
 	ans.System = specs.NewSystem()
 
-	ans.Backend, err = bmacaroni.NewBackend(btype)
+	ans.Backend, err = bmacaroni.NewBackend(general.GetBackendType(),
+		general.GetRootDir())
 	if err != nil {
 		return nil, err
 	}
@@ -43,22 +54,70 @@ func (a *Analyzer) GetBackend() bmacaroni.SystemBackend {
 	return a.Backend
 }
 
-func (a *Analyzer) readGbmLibs() error {
+// Return the path of the file under the root directory
+// configured in the backend.
+func (a *Analyzer) GetRealPath(p string) string {
+	return rootfs.RealPath(a.Backend.GetRootDir(), p)
+}
+
+// Read a file of the target system following the links
+// inside the root directory.
+func (a *Analyzer) ReadFile(p string) ([]byte, error) {
+	return rootfs.ReadFile(a.Backend.GetRootDir(), p)
+}
+
+// Register the *specs.ScanError returned by the backend as
+// diagnostics. The other errors are returned.
+func (a *Analyzer) addScanErrors(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	for _, e := range errs {
+		var scanErr *specs.ScanError
+		if !errors.As(e, &scanErr) {
+			return e
+		}
+		a.System.AddDiagnostic(scanErr.Path, scanErr.Kind, scanErr.Err)
+	}
+
+	return nil
+}
+
+// Check that the kernel modules of a driver slot are
+// of the same version of the slot.
+func (a *Analyzer) checkKernelModulesVersion(modules *[]*specs.KernelModule) {
+	for _, m := range *modules {
+		if m.DriverVersion != "" && m.Fields["version"] != m.DriverVersion {
+			a.System.AddDiagnostic(m.Path, specs.DiagnosticVersionMismatch,
+				fmt.Errorf("module version %s doesn't match the driver %s",
+					m.Fields["version"], m.DriverVersion))
+		}
+	}
+}
+
+func (a *Analyzer) readGbmLibs() {
 	var regexlib = regexp.MustCompile(`.so$|.so.disabled$`)
 
 	gbmlibdir := a.Backend.GetGBMLibDir()
 	if gbmlibdir == "" {
 		// POST: nothing to do.
-		return nil
+		return
 	}
 
-	if !utils.Exists(gbmlibdir) {
-		return nil
+	if !utils.Exists(a.GetRealPath(gbmlibdir)) {
+		return
 	}
 
-	dirEntries, err := os.ReadDir(gbmlibdir)
+	dirEntries, err := os.ReadDir(a.GetRealPath(gbmlibdir))
 	if err != nil {
-		return err
+		a.System.AddDiagnostic(gbmlibdir, specs.DiagnosticUnreadableDir, err)
+		return
 	}
 
 	for _, file := range dirEntries {
@@ -75,18 +134,21 @@ func (a *Analyzer) readGbmLibs() error {
 			LinkedFile: "",
 		}
 
-		path := filepath.Join(gbmlibdir, file.Name())
+		libpath := filepath.Join(gbmlibdir, file.Name())
+		path := a.GetRealPath(libpath)
 
 		// Check if the library is a link
 		finfo, err := os.Lstat(path)
 		if err != nil {
-			return err
+			a.System.AddDiagnostic(libpath, specs.DiagnosticUnreadableFile, err)
+			continue
 		}
 		if finfo.Mode()&os.ModeSymlink != 0 {
 			// Resolve link
 			linkedLink, err := os.Readlink(path)
 			if err != nil {
-				return err
+				a.System.AddDiagnostic(libpath, specs.DiagnosticUnreadableFile, err)
+				continue
 			}
 			lib.LinkedFile = linkedLink
 		}
@@ -98,8 +160,6 @@ func (a *Analyzer) readGbmLibs() error {
 
 		a.System.GbmLibraries = append(a.System.GbmLibraries, lib)
 	}
-
-	return nil
 }
 
 func (a *Analyzer) Read() error {
@@ -114,9 +174,11 @@ func (a *Analyzer) Read() error {
 	}
 
 	for _, dir := range dirs {
-		dirEntries, err := os.ReadDir(dir)
+		dirEntries, err := os.ReadDir(a.GetRealPath(dir))
 		if err != nil {
-			// TODO: Add warning
+			if !os.IsNotExist(err) {
+				a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+			}
 			continue
 		}
 
@@ -134,15 +196,18 @@ func (a *Analyzer) Read() error {
 				continue
 			}
 
-			content, err := os.ReadFile(path.Join(dir, file.Name()))
+			content, err := a.ReadFile(path.Join(dir, file.Name()))
 			if err != nil {
-				// TODO: Add warning
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticUnreadableFile, err)
 				continue
 			}
 
 			icdjson, err := specs.NewICDJson(content)
 			if err != nil {
-				return err
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticMalformedFile, err)
+				continue
 			}
 
 			jsonfile := specs.NewJsonFile(file.Name(), icdjson)
@@ -152,6 +217,11 @@ func (a *Analyzer) Read() error {
 
 	}
 
+	err = a.readEglVendorDirs()
+	if err != nil {
+		return err
+	}
+
 	// Read vulkan layers files
 	dirs, err = a.Backend.GetVulkanLayersDirs()
 	if err != nil {
@@ -159,9 +229,11 @@ func (a *Analyzer) Read() error {
 	}
 
 	for _, dir := range dirs {
-		dirEntries, err := os.ReadDir(dir)
+		dirEntries, err := os.ReadDir(a.GetRealPath(dir))
 		if err != nil {
-			// TODO: Add warning
+			if !os.IsNotExist(err) {
+				a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+			}
 			continue
 		}
 
@@ -179,15 +251,29 @@ func (a *Analyzer) Read() error {
 				continue
 			}
 
-			content, err := os.ReadFile(path.Join(dir, file.Name()))
+			content, err := a.ReadFile(path.Join(dir, file.Name()))
 			if err != nil {
-				// TODO: Add warning
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticUnreadableFile, err)
+				continue
+			}
+
+			manifest, err := specs.NewVulkanLayerManifest(content)
+			if err != nil {
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticMalformedFile, err)
 				continue
 			}
 
 			vulkanFile := specs.NewVulkanLayersFile(file.Name())
-			if err := json.Unmarshal(content, &vulkanFile.Content); err != nil {
-				return err
+			vulkanFile.Manifest = manifest
+			vulkanFile.Implicit = filepath.Base(dir) == "implicit_layer.d"
+
+			// POST: the layers not valid are reported but
+			//       they are available for the enable/disable.
+			if err := manifest.Validate(vulkanFile.Implicit); err != nil {
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticInvalidManifest, err)
 			}
 
 			vulkandir.Files[file.Name()] = vulkanFile
@@ -202,9 +288,11 @@ func (a *Analyzer) Read() error {
 	}
 
 	for _, dir := range dirs {
-		dirEntries, err := os.ReadDir(dir)
+		dirEntries, err := os.ReadDir(a.GetRealPath(dir))
 		if err != nil {
-			// TODO: Add warning
+			if !os.IsNotExist(err) {
+				a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+			}
 			continue
 		}
 
@@ -222,53 +310,75 @@ func (a *Analyzer) Read() error {
 				continue
 			}
 
-			content, err := os.ReadFile(path.Join(dir, file.Name()))
+			content, err := a.ReadFile(path.Join(dir, file.Name()))
 			if err != nil {
-				// TODO: Add warning
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticUnreadableFile, err)
 				continue
 			}
 
 			icdjson, err := specs.NewICDJson(content)
 			if err != nil {
-				return err
+				a.System.AddDiagnostic(path.Join(dir, file.Name()),
+					specs.DiagnosticMalformedFile, err)
+				continue
 			}
 
 			jsonfile := specs.NewJsonFile(file.Name(), icdjson)
+			jsonfile.Arch = a.getIcdArch(dir, jsonfile)
 			vulkandir.Files[file.Name()] = jsonfile
 		}
 
 	}
 
-	err = a.readGbmLibs()
+	a.readVulkanLoaderSettings()
+	a.readOpenCLVendors()
+
+	err = a.readVideoDrivers()
 	if err != nil {
 		return err
 	}
 
+	a.readDrirc()
+
+	a.readGbmLibs()
+
 	// Retrieve NVIDIA drivers installed
 	nvDrivers, err := a.Backend.GetNVIDIADrivers()
+	err = a.addScanErrors(err)
 	if err != nil {
 		return err
 	}
 
 	a.System.Nvidia = specs.NewNVIDIASetup()
 	a.System.Nvidia.Drivers = *nvDrivers
-	versionActive, err := a.Backend.GetNVIDIADriverActive()
+
+	a.System.Nvidia.VersionConfigured, err = a.Backend.GetNVIDIADriverConfigured()
 	if err != nil {
 		return err
 	}
-	a.System.Nvidia.SetVersion(versionActive)
+	// POST: the version active is the version configured only if installed.
+	if a.System.Nvidia.HasVersion(a.System.Nvidia.VersionConfigured) {
+		a.System.Nvidia.SetVersion(a.System.Nvidia.VersionConfigured)
+	}
 
 	nvidiaKModules, err := a.Backend.GetNVIDIAKernelModules(false)
+	err = a.addScanErrors(err)
 	if err != nil {
 		return err
 	}
 	a.System.Nvidia.KModuleAvailable = *nvidiaKModules
+	a.checkKernelModulesVersion(nvidiaKModules)
 
 	nvidiaOpenKModules, err := a.Backend.GetNVIDIAKernelModules(true)
+	err = a.addScanErrors(err)
 	if err != nil {
 		return err
 	}
+	a.checkKernelModulesVersion(nvidiaOpenKModules)
 	a.System.Nvidia.KOpenModuleAvailable = *nvidiaOpenKModules
 
+	a.readGPUTopology()
+
 	return nil
 }
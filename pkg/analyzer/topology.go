@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer/pci"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Read the GPU topology from the PCI devices and the DRM
+// devices of sysfs. The sysfs is always related to the running
+// system and not to the root directory.
+func (a *Analyzer) readGPUTopology() {
+	sysfsDir := a.SysfsDir
+	if sysfsDir == "" {
+		sysfsDir = pci.DefaultSysfsDir
+	}
+
+	topology := specs.NewGPUTopology()
+	a.System.Topology = topology
+
+	devices, err := pci.GetDevices(sysfsDir, "", a.PciIdsPath)
+	err = a.addScanErrors(err)
+	if err != nil {
+		// POST: the topology is empty and the GPUs are not configured.
+		a.System.AddDiagnostic(filepath.Join(sysfsDir, pci.SysfsPciDevicesDir),
+			specs.DiagnosticUnreadableDir, err)
+		return
+	}
+
+	for _, gpu := range *devices.GetGPUDevices() {
+		kernelDriver := gpu.KernelDriverInUse
+		if kernelDriver == "" && len(gpu.KernelModules) > 0 {
+			// POST: the driver is not bound. I use the first
+			//       kernel module available like lspci -k.
+			kernelDriver = gpu.KernelModules[0]
+		}
+		topology.GPUs = append(topology.GPUs, &specs.GPUNode{
+			BusId:        gpu.BusId,
+			Id:           gpu.Id,
+			Name:         gpu.Name,
+			Vendor:       gpu.Vendor,
+			Role:         gpu.GPURole,
+			KernelDriver: kernelDriver,
+			BootVGA:      gpu.BootVGA,
+			Outputs:      []*specs.DRMOutput{},
+		})
+	}
+
+	drmDir := filepath.Join(sysfsDir, "class/drm")
+	err = a.readDRMDevices(drmDir, topology)
+	if err != nil {
+		a.System.AddDiagnostic(drmDir, specs.DiagnosticUnreadableDir, err)
+	}
+
+	topology.Evaluate()
+}
+
+// Map the DRM cards, render nodes and connectors to the GPUs.
+// Example of entries under /sys/class/drm:
+// card0  card0-eDP-1  card1  card1-HDMI-A-1  renderD128  renderD129  version
+func (a *Analyzer) readDRMDevices(drmDir string, topology *specs.GPUTopology) error {
+	entries, err := os.ReadDir(drmDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// POST: DRM not available (ex. container)
+			return nil
+		}
+		return err
+	}
+
+	cards := make(map[string]*specs.GPUNode)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, "-") ||
+			!(strings.HasPrefix(name, "card") || strings.HasPrefix(name, "renderD")) {
+			continue
+		}
+
+		device, err := os.Readlink(filepath.Join(drmDir, name, "device"))
+		if err != nil {
+			a.System.AddDiagnostic(filepath.Join(drmDir, name),
+				specs.DiagnosticUnreadableFile, err)
+			continue
+		}
+
+		gpu := topology.GetGPU(
+			strings.TrimPrefix(filepath.Base(device), "0000:"))
+		if gpu == nil {
+			continue
+		}
+
+		if strings.HasPrefix(name, "card") {
+			gpu.Card = name
+			cards[name] = gpu
+		} else {
+			gpu.RenderNode = name
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "card") || !strings.Contains(name, "-") {
+			continue
+		}
+
+		words := strings.SplitN(name, "-", 2)
+		gpu, ok := cards[words[0]]
+		if !ok {
+			continue
+		}
+
+		output := &specs.DRMOutput{
+			Name: words[1],
+		}
+		if data, err := os.ReadFile(filepath.Join(drmDir, name, "status")); err == nil {
+			output.Status = strings.TrimSpace(string(data))
+		}
+		if data, err := os.ReadFile(filepath.Join(drmDir, name, "enabled")); err == nil {
+			output.Enabled = strings.TrimSpace(string(data)) == "enabled"
+		}
+
+		gpu.Outputs = append(gpu.Outputs, output)
+	}
+
+	for _, gpu := range topology.GPUs {
+		sort.Slice(gpu.Outputs, func(i, j int) bool {
+			return gpu.Outputs[i].Name < gpu.Outputs[j].Name
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package analyzer
+
+import (
+	"os"
+	"path"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Read the VA-API (*_drv_video.so) and VDPAU (libvdpau_*.so)
+// drivers installed.
+func (a *Analyzer) readVideoDrivers() error {
+	setup := specs.NewVideoSetup()
+
+	for _, t := range []struct {
+		Dirs    func() ([]string, error)
+		GetName func(string) string
+		Add     func(string, string)
+	}{
+		{a.Backend.GetVADriversDirs, specs.GetVADriverName, setup.AddVADriver},
+		{a.Backend.GetVDPAUDriversDirs, specs.GetVDPAUDriverName, setup.AddVDPAUDriver},
+	} {
+		dirs, err := t.Dirs()
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range dirs {
+			entries, err := os.ReadDir(a.GetRealPath(dir))
+			if err != nil {
+				if !os.IsNotExist(err) {
+					a.System.AddDiagnostic(dir, specs.DiagnosticUnreadableDir, err)
+				}
+				continue
+			}
+
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				if name := t.GetName(e.Name()); name != "" {
+					t.Add(name, path.Join(dir, e.Name()))
+				}
+			}
+		}
+	}
+
+	a.System.Video = setup
+
+	return nil
+}
@@ -8,14 +8,25 @@ import (
 	"fmt"
 
 	bmacaroni "github.com/macaroni-os/gpu-configurator/pkg/backend/macaroni"
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 )
 
 type SystemBackend interface {
 	GetName() string
+	GetRootDir() string
+	GetExecutor() *executor.Executor
+	SetExecutor(*executor.Executor)
 	GetEglExternalPlatformsDirs() ([]string, error)
+	GetEglVendorDirs() ([]string, error)
 	GetVulkanLayersDirs() ([]string, error)
 	GetVulkanICDDirs() ([]string, error)
+	GetVulkanLoaderSettingsFile() string
+	GetOpenCLVendorsDir() string
+	GetVADriversDirs() ([]string, error)
+	GetVDPAUDriversDirs() ([]string, error)
+	GetDrircFile() string
+	GetDrircConfDir() string
 
 	// GBM stuff
 	GetGBMLibDir() string
@@ -24,21 +35,24 @@ type SystemBackend interface {
 	// NVIDIA gpu functions
 	GetNVIDIAEglWaylandLibDir() string
 	GetNVIDIAEglGbmLibDir() string
+	// The unreadable paths are returned as *specs.ScanError
+	// with the partial result.
 	GetNVIDIADrivers() (*[]*specs.NVIDIADriver, error)
 	GetNVIDIAKernelModules(open bool) (*[]*specs.KernelModule, error)
 	GetNVIDIADriverActive() (string, error)
+	GetNVIDIADriverConfigured() (string, error)
 	SetNVIDIAVersion(*specs.NVIDIASetup, string) error
-	PurgeNVIDIADriver(*specs.NVIDIASetup) error
+	PurgeNVIDIADriver(*specs.NVIDIASetup, *specs.NVIDIAPurgeOpts) error
 }
 
-func NewBackend(btype string) (SystemBackend, error) {
+func NewBackend(btype, rootDir string) (SystemBackend, error) {
 	var ans SystemBackend
 	var err error
 	switch btype {
 	case "macaroni":
-		ans, err = bmacaroni.NewMacaroniBackend()
+		ans, err = bmacaroni.NewMacaroniBackend(rootDir)
 	case "funtoo":
-		ans, err = bmacaroni.NewMacaroniBackend()
+		ans, err = bmacaroni.NewMacaroniBackend(rootDir)
 		if ans != nil {
 			(ans.(*bmacaroni.MacaroniBackend)).Name = "funtoo"
 		}
@@ -6,12 +6,15 @@ package macaroni
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
 	"github.com/macaroni-os/gpu-configurator/pkg/kernel"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 
 	"github.com/macaroni-os/macaronictl/pkg/utils"
@@ -23,25 +26,60 @@ const (
 )
 
 type MacaroniBackend struct {
-	Name string
+	Name     string
+	RootDir  string
+	Executor *executor.Executor
 }
 
-func NewMacaroniBackend() (*MacaroniBackend, error) {
-	return &MacaroniBackend{
-		Name: "macaroni",
-	}, nil
+func NewMacaroniBackend(rootDir string) (*MacaroniBackend, error) {
+	ans := &MacaroniBackend{
+		Name:    "macaroni",
+		RootDir: rootDir,
+	}
+	ans.SetExecutor(executor.NewExecutor(rootDir, specs.DefaultStateDir, false))
+	return ans, nil
 }
 
 func (b *MacaroniBackend) GetName() string {
 	return b.Name
 }
 
+func (b *MacaroniBackend) GetRootDir() string {
+	return b.RootDir
+}
+
+func (b *MacaroniBackend) GetExecutor() *executor.Executor {
+	return b.Executor
+}
+
+func (b *MacaroniBackend) SetExecutor(e *executor.Executor) {
+	// POST: the links to another driver slot are replaced.
+	e.AddManagedPrefix(NvidiaPrefixDriverPath)
+	b.Executor = e
+}
+
+// Return the path of the file under the root directory.
+// All the paths managed by the backend are related to the
+// target system and are converted only on access.
+func (b *MacaroniBackend) realPath(p string) string {
+	return rootfs.RealPath(b.RootDir, p)
+}
+
 func (b *MacaroniBackend) GetEglExternalPlatformsDirs() ([]string, error) {
 	return []string{
 		"/usr/share/egl/egl_external_platform.d",
 	}, nil
 }
 
+// The directories of the glvnd EGL vendors in the order
+// used by libEGL.
+func (b *MacaroniBackend) GetEglVendorDirs() ([]string, error) {
+	return []string{
+		"/etc/glvnd/egl_vendor.d",
+		"/usr/share/glvnd/egl_vendor.d",
+	}, nil
+}
+
 func (b *MacaroniBackend) GetVulkanLayersDirs() ([]string, error) {
 	return []string{
 		"/usr/share/vulkan/explicit_layer.d",
@@ -56,6 +94,29 @@ func (b *MacaroniBackend) GetVulkanICDDirs() ([]string, error) {
 	}, nil
 }
 
+func (b *MacaroniBackend) GetVulkanLoaderSettingsFile() string {
+	return "/etc/vulkan/loader_settings.d/vk_loader_settings.json"
+}
+
+func (b *MacaroniBackend) GetOpenCLVendorsDir() string { return "/etc/OpenCL/vendors" }
+
+func (b *MacaroniBackend) GetVADriversDirs() ([]string, error) {
+	return []string{
+		"/usr/lib64/dri",
+		"/usr/lib/dri",
+	}, nil
+}
+
+func (b *MacaroniBackend) GetVDPAUDriversDirs() ([]string, error) {
+	return []string{
+		"/usr/lib64/vdpau",
+		"/usr/lib/vdpau",
+	}, nil
+}
+
+func (b *MacaroniBackend) GetDrircFile() string    { return "/etc/drirc" }
+func (b *MacaroniBackend) GetDrircConfDir() string { return "/usr/share/drirc.d" }
+
 func (b *MacaroniBackend) GetEnvironmentDir() string { return "/etc/env.d" }
 
 func (b *MacaroniBackend) GetGBMLibDir() string { return "/usr/lib64/gbm" }
@@ -63,23 +124,20 @@ func (b *MacaroniBackend) GetGBMLibDir() string { return "/usr/lib64/gbm" }
 func (b *MacaroniBackend) GetNVIDIAEglWaylandLibDir() string { return "/usr/lib64" }
 func (b *MacaroniBackend) GetNVIDIAEglGbmLibDir() string     { return "/usr/lib64" }
 
-func (b *MacaroniBackend) GetNVIDIADriverActive() (string, error) {
+// Return the NVIDIA_DRIVER_VERSION of the /etc/env.d/09nvidia file
+// also if the version is not installed.
+func (b *MacaroniBackend) GetNVIDIADriverConfigured() (string, error) {
 	ans := ""
 
-	drivers, err := b.GetNVIDIADrivers()
-	if err != nil {
-		return "", err
-	}
-
 	envNvidia := filepath.Join(b.GetEnvironmentDir(), NvidiaEnvFileName)
 
-	if !utils.Exists(envNvidia) {
+	if !utils.Exists(b.realPath(envNvidia)) {
 		// POST: there isn't an active nvidia driver.
 		return "", nil
 	}
 
 	// Read file /etc/env.d/09nvidia and get NVIDIA_DRIVER_VERSION env
-	f, err := os.Open(envNvidia)
+	f, err := os.Open(b.realPath(envNvidia))
 	if err != nil {
 		return "", fmt.Errorf("Error on open file %s: %s", envNvidia, err.Error())
 	}
@@ -106,6 +164,20 @@ func (b *MacaroniBackend) GetNVIDIADriverActive() (string, error) {
 			envNvidia, err.Error())
 	}
 
+	return ans, nil
+}
+
+func (b *MacaroniBackend) GetNVIDIADriverActive() (string, error) {
+	drivers, err := b.GetNVIDIADrivers()
+	if err != nil {
+		return "", err
+	}
+
+	ans, err := b.GetNVIDIADriverConfigured()
+	if err != nil {
+		return "", err
+	}
+
 	// Check if the version is present between
 	// drivers else ignore it.
 	hasVersion := false
@@ -121,6 +193,9 @@ func (b *MacaroniBackend) GetNVIDIADriverActive() (string, error) {
 	return "", nil
 }
 
+// Return the NVIDIA kernel modules available. The paths not
+// readable are returned as *specs.ScanError joined together
+// with the modules found.
 func (b *MacaroniBackend) GetNVIDIAKernelModules(open bool) (*[]*specs.KernelModule, error) {
 	modulePath := "/lib/modules/nvidia"
 	if open {
@@ -128,14 +203,17 @@ func (b *MacaroniBackend) GetNVIDIAKernelModules(open bool) (*[]*specs.KernelMod
 	}
 
 	ans := []*specs.KernelModule{}
+	errs := []error{}
 
-	if !utils.Exists(modulePath) {
+	if !utils.Exists(b.realPath(modulePath)) {
 		return &ans, nil
 	}
 
-	dirEntries, err := os.ReadDir(modulePath)
+	dirEntries, err := os.ReadDir(b.realPath(modulePath))
 	if err != nil {
-		return nil, err
+		return &ans, &specs.ScanError{
+			Path: modulePath, Kind: specs.DiagnosticUnreadableDir, Err: err,
+		}
 	}
 
 	// Path used by slotted package follow this pattern
@@ -149,9 +227,12 @@ func (b *MacaroniBackend) GetNVIDIAKernelModules(open bool) (*[]*specs.KernelMod
 		nvidiaVersion := file.Name()
 
 		nvidiaKVersionPath := filepath.Join(modulePath, nvidiaVersion)
-		kernelDirs, err := os.ReadDir(nvidiaKVersionPath)
+		kernelDirs, err := os.ReadDir(b.realPath(nvidiaKVersionPath))
 		if err != nil {
-			return nil, err
+			errs = append(errs, &specs.ScanError{
+				Path: nvidiaKVersionPath, Kind: specs.DiagnosticUnreadableDir, Err: err,
+			})
+			continue
 		}
 
 		for _, kf := range kernelDirs {
@@ -160,23 +241,26 @@ func (b *MacaroniBackend) GetNVIDIAKernelModules(open bool) (*[]*specs.KernelMod
 			nvidiaKmoduleDir := filepath.Join(
 				nvidiaKVersionPath, kVersion, "video")
 			nvidiaKModule := filepath.Join(nvidiaKmoduleDir, "nvidia.ko.zst")
-
-			kversion := ""
-			if utils.Exists(nvidiaKModule) {
-				kversion, _ = kernel.ModinfoField(nvidiaKModule, "version")
-			} else {
-				nvidiaKModule := filepath.Join(nvidiaKmoduleDir, "nvidia.ko")
-				if utils.Exists(nvidiaKModule) {
-					kversion, _ = kernel.ModinfoField(nvidiaKModule, "version")
+			if !utils.Exists(b.realPath(nvidiaKModule)) {
+				nvidiaKModule = filepath.Join(nvidiaKmoduleDir, "nvidia.ko")
+				if !utils.Exists(b.realPath(nvidiaKModule)) {
+					continue
 				}
 			}
 
-			// TODO: if nvidiaKModule != nvidiaVersion add Warning.
+			kversion, err := kernel.ModinfoField(b.realPath(nvidiaKModule), "version")
+			if err != nil {
+				errs = append(errs, &specs.ScanError{
+					Path: nvidiaKModule, Kind: specs.DiagnosticUnreadableFile, Err: err,
+				})
+				continue
+			}
 
 			if kversion != "" {
 				lp := &specs.KernelModule{
 					Path:          nvidiaKModule,
 					KernelVersion: kVersion,
+					DriverVersion: nvidiaVersion,
 					Name:          "nvidia",
 					Fields:        make(map[string]string, 0),
 				}
@@ -187,22 +271,26 @@ func (b *MacaroniBackend) GetNVIDIAKernelModules(open bool) (*[]*specs.KernelMod
 
 	}
 
-	return &ans, nil
+	return &ans, errors.Join(errs...)
 }
 
+// Return the NVIDIA drivers installed. An unreadable path
+// is returned as *specs.ScanError together with the drivers found.
 func (b *MacaroniBackend) GetNVIDIADrivers() (*[]*specs.NVIDIADriver, error) {
 	ans := []*specs.NVIDIADriver{}
 
 	dirPrefix := "nvidia-drivers"
 
-	if !utils.Exists(NvidiaPrefixDriverPath) {
+	if !utils.Exists(b.realPath(NvidiaPrefixDriverPath)) {
 		// POST: no nvidia drivers available
 		return &ans, nil
 	}
 
-	dirEntries, err := os.ReadDir(NvidiaPrefixDriverPath)
+	dirEntries, err := os.ReadDir(b.realPath(NvidiaPrefixDriverPath))
 	if err != nil {
-		return nil, err
+		return &ans, &specs.ScanError{
+			Path: NvidiaPrefixDriverPath, Kind: specs.DiagnosticUnreadableDir, Err: err,
+		}
 	}
 
 	// Retrieve current kernel version
@@ -226,25 +314,29 @@ func (b *MacaroniBackend) GetNVIDIADrivers() (*[]*specs.NVIDIADriver, error) {
 			Version: version,
 		}
 
-		nvidiaKmoduleDir := filepath.Join(
-			"/lib/modules/", kVersion, "video")
-		nvidiaKModule := filepath.Join(nvidiaKmoduleDir, "nvidia.ko.zst")
-
-		kversion := ""
-		if utils.Exists(nvidiaKModule) {
-			kversion, _ = kernel.ModinfoField(nvidiaKModule, "version")
-		} else {
-			nvidiaKModule := filepath.Join(nvidiaKmoduleDir, "nvidia.ko")
-			if utils.Exists(nvidiaKModule) {
-				kversion, _ = kernel.ModinfoField(nvidiaKModule, "version")
+		ans = append(ans, driverDir)
+	}
+
+	nvidiaKmoduleDir := filepath.Join(
+		"/lib/modules/", kVersion, "video")
+	nvidiaKModule := filepath.Join(nvidiaKmoduleDir, "nvidia.ko.zst")
+	if !utils.Exists(b.realPath(nvidiaKModule)) {
+		nvidiaKModule = filepath.Join(nvidiaKmoduleDir, "nvidia.ko")
+	}
+
+	if len(ans) > 0 && utils.Exists(b.realPath(nvidiaKModule)) {
+		kversion, err := kernel.ModinfoField(b.realPath(nvidiaKModule), "version")
+		if err != nil {
+			return &ans, &specs.ScanError{
+				Path: nvidiaKModule, Kind: specs.DiagnosticUnreadableFile, Err: err,
 			}
 		}
 
-		if version == kversion {
-			driverDir.WithKernelModules = true
+		for _, driverDir := range ans {
+			if driverDir.Version == kversion {
+				driverDir.WithKernelModules = true
+			}
 		}
-
-		ans = append(ans, driverDir)
 	}
 
 	return &ans, nil
@@ -5,12 +5,12 @@ See AUTHORS and LICENSE for the license details and contributors.
 package macaroni
 
 import (
+	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
 	"github.com/macaroni-os/macaronictl/pkg/utils"
 )
@@ -48,102 +48,127 @@ var (
 	}
 )
 
+type nvidiaStep struct {
+	Description string
+	Run         func(plan *specs.OperationsPlan, v string) error
+}
+
 func (b *MacaroniBackend) SetNVIDIAVersion(setup *specs.NVIDIASetup, v string) error {
+	if !setup.HasVersion(v) {
+		return fmt.Errorf("NVIDIA driver version %s not available", v)
+	}
+
 	// NOTE: I want to reset the links and setup every time. This permits
 	//       to fix things also when there are bugs on gpu-configurator with
-	//       previous versions.
+	//       previous versions. The executor reconciles every artifact:
+	//       correct links and files are left untouched, links to another
+	//       driver slot are replaced and regular files are never clobbered.
 
 	// Configure NVIDIA version needs:
+	steps := []nvidiaStep{
+		// 1. create /etc/env.d/09nvidia file
+		{"Create /etc/env.d/09nvidia file", b.createNvidiaEnvfile},
+		// 2. create links to /usr/bin
+		{"Create /usr/bin links", b.createNvidiaBins},
+		// 3. create files under /etc/init.d
+		{"Create /etc/init.d scripts", b.createInitd},
+		// 4. create files under /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d
+		{"Create /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d files", b.createEtc},
+		// 5. create links for .desktop
+		{"Create .desktop file link", b.createDesktopFile},
+		// 6. create links for .png
+		{"Create .png file link", b.createPngFile},
+		// 7. create links under /usr/share
+		{"Create /usr/share links", b.createUsrShare},
+		// 8. create links under /usr/lib64/xorg/modules/drivers/
+		{"Create Xorg modules driver link", b.createXorgModulesDriver},
+		// 9. create links under /usr/lib64/xorgs/modules/extensions/
+		{"Create Xorg modules extension link", b.createXorgModulesExtension},
+		// 10. create /etc/conf.d/* (if doesn't exist)
+		{"Create /etc/conf.d files", b.createConfdIfNotPresent},
+		// 11. create /etc/ld.so.conf.d/07-nvidia.conf
+		{"Create /etc/ld.so.conf.d file", b.createLdsoconfdFile},
+		// 12. create hardlink to nvidia kernel driver.
+	}
+
+	return b.runNvidiaSteps(steps, v)
+}
 
-	// 1. create /etc/env.d/09nvidia file
-	err := b.createNvidiaEnvfile(v)
-	if err != nil {
-		return err
-	}
-
-	// 2. create links to /usr/bin
-	err = b.createNvidiaBins(v)
-	if err != nil {
-		return err
-	}
-
-	// 3. create files under /etc/init.d
-	err = b.createInitd(v)
-	if err != nil {
-		return err
-	}
-
-	// 4. create files under /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d
-	err = b.createEtc(v)
-	if err != nil {
-		return err
-	}
+// Run the steps in order. Every step prepares the operations
+// that are applied by the executor before the next step.
+// On failure all the operations of the transaction are reverted.
+func (b *MacaroniBackend) runNvidiaSteps(steps []nvidiaStep, v string) error {
+	log := logger.GetDefaultLogger()
+	dryRun := b.Executor.IsDryRun()
 
-	// 5. create links for .desktop
-	err = b.createDesktopFile(v)
-	if err != nil {
-		return err
-	}
+	b.Executor.SetVersion(v)
 
-	// 6. create links for .png
-	err = b.createPngFile(v)
-	if err != nil {
-		return err
+	// POST: if the transaction is started by the caller
+	//       the commit is delegated to the caller.
+	ownTransaction := !b.Executor.InTransaction()
+	if ownTransaction {
+		err := b.Executor.Begin()
+		if err != nil {
+			return err
+		}
 	}
 
-	// 7. create links under /usr/share
-	err = b.createUsrShare(v)
-	if err != nil {
-		return err
-	}
+	for idx, step := range steps {
+		plan := specs.NewOperationsPlan()
 
-	// 8. create links under /usr/lib64/xorg/modules/drivers/
-	err = b.createXorgModulesDriver(v)
-	if err != nil {
-		return err
-	}
+		err := step.Run(plan, v)
+		if err == nil {
+			err = b.Executor.Apply(plan)
+		}
+		if err != nil {
+			log.Error(fmt.Sprintf("[%2d/%d] %s: failed",
+				idx+1, len(steps), step.Description))
 
-	// 9. create links under /usr/lib64/xorgs/modules/extensions/
-	err = b.createXorgModulesExtension(v)
-	if err != nil {
-		return err
-	}
+			if !dryRun {
+				log.Warning("Rolling back the applied operations...")
+			}
+			rerr := b.Executor.Rollback()
+			if rerr != nil {
+				return fmt.Errorf("%s. Rollback failed: %s",
+					err.Error(), rerr.Error())
+			}
+			return err
+		}
 
-	// 10. create /etc/conf.d/* (if doesn't exist)
-	err = b.createConfdIfNotPresent(v)
-	if err != nil {
-		return err
+		if !dryRun {
+			log.InfoC(fmt.Sprintf("[%2d/%d] %s: done",
+				idx+1, len(steps), step.Description))
+		}
 	}
 
-	// 11. create /etc/ld.so.conf.d/07-nvidia.conf
-	err = b.createLdsoconfdFile(v)
-	if err != nil {
-		return err
+	if ownTransaction {
+		err := b.Executor.Commit()
+		if err != nil {
+			log.Warning("Error on commit operations:", err.Error())
+		}
 	}
 
-	// 12. create hardlink to nvidia kernel driver.
-
 	return nil
 }
 
-func (b *MacaroniBackend) createLdsoconfdFile(v string) error {
+func (b *MacaroniBackend) createLdsoconfdFile(plan *specs.OperationsPlan, v string) error {
 	targetDir := "/etc/ld.so.conf.d"
 	targetFile := filepath.Join(targetDir,
 		"07-nvidia",
 	)
-	err := os.WriteFile(targetFile, []byte(
-		fmt.Sprintf(`/opt/nvidia/nvidia-drivers-%s/lib64
-`,
-			v)), 0644)
 
-	if err != nil {
-		return fmt.Errorf("Error on write ld.so.conf.d file %s: %s",
-			targetFile, err.Error())
+	if !utils.Exists(b.realPath(targetDir)) {
+		plan.Mkdir(targetDir)
 	}
+
+	plan.WriteFile(targetFile,
+		fmt.Sprintf(`%s/lib64
+`, b.getDriverDir(v)), 0644)
+
 	return nil
 }
 
-func (b *MacaroniBackend) createConfdIfNotPresent(v string) error {
+func (b *MacaroniBackend) createConfdIfNotPresent(plan *specs.OperationsPlan, v string) error {
 	driverPath := b.getDriverDir(v)
 
 	targetDir := "/etc/conf.d"
@@ -159,39 +184,19 @@ func (b *MacaroniBackend) createConfdIfNotPresent(v string) error {
 	//       very few options. It doesn't make sense to manage
 	//       CONFIG_PROTECT. I just avoid to update it if it's
 	//       already present.
-	if utils.Exists(targetFile) {
+	if !utils.Exists(b.realPath(targetFile)) && utils.Exists(b.realPath(origPath)) {
 
-		if !utils.Exists(targetDir) {
-			err := os.MkdirAll(targetDir, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		}
-
-		// Open destination file (truncate it if exists)
-		tfd, err := os.OpenFile(targetFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
+		if !utils.Exists(b.realPath(targetDir)) {
+			plan.Mkdir(targetDir)
 		}
-		defer tfd.Close()
 
-		// Open source file
-		sourcefd, err := os.Open(origPath)
-		if err != nil {
-			return err
-		}
-		defer sourcefd.Close()
-
-		_, err = io.Copy(tfd, sourcefd)
-		if err != nil {
-			return err
-		}
+		plan.CopyFile(origPath, targetFile)
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) createXorgModulesExtension(v string) error {
+func (b *MacaroniBackend) createXorgModulesExtension(plan *specs.OperationsPlan, v string) error {
 	driverPath := b.getDriverDir(v)
 
 	targetPath := "/usr/lib64/xorg/modules/extensions"
@@ -200,32 +205,22 @@ func (b *MacaroniBackend) createXorgModulesExtension(v string) error {
 		"libglxserver_nvidia.so",
 	)
 
-	if utils.Exists(targetPath) {
-
-		if !utils.Exists(targetPath) {
-			err := os.MkdirAll(targetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		}
-
+	if utils.Exists(b.realPath(targetPath)) {
 		targetFile := filepath.Join(
 			targetPath, "libglxserver_nvidia.so",
 		)
 
-		err := os.Symlink(origPath, targetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				origPath, targetFile,
-				err.Error())
-		}
+		plan.CreateLink(targetFile, origPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(targetPath, specs.DiagnosticSkippedArtifact,
+			errors.New("directory not available, libglxserver_nvidia.so not linked"))
+	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) createXorgModulesDriver(v string) error {
+func (b *MacaroniBackend) createXorgModulesDriver(plan *specs.OperationsPlan, v string) error {
 	driverPath := b.getDriverDir(v)
 
 	targetPath := "/usr/lib64/xorg/modules/drivers"
@@ -234,32 +229,22 @@ func (b *MacaroniBackend) createXorgModulesDriver(v string) error {
 		"nvidia_drv.so",
 	)
 
-	if utils.Exists(targetPath) {
-
-		if !utils.Exists(targetPath) {
-			err := os.MkdirAll(targetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		}
-
+	if utils.Exists(b.realPath(targetPath)) {
 		targetFile := filepath.Join(
 			targetPath, "nvidia_drv.so",
 		)
 
-		err := os.Symlink(origPath, targetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				origPath, targetFile,
-				err.Error())
-		}
+		plan.CreateLink(targetFile, origPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(targetPath, specs.DiagnosticSkippedArtifact,
+			errors.New("directory not available, nvidia_drv.so not linked"))
+	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) createUsrShare(v string) error {
+func (b *MacaroniBackend) createUsrShare(plan *specs.OperationsPlan, v string) error {
 	driverPath := b.getDriverDir(v)
 
 	// Create /usr/share/vulkan/icd.d/nvidia_icd.json file
@@ -269,28 +254,18 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		"nvidia_icd.json",
 	)
 
-	if utils.Exists(nvidiaVulkanIcdTargetPath) {
-
-		if !utils.Exists(nvidiaVulkanIcdTargetPath) {
-			err := os.MkdirAll(nvidiaVulkanIcdTargetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		}
-
+	if utils.Exists(b.realPath(nvidiaVulkanIcdTargetPath)) {
 		nvidiaVulkanIcdTargetFile := filepath.Join(
 			nvidiaVulkanIcdTargetPath,
 			"nvidia_icd.json",
 		)
 
-		err := os.Symlink(nvidiaVulkanIcdOrigPath, nvidiaVulkanIcdTargetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				nvidiaVulkanIcdOrigPath, nvidiaVulkanIcdTargetFile,
-				err.Error())
-		}
+		plan.CreateLink(nvidiaVulkanIcdTargetFile, nvidiaVulkanIcdOrigPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(nvidiaVulkanIcdTargetPath, specs.DiagnosticSkippedArtifact,
+			errors.New("directory not available, nvidia_icd.json not linked"))
+	}
 
 	// Create /usr/share/vulkan/implicit_layer.d/nvidia_layers.json
 	nvidiaVulkanLayerTargetPath := "/usr/share/vulkan/implicit_layer.d"
@@ -299,39 +274,26 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		"nvidia_layers.json",
 	)
 
-	if utils.Exists(nvidiaVulkanLayerTargetPath) {
-
-		if !utils.Exists(nvidiaVulkanLayerTargetPath) {
-			err := os.MkdirAll(nvidiaVulkanLayerTargetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		}
-
+	if utils.Exists(b.realPath(nvidiaVulkanLayerTargetPath)) {
 		nvidiaVulkanLayerTargetFile := filepath.Join(
 			nvidiaVulkanLayerTargetPath,
 			"nvidia_layers.json",
 		)
 
-		err := os.Symlink(nvidiaVulkanLayerOrigPath, nvidiaVulkanLayerTargetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				nvidiaVulkanLayerOrigPath, nvidiaVulkanLayerTargetFile,
-				err.Error())
-		}
+		plan.CreateLink(nvidiaVulkanLayerTargetFile, nvidiaVulkanLayerOrigPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(nvidiaVulkanLayerTargetPath, specs.DiagnosticSkippedArtifact,
+			errors.New("directory not available, nvidia_layers.json not linked"))
+	}
 
 	// Create /usr/share/nvidia/ files
 	shareNvidiaTargetPath := "/usr/share/nvidia"
 	shareNvidiaOriginPath := filepath.Join(
 		driverPath, shareNvidiaTargetPath,
 	)
-	if !utils.Exists(shareNvidiaTargetPath) {
-		err := os.MkdirAll(shareNvidiaTargetPath, os.ModePerm)
-		if err != nil {
-			return err
-		}
+	if !utils.Exists(b.realPath(shareNvidiaTargetPath)) {
+		plan.Mkdir(shareNvidiaTargetPath)
 	}
 
 	for _, f := range shareNvidiaFiles {
@@ -340,11 +302,7 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		targetfile := filepath.Join(
 			shareNvidiaTargetPath, f)
 
-		err := os.Symlink(origfile, targetfile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				origfile, targetfile, err.Error())
-		}
+		plan.CreateLink(targetfile, origfile)
 	}
 
 	// Create /usr/share/glvnd/egl_vendor.d/10_nvidia.json
@@ -354,13 +312,10 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		"10_nvidia.json",
 	)
 
-	if utils.Exists(eglvendorOriginPath) {
+	if utils.Exists(b.realPath(eglvendorOriginPath)) {
 
-		if !utils.Exists(eglvendorTargetPath) {
-			err := os.MkdirAll(eglvendorTargetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
+		if !utils.Exists(b.realPath(eglvendorTargetPath)) {
+			plan.Mkdir(eglvendorTargetPath)
 		}
 
 		eglvendorTargetFile := filepath.Join(
@@ -368,13 +323,12 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 			"10_nvidia.json",
 		)
 
-		err := os.Symlink(eglvendorOriginPath, eglvendorTargetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				eglvendorOriginPath, eglvendorTargetFile, err.Error())
-		}
+		plan.CreateLink(eglvendorTargetFile, eglvendorOriginPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(eglvendorOriginPath, specs.DiagnosticSkippedArtifact,
+			errors.New("file not available in the driver, 10_nvidia.json not linked"))
+	}
 
 	// Create /usr/share/X11/xorg.conf.d/nvidia-drm-outputclass.conf
 	outputclassTargetPath := "/usr/share/X11/xorg.conf.d"
@@ -383,13 +337,10 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		"nvidia-drm-outputclass.conf",
 	)
 
-	if utils.Exists(outputclassOriginPath) {
+	if utils.Exists(b.realPath(outputclassOriginPath)) {
 
-		if !utils.Exists(outputclassTargetPath) {
-			err := os.MkdirAll(outputclassTargetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
+		if !utils.Exists(b.realPath(outputclassTargetPath)) {
+			plan.Mkdir(outputclassTargetPath)
 		}
 
 		outputclassTargetFile := filepath.Join(
@@ -397,13 +348,12 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 			"nvidia-drm-outputclass.conf",
 		)
 
-		err := os.Symlink(outputclassOriginPath, outputclassTargetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				outputclassOriginPath, outputclassTargetFile, err.Error())
-		}
+		plan.CreateLink(outputclassTargetFile, outputclassOriginPath)
 
-	} // else TODO add warning
+	} else {
+		plan.AddDiagnostic(outputclassOriginPath, specs.DiagnosticSkippedArtifact,
+			errors.New("file not available in the driver, nvidia-drm-outputclass.conf not linked"))
+	}
 
 	// Create /usr/share/dbus-1/system.d/nvidia-dbus.conf
 	dbusSystemTargetPath := "/usr/share/dbus-1/system.d"
@@ -411,26 +361,22 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		driverPath, dbusSystemTargetPath,
 		"nvidia-dbus.conf",
 	)
-	if utils.Exists(dbusSystemOriginPath) {
+	if utils.Exists(b.realPath(dbusSystemOriginPath)) {
 
-		if !utils.Exists(dbusSystemTargetPath) {
-			err := os.MkdirAll(dbusSystemTargetPath, os.ModePerm)
-			if err != nil {
-				return err
-			}
+		if !utils.Exists(b.realPath(dbusSystemTargetPath)) {
+			plan.Mkdir(dbusSystemTargetPath)
 		}
 
 		dbusTargetFile := filepath.Join(
 			dbusSystemTargetPath, "nvidia-dbus.conf",
 		)
 
-		err := os.Symlink(dbusSystemOriginPath, dbusTargetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				dbusSystemOriginPath, dbusTargetFile, err.Error())
-		}
+		plan.CreateLink(dbusTargetFile, dbusSystemOriginPath)
 
-	} // else TODO: Add warning
+	} else {
+		logger.GetDefaultLogger().Debug(
+			"File", dbusSystemOriginPath, "not available. Skipped.")
+	}
 
 	// Create /usr/share/man/man1/* files
 	manTargetPath := "/usr/share/man/man1"
@@ -438,36 +384,28 @@ func (b *MacaroniBackend) createUsrShare(v string) error {
 		driverPath, manTargetPath,
 	)
 
-	if !utils.Exists(manTargetPath) {
-		err := os.MkdirAll(manTargetPath, os.ModePerm)
-		if err != nil {
-			return err
-		}
+	if !utils.Exists(b.realPath(manTargetPath)) {
+		plan.Mkdir(manTargetPath)
 	}
 
 	for _, f := range manPages {
 		manFile := filepath.Join(manOriginPath, f)
-		if !utils.Exists(manFile) {
-			// TODO: Add warning
+		if !utils.Exists(b.realPath(manFile)) {
+			logger.GetDefaultLogger().Debug(
+				"File", manFile, "not available. Skipped.")
 			continue
 		}
 
 		targetManFile := filepath.Join(manTargetPath, f)
-		err := os.Symlink(manFile, targetManFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				targetManFile, manFile, err.Error())
-		}
+		plan.CreateLink(targetManFile, manFile)
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) createPngFile(v string) error {
-	dirPrefix := "nvidia-drivers"
+func (b *MacaroniBackend) createPngFile(plan *specs.OperationsPlan, v string) error {
 	pixmapsDir := "/usr/share/pixmaps"
-	sourceFile := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
+	sourceFile := filepath.Join(b.getDriverDir(v),
 		pixmapsDir,
 		"nvidia-settings.png",
 	)
@@ -475,23 +413,19 @@ func (b *MacaroniBackend) createPngFile(v string) error {
 		"nvidia-settings.png",
 	)
 
-	if utils.Exists(sourceFile) {
-		err := os.Symlink(sourceFile, targetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				targetFile, sourceFile, err.Error())
-		}
+	if utils.Exists(b.realPath(sourceFile)) {
+		plan.CreateLink(targetFile, sourceFile)
+	} else {
+		logger.GetDefaultLogger().Debug(
+			"File", sourceFile, "not available. Skipped.")
 	}
-	// TODO: Add warning if file doesn't exist
 
 	return nil
 }
 
-func (b *MacaroniBackend) createDesktopFile(v string) error {
-	dirPrefix := "nvidia-drivers"
+func (b *MacaroniBackend) createDesktopFile(plan *specs.OperationsPlan, v string) error {
 	appsDesktopDir := "/usr/share/applications"
-	sourceFile := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
+	sourceFile := filepath.Join(b.getDriverDir(v),
 		appsDesktopDir,
 		"nvidia-settings.desktop",
 	)
@@ -499,19 +433,17 @@ func (b *MacaroniBackend) createDesktopFile(v string) error {
 		"nvidia-settings.desktop",
 	)
 
-	if utils.Exists(sourceFile) {
-		err := os.Symlink(sourceFile, targetFile)
-		if err != nil {
-			return fmt.Errorf("error on linking file %s to %s: %s",
-				targetFile, sourceFile, err.Error())
-		}
+	if utils.Exists(b.realPath(sourceFile)) {
+		plan.CreateLink(targetFile, sourceFile)
+	} else {
+		logger.GetDefaultLogger().Debug(
+			"File", sourceFile, "not available. Skipped.")
 	}
-	// TODO: Add warning if file doesn't exist
 
 	return nil
 }
 
-func (b *MacaroniBackend) createEtc(v string) error {
+func (b *MacaroniBackend) createEtc(plan *specs.OperationsPlan, v string) error {
 	var etcsandboxd = "/etc/sandbox.d"
 	var xinitrcd = "/etc/X11/xinit/xinitrc.d"
 	var tmpfilesd = "/etc/tmpfiles.d"
@@ -520,124 +452,70 @@ func (b *MacaroniBackend) createEtc(v string) error {
 	var nvidiaTmpfilesd = filepath.Join(tmpfilesd, "nvidia-drivers.conf")
 
 	// Create /etc/sandbox.d/20nvidia
-	if !utils.Exists(etcsandboxd) {
-		err := os.MkdirAll(etcsandboxd, os.ModePerm)
-		if err != nil {
-			return err
-		}
+	if !utils.Exists(b.realPath(etcsandboxd)) {
+		plan.Mkdir(etcsandboxd)
 	}
 
-	err := os.WriteFile(nvidiaFile, []byte(
+	plan.WriteFile(nvidiaFile,
 		`SANDBOX_PREDICT="/dev/nvidiactl:/dev/nvidia-caps:/dev/char"
-`), 0644,
+`, 0644,
 	)
 
-	if err != nil {
-		return fmt.Errorf("error on write file %s: %s",
-			nvidiaFile, err.Error())
-	}
-
-	if !utils.Exists(xinitrcd) {
-		err := os.MkdirAll(xinitrcd, os.ModePerm)
-		if err != nil {
-			return err
-		}
+	if !utils.Exists(b.realPath(xinitrcd)) {
+		plan.Mkdir(xinitrcd)
 	}
 
 	// Create /etc/X11/xinit/xinitrc.d/95-nvidia-settings
-	err = os.WriteFile(nvidiaSettingsFile, []byte(
+	plan.WriteFile(nvidiaSettingsFile,
 		`#!/bin/sh
 if [ $(lsmod | grep nvidia | wc -l) != "0" ] ; then
   /usr/bin/nvidia-settings --load-config-only
 fi
-`), 0644)
-	if err != nil {
-		return fmt.Errorf("error on write file %s: %s",
-			nvidiaSettingsFile, err.Error())
-	}
+`, 0644)
 
-	if !utils.Exists(tmpfilesd) {
-		err := os.MkdirAll(tmpfilesd, os.ModePerm)
-		if err != nil {
-			return err
-		}
+	if !utils.Exists(b.realPath(tmpfilesd)) {
+		plan.Mkdir(tmpfilesd)
 	}
 
 	// Create /etc/tmpfiles.d/nvidia-drivers.conf
-	err = os.WriteFile(nvidiaTmpfilesd, []byte(
-		`d /run/nvidia-xdriver 0775 root video -`),
+	plan.WriteFile(nvidiaTmpfilesd,
+		`d /run/nvidia-xdriver 0775 root video -`,
 		0644)
-	if err != nil {
-		return fmt.Errorf("error on write file %s: %s",
-			nvidiaTmpfilesd, err.Error())
-	}
 
 	// Create link on /etc/OpenCL/vendors/nvidia.icd
-	dirPrefix := "nvidia-drivers"
-	openCLDir := "/etc/OpenCL/vendors"
-	sourceOpenCLFile := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
+	openCLDir := b.GetOpenCLVendorsDir()
+	sourceOpenCLFile := filepath.Join(b.getDriverDir(v),
 		openCLDir, "nvidia.icd",
 	)
-	if !utils.Exists(openCLDir) {
-		err = os.MkdirAll(openCLDir, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("error on create dir %s: %s",
-				openCLDir, err.Error())
-		}
+	if !utils.Exists(b.realPath(openCLDir)) {
+		plan.Mkdir(openCLDir)
 	}
 
 	linkOpenCLFile := filepath.Join(
 		openCLDir, "nvidia.icd",
 	)
-	err = os.Symlink(sourceOpenCLFile, linkOpenCLFile)
-	if err != nil {
-		return fmt.Errorf("error on create link file %s to %s: %s",
-			linkOpenCLFile, sourceOpenCLFile, err.Error())
-	}
+	plan.CreateLink(linkOpenCLFile, sourceOpenCLFile)
 
 	return nil
 }
 
-func (b *MacaroniBackend) createInitd(v string) error {
-	dirPrefix := "nvidia-drivers"
-	initdDir := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
+func (b *MacaroniBackend) createInitd(plan *specs.OperationsPlan, v string) error {
+	initdDir := filepath.Join(b.getDriverDir(v),
 		"etc/init.d",
 	)
 
 	for idx := range initdscripts {
 
 		f := filepath.Join(initdDir, initdscripts[idx])
-		if !utils.Exists(f) {
-			continue
-		}
-
-		if !utils.Exists(f) {
-			// TODO: Add warning
+		if !utils.Exists(b.realPath(f)) {
+			logger.GetDefaultLogger().Debug(
+				"File", f, "not available. Skipped.")
 			continue
 		}
 
 		t := filepath.Join("/etc/init.d", initdscripts[idx])
 
-		// Open destination file (truncate it if exists)
-		tfd, err := os.OpenFile(t, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
-		}
-		defer tfd.Close()
-
-		// Open source file
-		sourcefd, err := os.Open(f)
-		if err != nil {
-			return err
-		}
-		defer sourcefd.Close()
-
-		_, err = io.Copy(tfd, sourcefd)
-		if err != nil {
-			return err
-		}
+		plan.CopyFile(f, t)
 	}
 
 	return nil
@@ -651,53 +529,38 @@ func (b *MacaroniBackend) getDriverDir(v string) string {
 	return driverDir
 }
 
-func (b *MacaroniBackend) createNvidiaBins(v string) error {
-	var err error
-
-	dirPrefix := "nvidia-drivers"
-	driverDir := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
-	)
-	driverBinDir := filepath.Join(driverDir, "/bin")
+func (b *MacaroniBackend) createNvidiaBins(plan *specs.OperationsPlan, v string) error {
+	driverBinDir := filepath.Join(b.getDriverDir(v), "/bin")
 
 	for idx := range binariesBin {
 		f := filepath.Join(driverBinDir, binariesBin[idx])
 
-		if utils.Exists(f) {
-			b := filepath.Join("/usr/bin/", binariesBin[idx])
-			err = os.Symlink(f, b)
-			if err != nil {
-				return fmt.Errorf(
-					"error on create symlink %s: %s",
-					b, err.Error())
-			}
-		} // else {
-		// TODO: Add warning
+		if utils.Exists(b.realPath(f)) {
+			target := filepath.Join("/usr/bin/", binariesBin[idx])
+			plan.CreateLink(target, f)
+		} else {
+			logger.GetDefaultLogger().Debug(
+				"File", f, "not available. Skipped.")
+		}
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) createNvidiaEnvfile(v string) error {
+func (b *MacaroniBackend) createNvidiaEnvfile(plan *specs.OperationsPlan, v string) error {
 	envNvidia := filepath.Join(b.GetEnvironmentDir(), NvidiaEnvFileName)
 
-	dirPrefix := "nvidia-drivers"
-	libDir := filepath.Join(NvidiaPrefixDriverPath,
-		dirPrefix+"-"+v,
+	libDir := filepath.Join(b.getDriverDir(v),
 		"lib64",
 	)
 
-	err := os.WriteFile(envNvidia, []byte(
+	plan.WriteFile(envNvidia,
 		fmt.Sprintf(`
 # autogenerated file by gpu-configurator
 LDPATH="%s"
 NVIDIA_DRIVER_VERSION="%s"
 `,
-			libDir, v)), 0644)
+			libDir, v), 0644)
 
-	if err != nil {
-		return fmt.Errorf("Error on write env file %s: %s",
-			envNvidia, err.Error())
-	}
 	return nil
 }
@@ -5,144 +5,179 @@ See AUTHORS and LICENSE for the license details and contributors.
 package macaroni
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
 	"github.com/macaroni-os/gpu-configurator/pkg/specs"
-	"github.com/macaroni-os/macaronictl/pkg/utils"
 )
 
-func (b *MacaroniBackend) PurgeNVIDIADriver(setup *specs.NVIDIASetup) error {
-
-	// 1. Removing /etc/env.d/09nvidia file
-	f := "/etc/env.d/09nvidia"
-	if utils.Exists(f) {
-		err := os.Remove(f)
-		if err != nil {
-			return err
-		}
+func (b *MacaroniBackend) PurgeNVIDIADriver(setup *specs.NVIDIASetup,
+	opts *specs.NVIDIAPurgeOpts) error {
+	if opts == nil {
+		opts = specs.NewNVIDIAPurgeOpts()
 	}
 
-	// 2. Removing /usr/bin/ links
-	err := b.purgeNvidiaBins()
+	manifest, err := b.Executor.GetManifest()
 	if err != nil {
 		return err
 	}
 
-	// 3. Removing /etc/init.d links
-	err = b.purgeNvidiaInitd()
-	if err != nil {
-		return err
+	if len(manifest.GetArtifactsByVersion("")) > 0 {
+		// POST: the setup is been done with the state manifest.
+		//       I remove exactly the artifacts recorded.
+		steps := []nvidiaStep{
+			{"Remove recorded artifacts", func(plan *specs.OperationsPlan, v string) error {
+				return b.purgeManagedArtifacts(plan, manifest, opts)
+			}},
+		}
+		return b.runNvidiaSteps(steps, setup.VersionActive)
 	}
 
-	// 4. Removing file from /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d
-	err = b.purgeEtc()
-	if err != nil {
-		return err
+	// Without state manifest I use the static lists of the files.
+	steps := []nvidiaStep{
+		// 1. Removing /etc/env.d/09nvidia file
+		{"Remove /etc/env.d/09nvidia file", b.purgeNvidiaEnvfile},
+		// 2. Removing /usr/bin/ links
+		{"Remove /usr/bin links", b.purgeNvidiaBins},
+		// 3. Removing /etc/init.d links
+		{"Remove /etc/init.d scripts", b.purgeNvidiaInitd},
+		// 4. Removing file from /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d
+		{"Remove /etc/X11, /etc/sandbox.d, /etc/tmpfiles.d, /etc/OpenCL files", b.purgeEtc},
+		// 5. remove link for .desktop
+		{"Remove .desktop file link", b.purgeDesktopfile},
+		// 6. remove png file
+		{"Remove .png file link", b.purgePngfile},
+		// 7. remove links under /usr/share
+		{"Remove /usr/share links", b.purgeUsrShare},
+		// 8. remove links under /usr/lib64/xorg/modules/drivers/
+		{"Remove Xorg modules driver link", b.purgeXorgModulesDriver},
+		// 9. removing links under /usr/lib64/xorgs/modules/extensions
+		{"Remove Xorg modules extension link", b.purgeXorgModulesExtension},
 	}
 
-	// 5. remove link for .desktop
-	err = b.purgeDesktopfile()
-	if err != nil {
-		return err
+	// 10. By default I avoid to remove file from /etc/conf.d/
+	if !opts.KeepConfd {
+		steps = append(steps,
+			nvidiaStep{"Remove /etc/conf.d files", b.purgeConfd})
 	}
 
-	// 6. remove png file
-	err = b.purgePngfile()
-	if err != nil {
-		return err
-	}
+	// 11. removing /etc/ld.so.conf.d file
+	steps = append(steps,
+		nvidiaStep{"Remove /etc/ld.so.conf.d file", b.purgeLdsoconfdFile})
 
-	// 7. remove links under /usr/share
-	err = b.purgeUsrShare(setup.VersionActive)
-	if err != nil {
-		return err
-	}
+	return b.runNvidiaSteps(steps, setup.VersionActive)
+}
 
-	// 8. remove links under /usr/lib64/xorg/modules/drivers/
-	err = b.purgeXorgModulesDriver()
-	if err != nil {
-		return err
-	}
+// Remove the artifacts recorded in the manifest. The artifacts
+// modified after the setup are not removed and are forgotten.
+func (b *MacaroniBackend) purgeManagedArtifacts(plan *specs.OperationsPlan,
+	manifest *specs.StateManifest, opts *specs.NVIDIAPurgeOpts) error {
+	log := logger.GetDefaultLogger()
 
-	// 9. removing links under /usr/lib64/xorgs/modules/extensions
-	err = b.purgeXorgModulesExtension()
-	if err != nil {
-		return err
-	}
+	for _, a := range manifest.GetArtifactsByVersion("") {
+		if opts.KeepConfd && filepath.Dir(a.Path) == "/etc/conf.d" {
+			continue
+		}
 
-	// 10. I avoid to remove file from /etc/conf.d/
+		path := b.realPath(a.Path)
+		if rootfs.Lexists(path) {
+			changed := false
+			if a.Type == specs.OperationCreateLink {
+				linked, err := os.Readlink(path)
+				changed = err != nil || linked != a.Target
+			} else {
+				checksum, err := executor.Checksum(path)
+				changed = err != nil || checksum != a.Checksum
+			}
 
-	// 11. removing /etc/ld.so.conf.d file
-	err = b.purgeLdsoconfdFile()
-	if err != nil {
-		return err
+			if changed {
+				log.Warning(fmt.Sprintf(
+					"File %s modified after the setup. Leaving it.", a.Path))
+				manifest.Forget(a.Path)
+				continue
+			}
+		}
+
+		// POST: the remove of a missing file forgets the artifact.
+		plan.Remove(a.Path)
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeLdsoconfdFile() error {
+// Remove the file or the link if exists.
+func (b *MacaroniBackend) removeIfPresent(plan *specs.OperationsPlan, path string) error {
+	if rootfs.Lexists(b.realPath(path)) {
+		plan.Remove(path)
+	}
+	return nil
+}
+
+func (b *MacaroniBackend) purgeNvidiaEnvfile(plan *specs.OperationsPlan, v string) error {
+	return b.removeIfPresent(plan,
+		filepath.Join(b.GetEnvironmentDir(), NvidiaEnvFileName),
+	)
+}
+
+func (b *MacaroniBackend) purgeConfd(plan *specs.OperationsPlan, v string) error {
+	return b.removeIfPresent(plan,
+		filepath.Join("/etc/conf.d", "nvidia-persistenced"),
+	)
+}
+
+func (b *MacaroniBackend) purgeLdsoconfdFile(plan *specs.OperationsPlan, v string) error {
 	targetDir := "/etc/ld.so.conf.d"
 	targetFile := filepath.Join(targetDir,
 		"07-nvidia",
 	)
 
-	if utils.Exists(targetFile) {
-		err := os.Remove(targetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, targetFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeXorgModulesExtension() error {
+func (b *MacaroniBackend) purgeXorgModulesExtension(plan *specs.OperationsPlan, v string) error {
 	targetPath := "/usr/lib64/xorg/modules/extensions"
 	targetFile := filepath.Join(
-		targetPath, "nvidia_drv.so",
+		targetPath, "libglxserver_nvidia.so",
 	)
 
-	if utils.Exists(targetFile) {
-		err := os.Remove(targetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, targetFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeXorgModulesDriver() error {
+func (b *MacaroniBackend) purgeXorgModulesDriver(plan *specs.OperationsPlan, v string) error {
 	targetPath := "/usr/lib64/xorg/modules/drivers"
 	targetFile := filepath.Join(
 		targetPath, "nvidia_drv.so",
 	)
 
-	if utils.Exists(targetFile) {
-		err := os.Remove(targetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, targetFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeUsrShare(v string) error {
+func (b *MacaroniBackend) purgeUsrShare(plan *specs.OperationsPlan, v string) error {
 	nvidiaVulkanIcdTargetPath := "/usr/share/vulkan/icd.d"
 	nvidiaVulkanIcdTargetFile := filepath.Join(
 		nvidiaVulkanIcdTargetPath,
 		"nvidia_icd.json",
 	)
 
-	if utils.Exists(nvidiaVulkanIcdTargetFile) {
-		err := os.Remove(nvidiaVulkanIcdTargetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, nvidiaVulkanIcdTargetFile); err != nil {
+		return err
 	}
 
 	// Removing /usr/share/vulkan/implicit_layer.d/nvidia_layers.json
@@ -152,22 +187,35 @@ func (b *MacaroniBackend) purgeUsrShare(v string) error {
 		"nvidia_layers.json",
 	)
 
-	if utils.Exists(nvidiaVulkanLayerTargetFile) {
-		err := os.Remove(nvidiaVulkanLayerTargetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, nvidiaVulkanLayerTargetFile); err != nil {
+		return err
 	}
 
 	// Removing /usr/share/nvidia/ files
 	shareNvidiaTargetPath := "/usr/share/nvidia"
 	for _, f := range shareNvidiaFiles {
+		if v == "" && strings.Contains(f, "PV") {
+			// POST: without an active version I remove only the
+			//       links that point to a NVIDIA driver slot.
+			files, _ := filepath.Glob(filepath.Join(
+				b.realPath(shareNvidiaTargetPath),
+				strings.ReplaceAll(f, "PV", "*")))
+			for _, targetfile := range files {
+				linked, err := os.Readlink(targetfile)
+				if err != nil || !strings.HasPrefix(linked, NvidiaPrefixDriverPath) {
+					continue
+				}
+				plan.Remove(filepath.Join(shareNvidiaTargetPath,
+					filepath.Base(targetfile)))
+			}
+			continue
+		}
+
 		f := strings.ReplaceAll(f, "PV", v)
 		targetfile := filepath.Join(
 			shareNvidiaTargetPath, f)
 
-		err := os.Remove(targetfile)
-		if err != nil {
+		if err := b.removeIfPresent(plan, targetfile); err != nil {
 			return err
 		}
 	}
@@ -179,11 +227,8 @@ func (b *MacaroniBackend) purgeUsrShare(v string) error {
 		"10_nvidia.json",
 	)
 
-	if utils.Exists(eglvendorTargetFile) {
-		err := os.Remove(eglvendorTargetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, eglvendorTargetFile); err != nil {
+		return err
 	}
 
 	// Removing /usr/share/dbus-1/system.d/nvidia-dbus.conf
@@ -191,61 +236,49 @@ func (b *MacaroniBackend) purgeUsrShare(v string) error {
 	dbusTargetFile := filepath.Join(
 		dbusSystemTargetPath, "nvidia-dbus.conf",
 	)
-	if utils.Exists(dbusTargetFile) {
-		err := os.Remove(dbusTargetFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, dbusTargetFile); err != nil {
+		return err
 	}
 
 	// Removing /usr/share/man/man1/* files
 	manTargetPath := "/usr/share/man/man1"
 	for _, f := range manPages {
 		manFile := filepath.Join(manTargetPath, f)
-		if utils.Exists(manFile) {
-			err := os.Remove(manFile)
-			if err != nil {
-				return err
-			}
+		if err := b.removeIfPresent(plan, manFile); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgePngfile() error {
+func (b *MacaroniBackend) purgePngfile(plan *specs.OperationsPlan, v string) error {
 	pixmapsDir := "/usr/share/pixmaps"
 	pngFile := filepath.Join(pixmapsDir,
 		"nvidia-settings.png",
 	)
 
-	if utils.Exists(pngFile) {
-		err := os.Remove(pngFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, pngFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeDesktopfile() error {
+func (b *MacaroniBackend) purgeDesktopfile(plan *specs.OperationsPlan, v string) error {
 	appsDesktopDir := "/usr/share/applications"
 	desktopFile := filepath.Join(appsDesktopDir,
 		"nvidia-settings.desktop",
 	)
 
-	if utils.Exists(desktopFile) {
-		err := os.Remove(desktopFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, desktopFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeEtc() error {
+func (b *MacaroniBackend) purgeEtc(plan *specs.OperationsPlan, v string) error {
 	var etcsandboxd = "/etc/sandbox.d"
 	var xinitrcd = "/etc/X11/xinit/xinitrc.d"
 	var tmpfilesd = "/etc/tmpfiles.d"
@@ -253,63 +286,45 @@ func (b *MacaroniBackend) purgeEtc() error {
 	var nvidiaFile = filepath.Join(etcsandboxd, "20nvidia")
 	var nvidiaTmpfilesd = filepath.Join(tmpfilesd, "nvidia-drivers.conf")
 
-	if utils.Exists(nvidiaFile) {
-		err := os.Remove(nvidiaFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, nvidiaFile); err != nil {
+		return err
 	}
 
-	if utils.Exists(nvidiaSettingsFile) {
-		err := os.Remove(nvidiaSettingsFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, nvidiaSettingsFile); err != nil {
+		return err
 	}
 
-	if utils.Exists(nvidiaTmpfilesd) {
-		err := os.Remove(nvidiaTmpfilesd)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, nvidiaTmpfilesd); err != nil {
+		return err
 	}
 
-	openCLDir := "/etc/OpenCL/vendors"
+	openCLDir := b.GetOpenCLVendorsDir()
 	linkOpenCLFile := filepath.Join(
 		openCLDir, "nvidia.icd",
 	)
-	if utils.Exists(linkOpenCLFile) {
-		err := os.Remove(linkOpenCLFile)
-		if err != nil {
-			return err
-		}
+	if err := b.removeIfPresent(plan, linkOpenCLFile); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeNvidiaBins() error {
+func (b *MacaroniBackend) purgeNvidiaBins(plan *specs.OperationsPlan, v string) error {
 	for idx := range binariesBin {
 		f := filepath.Join("/usr/bin/", binariesBin[idx])
-		if utils.Exists(f) {
-			err := os.Remove(f)
-			if err != nil {
-				return err
-			}
+		if err := b.removeIfPresent(plan, f); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (b *MacaroniBackend) purgeNvidiaInitd() error {
+func (b *MacaroniBackend) purgeNvidiaInitd(plan *specs.OperationsPlan, v string) error {
 	for idx := range initdscripts {
 		f := filepath.Join("/etc/init.d/", initdscripts[idx])
-		if utils.Exists(f) {
-			err := os.Remove(f)
-			if err != nil {
-				return err
-			}
+		if err := b.removeIfPresent(plan, f); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package macaroni
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/executor"
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs/testutil"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+func TestPurgeManagedArtifacts(t *testing.T) {
+	logger.NewLogger(specs.NewConfig(nil)).SetAsDefault()
+
+	const envContent = "NVIDIA_DRIVER_VERSION=555.2\n"
+	otherSum := "0000000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name      string
+		keepConfd bool
+		// The files of the root. The links have the "->" prefix.
+		files      map[string]string
+		artifacts  []*specs.ManagedArtifact
+		wantRemove []string
+		wantKept   []string
+	}{
+		{
+			name:  "unchanged link removed",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/opt/nvidia/nvidia-smi"},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink,
+					Target: "/opt/nvidia/nvidia-smi", Version: "555.2"},
+			},
+			wantRemove: []string{"/usr/bin/nvidia-smi"},
+			wantKept:   []string{},
+		},
+		{
+			name:  "modified link kept",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/usr/local/bin/nvidia-smi"},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink,
+					Target: "/opt/nvidia/nvidia-smi", Version: "555.2"},
+			},
+			wantRemove: []string{},
+			wantKept:   []string{"/usr/bin/nvidia-smi"},
+		},
+		{
+			name:  "unchanged file removed",
+			files: map[string]string{"/etc/env.d/09nvidia": envContent},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/etc/env.d/09nvidia", Type: specs.OperationWriteFile,
+					Checksum: "", Version: "555.2"},
+			},
+			wantRemove: []string{"/etc/env.d/09nvidia"},
+			wantKept:   []string{},
+		},
+		{
+			name:  "modified file kept",
+			files: map[string]string{"/etc/env.d/09nvidia": "NVIDIA_DRIVER_VERSION=custom\n"},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/etc/env.d/09nvidia", Type: specs.OperationWriteFile,
+					Checksum: otherSum, Version: "555.2"},
+			},
+			wantRemove: []string{},
+			wantKept:   []string{"/etc/env.d/09nvidia"},
+		},
+		{
+			name:  "missing artifact forgotten",
+			files: map[string]string{},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-settings", Type: specs.OperationCreateLink,
+					Target: "/opt/nvidia/nvidia-settings", Version: "555.2"},
+			},
+			wantRemove: []string{"/usr/bin/nvidia-settings"},
+			wantKept:   []string{},
+		},
+		{
+			name:      "conf.d file kept with keep-confd",
+			keepConfd: true,
+			files:     map[string]string{"/etc/conf.d/nvidia-persistenced": envContent},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/etc/conf.d/nvidia-persistenced", Type: specs.OperationCopyFile,
+					Checksum: "", Version: "555.2"},
+			},
+			wantRemove: []string{},
+			wantKept:   []string{},
+		},
+		{
+			name:  "artifact without version ignored",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/opt/nvidia/nvidia-smi"},
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink,
+					Target: "/opt/nvidia/nvidia-smi"},
+			},
+			wantRemove: []string{},
+			wantKept:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := testutil.NewFakeRoot(t, tt.files)
+
+			manifest := specs.NewStateManifest()
+			for _, a := range tt.artifacts {
+				if a.Type != specs.OperationCreateLink && a.Checksum == "" {
+					// POST: the file is not modified after the setup.
+					sum, err := executor.Checksum(filepath.Join(root, a.Path))
+					if err != nil {
+						t.Fatal(err)
+					}
+					a.Checksum = sum
+				}
+				manifest.Record(a)
+			}
+
+			b, _ := NewMacaroniBackend(root)
+			opts := specs.NewNVIDIAPurgeOpts()
+			opts.KeepConfd = tt.keepConfd
+
+			plan := specs.NewOperationsPlan()
+			if err := b.purgeManagedArtifacts(plan, manifest, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			removed := []string{}
+			for _, op := range plan.Operations {
+				if op.Type != specs.OperationRemove {
+					t.Errorf("unexpected operation %s on %s", op.Type, op.Path)
+				}
+				removed = append(removed, op.Path)
+			}
+			if len(removed) != len(tt.wantRemove) {
+				t.Fatalf("removed = %v, want %v", removed, tt.wantRemove)
+			}
+			for idx := range removed {
+				if removed[idx] != tt.wantRemove[idx] {
+					t.Errorf("removed = %v, want %v", removed, tt.wantRemove)
+				}
+			}
+
+			for _, p := range tt.wantKept {
+				if manifest.GetArtifact(p) != nil {
+					t.Errorf("modified artifact %s not forgotten", p)
+				}
+				if _, err := os.Lstat(filepath.Join(root, p)); err != nil {
+					t.Errorf("modified artifact %s: %s", p, err.Error())
+				}
+			}
+		})
+	}
+}
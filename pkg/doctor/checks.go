@@ -0,0 +1,250 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package doctor
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+func init() {
+	Register(&Check{
+		Name:        "nvidia-driver",
+		Description: "The NVIDIA driver configured is installed.",
+		Run:         checkNvidiaDriver,
+	})
+	Register(&Check{
+		Name:        "nvidia-kernel-modules",
+		Description: "The NVIDIA kernel modules are available for the running kernel.",
+		Run:         checkNvidiaKernelModules,
+	})
+	Register(&Check{
+		Name:        "nvidia-nouveau",
+		Description: "The nvidia and nouveau drivers are not bound together.",
+		Run:         checkNvidiaNouveau,
+	})
+	Register(&Check{
+		Name:        "vulkan-icd-library",
+		Description: "The libraries of the Vulkan ICD files are available.",
+		Run:         checkVulkanIcdLibrary,
+	})
+	Register(&Check{
+		Name:        "egl-loader-library",
+		Description: "The libraries of the EGL external platforms are available.",
+		Run:         checkEglLoaderLibrary,
+	})
+	Register(&Check{
+		Name:        "egl-vendor-library",
+		Description: "The libraries of the glvnd EGL vendors are available.",
+		Run:         checkEglVendorLibrary,
+	})
+	Register(&Check{
+		Name:        "opencl-vendor-library",
+		Description: "The libraries of the OpenCL ICD vendors are available.",
+		Run:         checkOpenCLVendorLibrary,
+	})
+	Register(&Check{
+		Name:        "gbm-library-link",
+		Description: "The GBM libraries links are valid.",
+		Run:         checkGbmLibraryLink,
+	})
+	Register(&Check{
+		Name:        "analyzer-diagnostics",
+		Description: "The configuration files are readable and valid.",
+		Run:         checkAnalyzerDiagnostics,
+	})
+}
+
+// Return the last version of the NVIDIA drivers with kernel modules
+// for the running kernel.
+func getNvidiaVersionWithModules(setup *specs.NVIDIASetup) string {
+	ans := ""
+	for _, d := range setup.Drivers {
+		if d.WithKernelModules {
+			ans = d.Version
+		}
+	}
+	return ans
+}
+
+func checkNvidiaDriver(ctx *Context) []*Result {
+	ans := []*Result{}
+	setup := ctx.Analyzer.GetSystem().Nvidia
+	if setup == nil || (len(setup.Drivers) == 0 && setup.VersionConfigured == "") {
+		return ans
+	}
+
+	fix := ""
+	if v := getNvidiaVersionWithModules(setup); v != "" {
+		fix = "gpu-configurator nvidia configure " + v
+	} else if len(setup.Drivers) == 0 {
+		fix = "gpu-configurator nvidia unconfigure"
+	}
+
+	// POST: VersionActive is empty if the version configured
+	//       is not installed.
+	if setup.VersionConfigured == "" {
+		ans = append(ans, NewResult("nvidia-driver", SeverityWarning,
+			"NVIDIA drivers installed but no version configured.", fix))
+	} else if !setup.HasVersion(setup.VersionConfigured) {
+		ans = append(ans, NewResult("nvidia-driver", SeverityError,
+			fmt.Sprintf("NVIDIA driver %s configured but not installed.",
+				setup.VersionConfigured), fix))
+	}
+
+	return ans
+}
+
+func checkNvidiaKernelModules(ctx *Context) []*Result {
+	ans := []*Result{}
+	setup := ctx.Analyzer.GetSystem().Nvidia
+	if setup == nil || setup.VersionActive == "" {
+		return ans
+	}
+
+	driver := setup.GetDriver(setup.VersionActive)
+	if driver == nil || driver.WithKernelModules {
+		return ans
+	}
+
+	fix := ""
+	if v := getNvidiaVersionWithModules(setup); v != "" {
+		fix = "gpu-configurator nvidia configure " + v
+	}
+	ans = append(ans, NewResult("nvidia-kernel-modules", SeverityError,
+		fmt.Sprintf("No NVIDIA %s kernel module found for the running kernel.",
+			setup.VersionActive), fix))
+
+	return ans
+}
+
+func checkNvidiaNouveau(ctx *Context) []*Result {
+	ans := []*Result{}
+	fix := "echo 'blacklist nouveau' > /etc/modprobe.d/blacklist-nouveau.conf"
+
+	if ctx.IsModuleLoaded("nvidia") && ctx.IsModuleLoaded("nouveau") {
+		ans = append(ans, NewResult("nvidia-nouveau", SeverityError,
+			"Both nvidia and nouveau kernel modules are loaded.", fix))
+	}
+
+	setup := ctx.Analyzer.GetSystem().Nvidia
+	topology := ctx.Analyzer.GetSystem().Topology
+	if topology == nil || setup == nil || setup.VersionActive == "" {
+		return ans
+	}
+
+	for _, gpu := range topology.GPUs {
+		if gpu.Vendor == "nvidia" && gpu.KernelDriver == "nouveau" {
+			ans = append(ans, NewResult("nvidia-nouveau", SeverityWarning,
+				fmt.Sprintf("NVIDIA driver %s configured but GPU %s is bound to nouveau.",
+					setup.VersionActive, gpu.BusId), fix))
+		}
+	}
+
+	return ans
+}
+
+func (ctx *Context) checkJsonFilesLibrary(check string,
+	dirs []*specs.EglExternalPlatformFiles, fixCmd string) []*Result {
+	ans := []*Result{}
+
+	for _, dir := range dirs {
+		for _, f := range dir.Files {
+			if f.Disabled || f.File == nil || f.File.ICD.LibraryPath == "" {
+				continue
+			}
+
+			if !ctx.Analyzer.LibraryExists(dir.Path, f.File.ICD.LibraryPath) {
+				ans = append(ans, NewResult(check, SeverityError,
+					fmt.Sprintf("Library %s of %s not found.",
+						f.File.ICD.LibraryPath, filepath.Join(dir.Path, f.Name)),
+					fixCmd+" "+f.Name))
+			}
+		}
+	}
+
+	return ans
+}
+
+func checkVulkanIcdLibrary(ctx *Context) []*Result {
+	return ctx.checkJsonFilesLibrary("vulkan-icd-library",
+		ctx.Analyzer.GetSystem().VulkanICDDirs,
+		"gpu-configurator vulkan icd --disable-icd-file")
+}
+
+func checkEglLoaderLibrary(ctx *Context) []*Result {
+	return ctx.checkJsonFilesLibrary("egl-loader-library",
+		ctx.Analyzer.GetSystem().EglExtPlatformDirs,
+		"gpu-configurator egl --disable-json-loader")
+}
+
+func checkEglVendorLibrary(ctx *Context) []*Result {
+	return ctx.checkJsonFilesLibrary("egl-vendor-library",
+		ctx.Analyzer.GetSystem().EglVendorDirs,
+		"gpu-configurator egl vendor --disable")
+}
+
+func checkOpenCLVendorLibrary(ctx *Context) []*Result {
+	ans := []*Result{}
+	vendors := ctx.Analyzer.GetSystem().OpenCLVendors
+	if vendors == nil {
+		return ans
+	}
+
+	for _, f := range vendors.Files {
+		if f.Disabled || f.LibraryPath == "" || f.LibraryAvailable {
+			continue
+		}
+		ans = append(ans, NewResult("opencl-vendor-library", SeverityError,
+			fmt.Sprintf("Library %s of %s not found.",
+				f.LibraryPath, filepath.Join(vendors.Path, f.Name)),
+			"gpu-configurator opencl --disable-icd-file "+f.Name))
+	}
+
+	return ans
+}
+
+func checkGbmLibraryLink(ctx *Context) []*Result {
+	ans := []*Result{}
+	rootDir := ctx.Analyzer.GetBackend().GetRootDir()
+	gbmDir := ctx.Analyzer.GetBackend().GetGBMLibDir()
+
+	for _, lib := range ctx.Analyzer.GetSystem().GbmLibraries {
+		if lib.Disabled || lib.LinkedFile == "" {
+			continue
+		}
+
+		resolved, err := rootfs.ResolvePath(rootDir, filepath.Join(gbmDir, lib.Name))
+		if err == nil && utils.Exists(resolved) {
+			continue
+		}
+
+		fix := "rm " + filepath.Join(gbmDir, lib.Name)
+		if lib.Name == specs.NVIDIAGbmLibName {
+			fix = "gpu-configurator nvidia gbmlib --disable-driver --purge"
+		}
+		ans = append(ans, NewResult("gbm-library-link", SeverityError,
+			fmt.Sprintf("The link %s points to the missing file %s.",
+				filepath.Join(gbmDir, lib.Name), lib.LinkedFile), fix))
+	}
+
+	return ans
+}
+
+func checkAnalyzerDiagnostics(ctx *Context) []*Result {
+	ans := []*Result{}
+
+	for _, d := range ctx.Analyzer.GetSystem().Diagnostics {
+		ans = append(ans, NewResult("analyzer-diagnostics", SeverityWarning,
+			fmt.Sprintf("%s %s: %s", d.Kind, d.Path, d.Error), ""))
+	}
+
+	return ans
+}
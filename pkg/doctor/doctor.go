@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package doctor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SeverityOk      = "ok"
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+
+	// The exit codes of the doctor command.
+	ExitOk      = 0
+	ExitWarning = 1
+	ExitError   = 2
+	// The command failed before the end of the checks.
+	ExitFailure = 3
+
+	DefaultProcModulesFile = "/proc/modules"
+)
+
+type Result struct {
+	Check    string `json:"check" yaml:"check"`
+	Severity string `json:"severity" yaml:"severity"`
+	Message  string `json:"message" yaml:"message"`
+	// The command suggested to fix the issue.
+	Fix string `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+type Report struct {
+	Results []*Result `json:"results" yaml:"results"`
+}
+
+// The data available to the checks.
+type Context struct {
+	Analyzer *analyzer.Analyzer
+	// The kernel modules loaded on the running system.
+	LoadedModules []string
+}
+
+type Check struct {
+	Name        string
+	Description string
+	Run         func(ctx *Context) []*Result
+}
+
+var registry = []*Check{}
+
+// Register a check. The checks are executed in the
+// order of registration.
+func Register(c *Check) {
+	registry = append(registry, c)
+}
+
+func GetChecks() []*Check {
+	return registry
+}
+
+func GetCheck(name string) *Check {
+	for _, c := range registry {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func NewContext(a *analyzer.Analyzer, procModulesFile string) *Context {
+	ans := &Context{
+		Analyzer:      a,
+		LoadedModules: []string{},
+	}
+
+	if procModulesFile == "" {
+		procModulesFile = DefaultProcModulesFile
+	}
+	// POST: the loaded modules are not available in a
+	//       container or with an alternate root.
+	ans.LoadedModules, _ = readLoadedModules(procModulesFile)
+
+	return ans
+}
+
+func (c *Context) IsModuleLoaded(m string) bool {
+	for _, lm := range c.LoadedModules {
+		if lm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func NewResult(check, severity, message, fix string) *Result {
+	return &Result{
+		Check:    check,
+		Severity: severity,
+		Message:  message,
+		Fix:      fix,
+	}
+}
+
+// Run the checks with the selected names or all the
+// registered checks if names is empty.
+func Run(ctx *Context, names []string) (*Report, error) {
+	checks := registry
+	if len(names) > 0 {
+		checks = []*Check{}
+		for _, name := range names {
+			c := GetCheck(name)
+			if c == nil {
+				return nil, fmt.Errorf("check %s not found", name)
+			}
+			checks = append(checks, c)
+		}
+	}
+
+	ans := &Report{Results: []*Result{}}
+	for _, c := range checks {
+		results := c.Run(ctx)
+		if len(results) == 0 {
+			results = []*Result{NewResult(c.Name, SeverityOk, c.Description, "")}
+		}
+		ans.Results = append(ans.Results, results...)
+	}
+
+	return ans, nil
+}
+
+func (r *Report) Yaml() ([]byte, error) {
+	return yaml.Marshal(r)
+}
+
+func (r *Report) Json() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Return the exit code based on the worst severity.
+func (r *Report) ExitCode() int {
+	ans := ExitOk
+	for _, res := range r.Results {
+		switch res.Severity {
+		case SeverityError:
+			return ExitError
+		case SeverityWarning:
+			ans = ExitWarning
+		}
+	}
+	return ans
+}
+
+func (r *Report) Print(output string) error {
+	var err error
+	var data []byte
+
+	switch output {
+	case "json":
+		data, err = r.Json()
+	case "yaml":
+		data, err = r.Yaml()
+	default:
+		for _, res := range r.Results {
+			fmt.Println(fmt.Sprintf("%10s %s: %s", res.Severity, res.Check, res.Message))
+			if res.Fix != "" {
+				fmt.Println(fmt.Sprintf("%10s %s", "fix:", res.Fix))
+			}
+		}
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// Read the names of the modules from /proc/modules.
+// Example of line:
+// nvidia_drm 126976 4 - Live 0x0000000000000000 (POE)
+func readLoadedModules(f string) ([]string, error) {
+	ans := []string{}
+
+	fd, err := os.Open(f)
+	if err != nil {
+		return ans, err
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		words := strings.Fields(scanner.Text())
+		if len(words) > 0 {
+			ans = append(ans, words[0])
+		}
+	}
+
+	return ans, scanner.Err()
+}
@@ -0,0 +1,232 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package environment
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/video"
+)
+
+const (
+	// Print the variables for eval.
+	TargetPrint = "print"
+	// Write the variables under the env.d directory (env-update).
+	TargetEnvd = "envd"
+	// Write the variables under /etc/environment.d (systemd).
+	TargetEnvironmentd = "environmentd"
+	// Write the variables under ~/.config/environment.d
+	TargetUser = "user"
+
+	EnvdFileName             = "08gpu-configurator"
+	EnvironmentdDir          = "/etc/environment.d"
+	EnvironmentdFileName     = "20-gpu-configurator.conf"
+	UserEnvironmentdDir      = ".config/environment.d"
+	UserEnvironmentdFileName = "gpu-configurator.conf"
+
+	// The files of the video command. The names are sorted after
+	// the session files to override the video drivers.
+	EnvdVideoFileName             = "08gpu-configurator_video"
+	EnvironmentdVideoFileName     = "20-gpu-configurator_video.conf"
+	UserEnvironmentdVideoFileName = "gpu-configurator_video.conf"
+)
+
+type Variable struct {
+	Name    string `json:"name" yaml:"name"`
+	Value   string `json:"value" yaml:"value"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// The value is valid only for the current boot and it's
+	// not written in the files.
+	Runtime bool `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+}
+
+type Environment struct {
+	Variables []*Variable `json:"variables" yaml:"variables"`
+}
+
+func ValidTarget(target string) bool {
+	switch target {
+	case TargetPrint, TargetEnvd, TargetEnvironmentd, TargetUser:
+		return true
+	default:
+		return false
+	}
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{
+		Variables: []*Variable{},
+	}
+}
+
+// Add the variable or replace the value if already present.
+func (e *Environment) Set(name, value, comment string) {
+	for _, v := range e.Variables {
+		if v.Name == name {
+			v.Value = value
+			v.Comment = comment
+			v.Runtime = false
+			return
+		}
+	}
+	e.Variables = append(e.Variables, &Variable{
+		Name:    name,
+		Value:   value,
+		Comment: comment,
+	})
+}
+
+// Add the variable valid only for the current boot.
+func (e *Environment) SetRuntime(name, value, comment string) {
+	e.Set(name, value, comment)
+	e.GetVariable(name).Runtime = true
+}
+
+func (e *Environment) GetVariable(name string) *Variable {
+	for _, v := range e.Variables {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Return the variables not written in the files.
+func (e *Environment) GetRuntimeVariables() []*Variable {
+	ans := []*Variable{}
+	for _, v := range e.Variables {
+		if v.Runtime {
+			ans = append(ans, v)
+		}
+	}
+	return ans
+}
+
+// Set the VA-API and VDPAU variables of the drivers.
+func (e *Environment) SetVideoDrivers(d *video.Drivers) {
+	vars := d.GetEnvironment()
+	for _, v := range []struct {
+		Name    string
+		Comment string
+	}{
+		{video.VADriverEnv, "VA-API driver"},
+		{video.VDPAUDriverEnv, "VDPAU driver"},
+		{video.NvdBackendEnv, "Backend of nvidia-vaapi-driver"},
+	} {
+		if value, present := vars[v.Name]; present {
+			e.Set(v.Name, value, v.Comment)
+		}
+	}
+}
+
+// Compute the session variables for the GPU that drives
+// the outputs of the Wayland compositor. The video drivers
+// are selected between the drivers of the setup.
+func GetSessionEnvironment(topology *specs.GPUTopology,
+	setup *specs.VideoSetup) (*Environment, error) {
+	if topology == nil || len(topology.GPUs) == 0 {
+		return nil, fmt.Errorf("no GPUs available")
+	}
+
+	ans := NewEnvironment()
+
+	display := topology.GetGPU(topology.DisplayGPU)
+	if display == nil {
+		display = topology.GPUs[0]
+	}
+
+	switch display.KernelDriver {
+	case "nvidia":
+		ans.Set("GBM_BACKEND", "nvidia-drm",
+			"Use the NVIDIA GBM backend")
+		ans.Set("__GLX_VENDOR_LIBRARY_NAME", "nvidia",
+			"Use the NVIDIA GLX library with glvnd")
+		ans.Set("WLR_NO_HARDWARE_CURSORS", "1",
+			"Hardware cursors are broken with NVIDIA on wlroots")
+	}
+
+	ans.SetVideoDrivers(video.GetDrivers(display.KernelDriver, setup))
+
+	// The compositors use the first DRM device as primary GPU.
+	// The lists are colon separated so the stable by-path links
+	// can't be used and the card numbering could change on the
+	// next boot: the variables are only printed.
+	devices := []string{}
+	if display.Card != "" {
+		devices = append(devices, filepath.Join("/dev/dri", display.Card))
+	}
+	for _, gpu := range topology.GPUs {
+		if gpu != display && gpu.Card != "" {
+			devices = append(devices, filepath.Join("/dev/dri", gpu.Card))
+		}
+	}
+	if len(devices) > 1 {
+		ans.SetRuntime("WLR_DRM_DEVICES", strings.Join(devices, ":"),
+			"Primary GPU first for wlroots compositors")
+		ans.SetRuntime("KWIN_DRM_DEVICES", strings.Join(devices, ":"),
+			"Primary GPU first for KWin")
+	}
+
+	return ans, nil
+}
+
+// Return the variables in shell format for eval.
+func (e *Environment) Shell() string {
+	var sb strings.Builder
+	for _, v := range e.Variables {
+		sb.WriteString(fmt.Sprintf("export %s=\"%s\"\n", v.Name, v.Value))
+	}
+	return sb.String()
+}
+
+// Return the content of the file in the format used by
+// env.d and environment.d.
+func (e *Environment) File() string {
+	var sb strings.Builder
+
+	sb.WriteString("# autogenerated file by gpu-configurator\n")
+	for _, v := range e.Variables {
+		if v.Runtime {
+			continue
+		}
+		if v.Comment != "" {
+			sb.WriteString("# " + v.Comment + "\n")
+		}
+		sb.WriteString(fmt.Sprintf("%s=\"%s\"\n", v.Name, v.Value))
+	}
+
+	return sb.String()
+}
+
+// Return the path of the file of the target.
+func GetTargetFile(target, envdDir, homeDir string) (string, error) {
+	return getTargetFile(target, envdDir, homeDir,
+		EnvdFileName, EnvironmentdFileName, UserEnvironmentdFileName)
+}
+
+// Return the path of the file of the target used by the video command.
+func GetVideoTargetFile(target, envdDir, homeDir string) (string, error) {
+	return getTargetFile(target, envdDir, homeDir,
+		EnvdVideoFileName, EnvironmentdVideoFileName, UserEnvironmentdVideoFileName)
+}
+
+func getTargetFile(target, envdDir, homeDir, envdFile, environmentdFile, userFile string) (string, error) {
+	switch target {
+	case TargetEnvd:
+		return filepath.Join(envdDir, envdFile), nil
+	case TargetEnvironmentd:
+		return filepath.Join(EnvironmentdDir, environmentdFile), nil
+	case TargetUser:
+		if homeDir == "" {
+			return "", fmt.Errorf("home directory not available")
+		}
+		return filepath.Join(homeDir, UserEnvironmentdDir, userFile), nil
+	default:
+		return "", fmt.Errorf("invalid target %s", target)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package environment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/video"
+)
+
+func TestSessionEnvironmentDRMDevices(t *testing.T) {
+	topology := &specs.GPUTopology{
+		GPUs: []*specs.GPUNode{
+			{BusId: "00:02.0", KernelDriver: "i915", Card: "card1"},
+			{BusId: "01:00.0", KernelDriver: "nvidia", Card: "card0"},
+		},
+		DisplayGPU: "01:00.0",
+	}
+
+	env, err := GetSessionEnvironment(topology, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"WLR_DRM_DEVICES", "KWIN_DRM_DEVICES"} {
+		v := env.GetVariable(name)
+		if v == nil {
+			t.Fatalf("%s not set", name)
+		}
+		if v.Value != "/dev/dri/card0:/dev/dri/card1" {
+			t.Errorf("%s = %s, want the display GPU first", name, v.Value)
+		}
+		if !v.Runtime {
+			t.Errorf("%s is not a runtime variable", name)
+		}
+		if !strings.Contains(env.Shell(), name) {
+			t.Errorf("%s not printed", name)
+		}
+		if strings.Contains(env.File(), name) {
+			t.Errorf("%s written in the file", name)
+		}
+	}
+
+	if !strings.Contains(env.File(), "GBM_BACKEND=\"nvidia-drm\"") {
+		t.Errorf("GBM_BACKEND not written in the file:\n%s", env.File())
+	}
+}
+
+func TestSetVideoDrivers(t *testing.T) {
+	tests := []struct {
+		drivers *video.Drivers
+		want    string
+	}{
+		{
+			&video.Drivers{VADriver: "nvidia", VDPAUDriver: "nvidia", NvdBackend: "egl"},
+			"export LIBVA_DRIVER_NAME=\"nvidia\"\nexport VDPAU_DRIVER=\"nvidia\"\nexport NVD_BACKEND=\"egl\"\n",
+		},
+		{
+			&video.Drivers{VADriver: "iHD", NvdBackend: "egl"},
+			"export LIBVA_DRIVER_NAME=\"iHD\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		env := NewEnvironment()
+		env.SetVideoDrivers(tt.drivers)
+		if env.Shell() != tt.want {
+			t.Errorf("%+v:\n%s\nwant:\n%s", tt.drivers, env.Shell(), tt.want)
+		}
+	}
+}
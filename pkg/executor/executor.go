@@ -0,0 +1,388 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+type Executor struct {
+	RootDir  string
+	StateDir string
+	DryRun   bool
+	// The driver version related to the artifacts created.
+	Version string
+
+	// All the operations applied or planned.
+	Plan *specs.OperationsPlan
+
+	// The journal of the operations applied in the
+	// current transaction.
+	journal *Journal
+	// The manifest of the managed artifacts.
+	manifest *specs.StateManifest
+	// The directories of the files linked by the managed links.
+	// An existing link to another directory and not recorded
+	// in the manifest is never replaced.
+	managedPrefixes []string
+}
+
+func NewExecutor(rootDir, stateDir string, dryRun bool) *Executor {
+	return &Executor{
+		RootDir:  rootDir,
+		StateDir: stateDir,
+		DryRun:   dryRun,
+		Plan:     specs.NewOperationsPlan(),
+		journal:  nil,
+	}
+}
+
+func (e *Executor) IsDryRun() bool                 { return e.DryRun }
+func (e *Executor) GetPlan() *specs.OperationsPlan { return e.Plan }
+func (e *Executor) InTransaction() bool            { return e.journal != nil }
+func (e *Executor) SetVersion(v string)            { e.Version = v }
+
+func (e *Executor) AddManagedPrefix(dir string) {
+	e.managedPrefixes = append(e.managedPrefixes, filepath.Clean(dir))
+}
+
+// Apply the operations of the plan in order. In dry-run mode
+// the operations are only stored. The operations that don't
+// change the artifact are skipped.
+func (e *Executor) Apply(plan *specs.OperationsPlan) error {
+	for _, op := range plan.Operations {
+		status, err := e.reconcile(op)
+		if err != nil {
+			return err
+		}
+		op.Status = status
+
+		if !e.DryRun && !op.IsUnchanged() {
+			if e.journal != nil {
+				// POST: the entry is registered before the execution
+				//       to restore also an operation partially applied.
+				err := e.journal.Record(op)
+				if err != nil {
+					return err
+				}
+			}
+
+			err := e.execute(op)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !e.DryRun {
+			err = e.record(op)
+			if err != nil {
+				return err
+			}
+		}
+		e.Plan.Add(op)
+	}
+	e.Plan.Diagnostics = append(e.Plan.Diagnostics, plan.Diagnostics...)
+
+	// POST: inside a transaction the manifest is saved on commit.
+	if e.journal == nil {
+		return e.SaveManifest()
+	}
+
+	return nil
+}
+
+// Begin a new transaction. All the operations applied until
+// the Commit are registered in the journal and could be reverted
+// with Rollback.
+func (e *Executor) Begin() error {
+	if e.journal != nil {
+		return fmt.Errorf("transaction already started")
+	}
+
+	e.journal = NewJournal(e.RootDir,
+		filepath.Join(e.realPath(e.StateDir), "backup",
+			fmt.Sprintf("%d", time.Now().UnixNano())),
+	)
+	return nil
+}
+
+// Close the current transaction, store the manifest and drop
+// the backup files.
+func (e *Executor) Commit() error {
+	if e.journal == nil {
+		return nil
+	}
+	defer func() { e.journal = nil }()
+
+	err := e.SaveManifest()
+	if err != nil {
+		return err
+	}
+
+	return e.journal.Clean()
+}
+
+// Revert all the operations applied in the current transaction
+// in reverse order.
+func (e *Executor) Rollback() error {
+	if e.journal == nil {
+		return nil
+	}
+	defer func() { e.journal = nil }()
+
+	// POST: the manifest is reloaded from disk on next use.
+	e.manifest = nil
+
+	return e.journal.Revert()
+}
+
+func (e *Executor) realPath(p string) string {
+	return rootfs.RealPath(e.RootDir, p)
+}
+
+// Compare the current state of the artifact with the operation
+// and return the status of the artifact after the operation.
+// Regular files are never replaced by links.
+func (e *Executor) reconcile(op *specs.FileOperation) (string, error) {
+	path := e.realPath(op.Path)
+
+	finfo, err := os.Lstat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	exists := err == nil
+
+	switch op.Type {
+	case specs.OperationCreateLink:
+		if !exists {
+			return specs.StatusCreated, nil
+		}
+		if finfo.Mode()&os.ModeSymlink == 0 {
+			return "", fmt.Errorf(
+				"refuse to replace %s with a link: the file is not a link",
+				op.Path)
+		}
+		linked, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if linked == op.Target {
+			return specs.StatusUnchanged, nil
+		}
+
+		managed, err := e.isManagedLink(op.Path, linked)
+		if err != nil {
+			return "", err
+		}
+		if !managed {
+			return "", fmt.Errorf(
+				"refuse to replace %s: the link points to %s not managed",
+				op.Path, linked)
+		}
+
+	case specs.OperationWriteFile, specs.OperationCopyFile:
+		if !exists {
+			return specs.StatusCreated, nil
+		}
+		if !finfo.Mode().IsRegular() {
+			return "", fmt.Errorf(
+				"refuse to replace %s: the file is not a regular file",
+				op.Path)
+		}
+
+		content := []byte(op.Content)
+		mode := os.FileMode(op.Mode)
+		if op.Type == specs.OperationCopyFile {
+			source, err := rootfs.ResolvePath(e.RootDir, op.Source)
+			if err != nil {
+				return "", err
+			}
+			content, err = os.ReadFile(source)
+			if err != nil {
+				return "", err
+			}
+			if mode == 0 {
+				sinfo, err := os.Stat(source)
+				if err != nil {
+					return "", err
+				}
+				mode = sinfo.Mode().Perm()
+			}
+		} else if mode == 0 {
+			mode = 0644
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(data, content) && finfo.Mode().Perm() == mode {
+			return specs.StatusUnchanged, nil
+		}
+
+		m, err := e.GetManifest()
+		if err != nil {
+			return "", err
+		}
+		if m.GetArtifact(op.Path) == nil {
+			return "", fmt.Errorf(
+				"refuse to replace %s: the file is not managed", op.Path)
+		}
+
+	case specs.OperationRemove:
+		if !exists {
+			return specs.StatusUnchanged, nil
+		}
+		return specs.StatusRemoved, nil
+
+	case specs.OperationMkdir:
+		if exists && finfo.IsDir() {
+			return specs.StatusUnchanged, nil
+		}
+		return specs.StatusCreated, nil
+	}
+
+	return specs.StatusUpdated, nil
+}
+
+// Check if the existing link is been created by gpu-configurator:
+// the link is recorded in the manifest or points to a managed directory.
+func (e *Executor) isManagedLink(p, linked string) (bool, error) {
+	if !filepath.IsAbs(linked) {
+		linked = filepath.Join(filepath.Dir(p), linked)
+	}
+	linked = filepath.Clean(linked)
+
+	for _, prefix := range e.managedPrefixes {
+		if strings.HasPrefix(linked, prefix+"/") {
+			return true, nil
+		}
+	}
+
+	m, err := e.GetManifest()
+	if err != nil {
+		return false, err
+	}
+
+	return m.GetArtifact(p) != nil, nil
+}
+
+func (e *Executor) execute(op *specs.FileOperation) error {
+	var err error
+	path := e.realPath(op.Path)
+
+	switch op.Type {
+	case specs.OperationCreateLink:
+		if op.Status == specs.StatusUpdated {
+			// POST: the link points to another file.
+			err = os.Remove(path)
+			if err != nil {
+				return fmt.Errorf("error on remove link %s: %s",
+					op.Path, err.Error())
+			}
+		}
+		err = os.Symlink(op.Target, path)
+		if err != nil {
+			return fmt.Errorf("error on linking file %s to %s: %s",
+				op.Target, op.Path, err.Error())
+		}
+
+	case specs.OperationWriteFile:
+		mode := os.FileMode(op.Mode)
+		if mode == 0 {
+			mode = 0644
+		}
+		err = os.WriteFile(path, []byte(op.Content), mode)
+		if err == nil {
+			// POST: the mode is not applied if the file already exists.
+			err = os.Chmod(path, mode)
+		}
+		if err != nil {
+			return fmt.Errorf("error on write file %s: %s",
+				op.Path, err.Error())
+		}
+
+	case specs.OperationCopyFile:
+		err = e.copyFile(op)
+		if err != nil {
+			return fmt.Errorf("error on copy file %s to %s: %s",
+				op.Source, op.Path, err.Error())
+		}
+
+	case specs.OperationRename:
+		err = os.Rename(path, e.realPath(op.Target))
+		if err != nil {
+			return fmt.Errorf("error on rename file %s to %s: %s",
+				op.Path, op.Target, err.Error())
+		}
+
+	case specs.OperationRemove:
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("error on remove file %s: %s",
+				op.Path, err.Error())
+		}
+
+	case specs.OperationMkdir:
+		err = os.MkdirAll(path, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("error on create dir %s: %s",
+				op.Path, err.Error())
+		}
+
+	default:
+		return fmt.Errorf("invalid operation %s on %s", op.Type, op.Path)
+	}
+
+	return nil
+}
+
+func (e *Executor) copyFile(op *specs.FileOperation) error {
+	source, err := rootfs.ResolvePath(e.RootDir, op.Source)
+	if err != nil {
+		return err
+	}
+
+	sourcefd, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourcefd.Close()
+
+	// Preserve the permissions of the source file if the mode
+	// is not defined. The init scripts must be executable.
+	mode := os.FileMode(op.Mode)
+	if mode == 0 {
+		finfo, err := sourcefd.Stat()
+		if err != nil {
+			return err
+		}
+		mode = finfo.Mode().Perm()
+	}
+
+	// Open destination file (truncate it if exists)
+	tfd, err := os.OpenFile(e.realPath(op.Path),
+		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer tfd.Close()
+
+	_, err = io.Copy(tfd, sourcefd)
+	if err != nil {
+		return err
+	}
+
+	return tfd.Chmod(mode)
+}
@@ -0,0 +1,424 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs/testutil"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const testStateDir = "/var/lib/gpu-configurator"
+
+func readLink(t *testing.T, path string) string {
+	t.Helper()
+	linked, err := os.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return linked
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// The paths recorded in the manifest.
+		recorded   []string
+		op         *specs.FileOperation
+		wantStatus string
+		wantErr    bool
+		check      func(t *testing.T, root string)
+	}{
+		{
+			name:  "create link",
+			files: map[string]string{"/opt/nvidia/nvidia-smi": "bin"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/opt/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			wantStatus: specs.StatusCreated,
+			check: func(t *testing.T, root string) {
+				if l := readLink(t, filepath.Join(root, "opt/nvidia-smi")); l != "/opt/nvidia/nvidia-smi" {
+					t.Errorf("link points to %s", l)
+				}
+			},
+		},
+		{
+			name:  "link unchanged",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/opt/nvidia/nvidia-smi"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			wantStatus: specs.StatusUnchanged,
+		},
+		{
+			name:  "link updated",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/opt/nvidia/old/nvidia-smi"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/new/nvidia-smi"},
+			wantStatus: specs.StatusUpdated,
+			check: func(t *testing.T, root string) {
+				if l := readLink(t, filepath.Join(root, "usr/bin/nvidia-smi")); l != "/opt/nvidia/new/nvidia-smi" {
+					t.Errorf("link points to %s", l)
+				}
+			},
+		},
+		{
+			name:  "foreign link not replaced",
+			files: map[string]string{"/usr/bin/nvidia-smi": "->/usr/local/bin/nvidia-smi"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			wantErr: true,
+			check: func(t *testing.T, root string) {
+				if l := readLink(t, filepath.Join(root, "usr/bin/nvidia-smi")); l != "/usr/local/bin/nvidia-smi" {
+					t.Errorf("link points to %s", l)
+				}
+			},
+		},
+		{
+			name:  "relative link escaping the managed prefix not replaced",
+			files: map[string]string{"/opt/nvidia/nvidia-smi": "->../../usr/local/bin/nvidia-smi"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/opt/nvidia/nvidia-smi", Target: "/opt/nvidia/new/nvidia-smi"},
+			wantErr: true,
+		},
+		{
+			name:     "recorded link replaced",
+			files:    map[string]string{"/usr/bin/nvidia-smi": "->/usr/local/bin/nvidia-smi"},
+			recorded: []string{"/usr/bin/nvidia-smi"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			wantStatus: specs.StatusUpdated,
+		},
+		{
+			name:  "foreign file not replaced by a link",
+			files: map[string]string{"/usr/bin/nvidia-smi": "foreign"},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			wantErr: true,
+			check: func(t *testing.T, root string) {
+				if c := readFile(t, filepath.Join(root, "usr/bin/nvidia-smi")); c != "foreign" {
+					t.Errorf("file content changed to %s", c)
+				}
+			},
+		},
+		{
+			name:  "write unchanged",
+			files: map[string]string{"/etc/env.d/09nvidia": "NVIDIA_DRIVER_VERSION=555.2\n"},
+			op: &specs.FileOperation{Type: specs.OperationWriteFile,
+				Path: "/etc/env.d/09nvidia", Content: "NVIDIA_DRIVER_VERSION=555.2\n"},
+			wantStatus: specs.StatusUnchanged,
+		},
+		{
+			name:     "write updated",
+			files:    map[string]string{"/etc/env.d/09nvidia": "NVIDIA_DRIVER_VERSION=550.1\n"},
+			recorded: []string{"/etc/env.d/09nvidia"},
+			op: &specs.FileOperation{Type: specs.OperationWriteFile,
+				Path: "/etc/env.d/09nvidia", Content: "NVIDIA_DRIVER_VERSION=555.2\n"},
+			wantStatus: specs.StatusUpdated,
+			check: func(t *testing.T, root string) {
+				if c := readFile(t, filepath.Join(root, "etc/env.d/09nvidia")); c != "NVIDIA_DRIVER_VERSION=555.2\n" {
+					t.Errorf("file content %s", c)
+				}
+			},
+		},
+		{
+			name:  "foreign file not replaced",
+			files: map[string]string{"/etc/modprobe.d/nvidia.conf": "options nvidia NVreg_PreserveVideoMemoryAllocations=1\n"},
+			op: &specs.FileOperation{Type: specs.OperationWriteFile,
+				Path: "/etc/modprobe.d/nvidia.conf", Content: "options nvidia-drm modeset=1\n"},
+			wantErr: true,
+			check: func(t *testing.T, root string) {
+				if c := readFile(t, filepath.Join(root, "etc/modprobe.d/nvidia.conf")); c != "options nvidia NVreg_PreserveVideoMemoryAllocations=1\n" {
+					t.Errorf("file content changed to %s", c)
+				}
+			},
+		},
+		{
+			name:  "foreign file not replaced by a copy",
+			files: map[string]string{"/etc/OpenCL/vendors/nvidia.icd": "libnvidia-opencl.so.1\n", "/opt/nvidia/nvidia.icd": "other\n"},
+			op: &specs.FileOperation{Type: specs.OperationCopyFile,
+				Path: "/etc/OpenCL/vendors/nvidia.icd", Source: "/opt/nvidia/nvidia.icd"},
+			wantErr: true,
+		},
+		{
+			name:  "rename",
+			files: map[string]string{"/etc/X11/xorg.conf.d/10-nvidia.conf": "conf"},
+			op: &specs.FileOperation{Type: specs.OperationRename,
+				Path: "/etc/X11/xorg.conf.d/10-nvidia.conf", Target: "/etc/X11/xorg.conf.d/10-nvidia.conf.disabled"},
+			wantStatus: specs.StatusUpdated,
+			check: func(t *testing.T, root string) {
+				if c := readFile(t, filepath.Join(root, "etc/X11/xorg.conf.d/10-nvidia.conf.disabled")); c != "conf" {
+					t.Errorf("file content %s", c)
+				}
+			},
+		},
+		{
+			name:       "remove missing file",
+			files:      map[string]string{},
+			op:         &specs.FileOperation{Type: specs.OperationRemove, Path: "/etc/env.d/09nvidia"},
+			wantStatus: specs.StatusUnchanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := testutil.NewFakeRoot(t, tt.files)
+			e := NewExecutor(root, testStateDir, false)
+			e.AddManagedPrefix("/opt/nvidia")
+
+			m, err := e.GetManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range tt.recorded {
+				m.Record(&specs.ManagedArtifact{Path: p, Type: tt.op.Type})
+			}
+
+			plan := specs.NewOperationsPlan()
+			plan.Add(tt.op)
+
+			err = e.Apply(plan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.op.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", tt.op.Status, tt.wantStatus)
+			}
+			if tt.check != nil {
+				tt.check(t, root)
+			}
+		})
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	root := testutil.NewFakeRoot(t, map[string]string{})
+	e := NewExecutor(root, testStateDir, true)
+
+	plan := specs.NewOperationsPlan()
+	plan.CreateLink("/usr/bin/nvidia-smi", "/opt/nvidia/nvidia-smi")
+
+	if err := e.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.GetPlan().Operations) != 1 {
+		t.Errorf("operations planned = %d, want 1", len(e.GetPlan().Operations))
+	}
+	if _, err := os.Lstat(filepath.Join(root, "usr/bin/nvidia-smi")); !os.IsNotExist(err) {
+		t.Errorf("link created in dry-run mode")
+	}
+	if _, err := os.Stat(filepath.Join(root, testStateDir)); !os.IsNotExist(err) {
+		t.Errorf("state directory created in dry-run mode")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	root := testutil.NewFakeRoot(t, map[string]string{
+		"/etc/env.d/09nvidia":  "NVIDIA_DRIVER_VERSION=550.1\n",
+		"/usr/bin/nvidia-smi":  "->/opt/nvidia/550.1/nvidia-smi",
+		"/etc/conf.d/nvidia":   "conf",
+		"/etc/X11/10-nv.conf":  "xorg",
+		"/opt/nvidia/555.2/ok": "",
+	})
+	e := NewExecutor(root, testStateDir, false)
+	e.AddManagedPrefix("/opt/nvidia")
+
+	m, err := e.GetManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Record(&specs.ManagedArtifact{Path: "/etc/env.d/09nvidia", Type: specs.OperationWriteFile})
+
+	if err := e.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := specs.NewOperationsPlan()
+	plan.WriteFile("/etc/env.d/09nvidia", "NVIDIA_DRIVER_VERSION=555.2\n", 0644)
+	plan.CreateLink("/usr/bin/nvidia-smi", "/opt/nvidia/555.2/nvidia-smi")
+	plan.Mkdir("/usr/share/nvidia/profiles")
+	plan.CreateLink("/usr/share/nvidia/profiles/app.json", "/opt/nvidia/555.2/app.json")
+	plan.Remove("/etc/conf.d/nvidia")
+	plan.Rename("/etc/X11/10-nv.conf", "/etc/X11/10-nv.conf.disabled")
+
+	if err := e.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+	if c := readFile(t, filepath.Join(root, "etc/env.d/09nvidia")); c != "NVIDIA_DRIVER_VERSION=555.2\n" {
+		t.Fatalf("file not written before the rollback: %s", c)
+	}
+
+	if err := e.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := readFile(t, filepath.Join(root, "etc/env.d/09nvidia")); c != "NVIDIA_DRIVER_VERSION=550.1\n" {
+		t.Errorf("file not restored: %s", c)
+	}
+	if l := readLink(t, filepath.Join(root, "usr/bin/nvidia-smi")); l != "/opt/nvidia/550.1/nvidia-smi" {
+		t.Errorf("link not restored: %s", l)
+	}
+	if c := readFile(t, filepath.Join(root, "etc/conf.d/nvidia")); c != "conf" {
+		t.Errorf("removed file not restored: %s", c)
+	}
+	if c := readFile(t, filepath.Join(root, "etc/X11/10-nv.conf")); c != "xorg" {
+		t.Errorf("renamed file not restored: %s", c)
+	}
+	for _, p := range []string{"etc/X11/10-nv.conf.disabled", "usr/share/nvidia"} {
+		if _, err := os.Lstat(filepath.Join(root, p)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed by the rollback", p)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, testStateDir, specs.StateManifestFile)); !os.IsNotExist(err) {
+		t.Errorf("manifest saved without commit")
+	}
+	if e.InTransaction() {
+		t.Errorf("transaction still open after the rollback")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		artifacts []*specs.ManagedArtifact
+		op        *specs.FileOperation
+		version   string
+		want      map[string]string
+	}{
+		{
+			name: "rename moves the artifact",
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/etc/X11/10-nv.conf", Type: specs.OperationCopyFile, Version: "555.2"},
+			},
+			op: &specs.FileOperation{Type: specs.OperationRename,
+				Path: "/etc/X11/10-nv.conf", Target: "/etc/X11/10-nv.conf.disabled"},
+			want: map[string]string{"/etc/X11/10-nv.conf.disabled": "555.2"},
+		},
+		{
+			name: "rename of a foreign file is not recorded",
+			op: &specs.FileOperation{Type: specs.OperationRename,
+				Path: "/etc/X11/10-user.conf", Target: "/etc/X11/10-user.conf.disabled"},
+			want: map[string]string{},
+		},
+		{
+			name: "remove forgets the artifact",
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink, Version: "555.2"},
+			},
+			op:   &specs.FileOperation{Type: specs.OperationRemove, Path: "/usr/bin/nvidia-smi"},
+			want: map[string]string{},
+		},
+		{
+			name: "remove of a foreign file keeps the other artifacts",
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink, Version: "555.2"},
+			},
+			op:   &specs.FileOperation{Type: specs.OperationRemove, Path: "/usr/bin/foreign"},
+			want: map[string]string{"/usr/bin/nvidia-smi": "555.2"},
+		},
+		{
+			name: "unchanged artifact of the same version is kept",
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink, Version: "555.2",
+					Timestamp: "2024-01-01T00:00:00Z"},
+			},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink, Status: specs.StatusUnchanged,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			version: "555.2",
+			want:    map[string]string{"/usr/bin/nvidia-smi": "555.2"},
+		},
+		{
+			name: "unchanged artifact of another version is recorded",
+			artifacts: []*specs.ManagedArtifact{
+				{Path: "/usr/bin/nvidia-smi", Type: specs.OperationCreateLink, Version: "550.1"},
+			},
+			op: &specs.FileOperation{Type: specs.OperationCreateLink, Status: specs.StatusUnchanged,
+				Path: "/usr/bin/nvidia-smi", Target: "/opt/nvidia/nvidia-smi"},
+			version: "555.2",
+			want:    map[string]string{"/usr/bin/nvidia-smi": "555.2"},
+		},
+		{
+			name: "unchanged foreign file is not recorded",
+			op: &specs.FileOperation{Type: specs.OperationCopyFile, Status: specs.StatusUnchanged,
+				Path: "/etc/OpenCL/vendors/nvidia.icd", Source: "/opt/nvidia/nvidia.icd"},
+			version: "555.2",
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecutor(t.TempDir(), testStateDir, false)
+			e.SetVersion(tt.version)
+
+			m, err := e.GetManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range tt.artifacts {
+				m.Record(a)
+			}
+
+			if err := e.record(tt.op); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(m.Artifacts) != len(tt.want) {
+				t.Fatalf("artifacts = %d, want %d", len(m.Artifacts), len(tt.want))
+			}
+			for p, v := range tt.want {
+				a := m.GetArtifact(p)
+				if a == nil {
+					t.Fatalf("artifact %s not recorded", p)
+				}
+				if a.Version != v {
+					t.Errorf("artifact %s version = %s, want %s", p, a.Version, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordChecksum(t *testing.T) {
+	root := testutil.NewFakeRoot(t, map[string]string{"/etc/env.d/00basic": ""})
+	e := NewExecutor(root, testStateDir, false)
+
+	plan := specs.NewOperationsPlan()
+	plan.WriteFile("/etc/env.d/09nvidia", "NVIDIA_DRIVER_VERSION=555.2\n", 0644)
+	if err := e.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	// POST: the manifest is reloaded from the state directory.
+	e = NewExecutor(root, testStateDir, false)
+	m, err := e.GetManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := m.GetArtifact("/etc/env.d/09nvidia")
+	if a == nil {
+		t.Fatal("artifact not recorded in the manifest")
+	}
+	checksum, err := Checksum(filepath.Join(root, "etc/env.d/09nvidia"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Checksum != checksum {
+		t.Errorf("checksum = %s, want %s", a.Checksum, checksum)
+	}
+}
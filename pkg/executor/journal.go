@@ -0,0 +1,235 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const (
+	snapshotNone = "none"
+	snapshotLink = "link"
+	snapshotFile = "file"
+	snapshotDir  = "dir"
+)
+
+// The state of a path before the execution of an operation.
+type Snapshot struct {
+	// The real path on disk
+	Path       string
+	Kind       string
+	LinkTarget string
+	Backup     string
+	Mode       os.FileMode
+}
+
+type JournalEntry struct {
+	Operation *specs.FileOperation
+	Snapshots []*Snapshot
+	// Directories created by a mkdir operation.
+	Dirs []string
+}
+
+type Journal struct {
+	RootDir   string
+	BackupDir string
+	Entries   []*JournalEntry
+
+	nBackups int
+}
+
+func NewJournal(rootDir, backupDir string) *Journal {
+	return &Journal{
+		RootDir:   rootDir,
+		BackupDir: backupDir,
+		Entries:   []*JournalEntry{},
+	}
+}
+
+// Register the operation and the state of the paths
+// that will be modified by the operation.
+func (j *Journal) Record(op *specs.FileOperation) error {
+	entry := &JournalEntry{
+		Operation: op,
+		Snapshots: []*Snapshot{},
+		Dirs:      []string{},
+	}
+
+	paths := []string{}
+	switch op.Type {
+	case specs.OperationMkdir:
+		// Store the directories not present from the
+		// leaf to the first existing parent.
+		dir := rootfs.RealPath(j.RootDir, op.Path)
+		for dir != "/" && dir != "." && !rootfs.Lexists(dir) {
+			entry.Dirs = append(entry.Dirs, dir)
+			dir = filepath.Dir(dir)
+		}
+	case specs.OperationRename:
+		paths = append(paths, op.Path, op.Target)
+	default:
+		paths = append(paths, op.Path)
+	}
+
+	for _, p := range paths {
+		s, err := j.snapshot(rootfs.RealPath(j.RootDir, p))
+		if err != nil {
+			return fmt.Errorf("error on backup file %s: %s", p, err.Error())
+		}
+		entry.Snapshots = append(entry.Snapshots, s)
+	}
+
+	j.Entries = append(j.Entries, entry)
+
+	return nil
+}
+
+// Revert the operations registered in reverse order. On error
+// the backup directory is maintained for a manual restore.
+func (j *Journal) Revert() error {
+	var errs []string
+
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+
+		for _, dir := range entry.Dirs {
+			err := os.Remove(dir)
+			if err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		for idx := len(entry.Snapshots) - 1; idx >= 0; idx-- {
+			err := entry.Snapshots[idx].Restore()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf(
+					"error on restore %s: %s",
+					entry.Snapshots[idx].Path, err.Error()))
+			}
+		}
+	}
+
+	j.Entries = []*JournalEntry{}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s (backup files available under %s)",
+			strings.Join(errs, "; "), j.BackupDir)
+	}
+
+	return j.Clean()
+}
+
+// Remove the backup files.
+func (j *Journal) Clean() error {
+	if j.nBackups == 0 {
+		return nil
+	}
+	return os.RemoveAll(j.BackupDir)
+}
+
+func (j *Journal) snapshot(path string) (*Snapshot, error) {
+	ans := &Snapshot{
+		Path: path,
+		Kind: snapshotNone,
+	}
+
+	finfo, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ans, nil
+		}
+		return nil, err
+	}
+
+	ans.Mode = finfo.Mode().Perm()
+
+	switch {
+	case finfo.Mode()&os.ModeSymlink != 0:
+		ans.Kind = snapshotLink
+		ans.LinkTarget, err = os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+	case finfo.IsDir():
+		ans.Kind = snapshotDir
+
+	case finfo.Mode().IsRegular():
+		err = os.MkdirAll(j.BackupDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		j.nBackups++
+		ans.Kind = snapshotFile
+		ans.Backup = filepath.Join(j.BackupDir, fmt.Sprintf("%d-%s",
+			j.nBackups, filepath.Base(path)))
+		err = copyRegularFile(path, ans.Backup, ans.Mode)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New("unsupported file type")
+	}
+
+	return ans, nil
+}
+
+// Restore the path to the state stored in the snapshot.
+func (s *Snapshot) Restore() error {
+	if s.Kind == snapshotDir {
+		if finfo, err := os.Lstat(s.Path); err == nil && finfo.IsDir() {
+			return nil
+		}
+	}
+
+	err := os.Remove(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch s.Kind {
+	case snapshotLink:
+		return os.Symlink(s.LinkTarget, s.Path)
+	case snapshotFile:
+		return copyRegularFile(s.Backup, s.Path, s.Mode)
+	case snapshotDir:
+		return os.MkdirAll(s.Path, s.Mode)
+	}
+
+	return nil
+}
+
+func copyRegularFile(source, target string, mode os.FileMode) error {
+	sourcefd, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourcefd.Close()
+
+	tfd, err := os.OpenFile(target,
+		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer tfd.Close()
+
+	_, err = io.Copy(tfd, sourcefd)
+	if err != nil {
+		return err
+	}
+
+	// POST: the mode is not applied if the file already exists.
+	return tfd.Chmod(mode)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"fmt"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Print the operations of the plan in the selected
+// output format (terminal, yaml, json).
+func PrintPlan(plan *specs.OperationsPlan, output string) error {
+	var err error
+	var data []byte
+
+	switch output {
+	case "json":
+		data, err = plan.Json()
+	case "yaml":
+		data, err = plan.Yaml()
+	default:
+		printDiagnostics(plan)
+		if len(plan.Operations) == 0 {
+			fmt.Println("[dry-run mode] No operations to execute.")
+			return nil
+		}
+		fmt.Println("[dry-run mode] Operations planned:")
+		for idx, op := range plan.Operations {
+			fmt.Println(fmt.Sprintf("%4d. %s (%s)", idx+1, op.String(), op.Status))
+		}
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// Print the status of the artifacts managed by the operations
+// applied. In terminal mode the unchanged artifacts are
+// only counted.
+func PrintReport(plan *specs.OperationsPlan, output string) error {
+	var err error
+	var data []byte
+
+	switch output {
+	case "json":
+		data, err = plan.Json()
+	case "yaml":
+		data, err = plan.Yaml()
+	default:
+		printDiagnostics(plan)
+		for _, op := range plan.Operations {
+			if op.IsUnchanged() {
+				continue
+			}
+			fmt.Println(fmt.Sprintf("%10s %s", op.Status, op.Path))
+		}
+
+		counters := plan.CountByStatus()
+		fmt.Println(fmt.Sprintf(
+			"Artifacts: %d created, %d updated, %d removed, %d unchanged.",
+			counters[specs.StatusCreated],
+			counters[specs.StatusUpdated],
+			counters[specs.StatusRemoved],
+			counters[specs.StatusUnchanged],
+		))
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+func printDiagnostics(plan *specs.OperationsPlan) {
+	for _, d := range plan.Diagnostics {
+		fmt.Println(fmt.Sprintf("WARNING [%s] %s: %s", d.Kind, d.Path, d.Error))
+	}
+}
+
+// Validate the output format of the plan.
+func ValidOutput(output string) bool {
+	switch output {
+	case "", "terminal", "json", "yaml":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+// Return the manifest of the artifacts managed by gpu-configurator.
+// The manifest is loaded from the state directory on first use.
+func (e *Executor) GetManifest() (*specs.StateManifest, error) {
+	if e.manifest != nil {
+		return e.manifest, nil
+	}
+
+	f := e.realPath(filepath.Join(e.StateDir, specs.StateManifestFile))
+	data, err := os.ReadFile(f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			e.manifest = specs.NewStateManifest()
+			return e.manifest, nil
+		}
+		return nil, err
+	}
+
+	e.manifest, err = specs.StateManifestFromYaml(data)
+	if err != nil {
+		return nil, fmt.Errorf("error on parse state file %s: %s",
+			f, err.Error())
+	}
+
+	return e.manifest, nil
+}
+
+// Write the manifest under the state directory.
+func (e *Executor) SaveManifest() error {
+	if e.DryRun || e.manifest == nil {
+		return nil
+	}
+
+	dir := e.realPath(e.StateDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return e.manifest.WriteFile(filepath.Join(dir, specs.StateManifestFile))
+}
+
+// Update the manifest with the artifact managed by the operation.
+func (e *Executor) record(op *specs.FileOperation) error {
+	m, err := e.GetManifest()
+	if err != nil {
+		return err
+	}
+
+	switch op.Type {
+	case specs.OperationRemove:
+		m.Forget(op.Path)
+
+	case specs.OperationRename:
+		a := m.GetArtifact(op.Path)
+		if a != nil {
+			m.Forget(op.Path)
+			a.Path = op.Target
+			m.Record(a)
+		}
+
+	case specs.OperationCreateLink, specs.OperationWriteFile, specs.OperationCopyFile:
+		a := m.GetArtifact(op.Path)
+		// POST: an unchanged file not recorded is not created
+		//       by gpu-configurator (ex. a file of a package).
+		if op.IsUnchanged() && (a == nil || a.Version == e.Version) {
+			return nil
+		}
+
+		a = &specs.ManagedArtifact{
+			Path:      op.Path,
+			Type:      op.Type,
+			Version:   e.Version,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+
+		if op.Type == specs.OperationCreateLink {
+			a.Target = op.Target
+		} else {
+			a.Target = op.Source
+			a.Checksum, err = Checksum(e.realPath(op.Path))
+			if err != nil {
+				return err
+			}
+		}
+
+		m.Record(a)
+	}
+
+	return nil
+}
+
+// Return the sha256 checksum of the file.
+func Checksum(f string) (string, error) {
+	fd, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fd); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -38,7 +38,7 @@ func ModinfoField(fpath, field string) (string, error) {
 	}
 
 	if cmd.ProcessState.ExitCode() != 0 {
-		return "", fmt.Errorf("modinfo exiting with %s: %s",
+		return "", fmt.Errorf("modinfo exiting with %d: %s",
 			cmd.ProcessState.ExitCode(),
 			errBuffer.String())
 	}
@@ -75,7 +75,7 @@ func GetRuntimeKernelVersion() (string, error) {
 	}
 
 	if cmd.ProcessState.ExitCode() != 0 {
-		return "", fmt.Errorf("%s exiting with %s: %s",
+		return "", fmt.Errorf("%s exiting with %d: %s",
 			binary, cmd.ProcessState.ExitCode(),
 			errBuffer.String())
 	}
@@ -0,0 +1,142 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package prime
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+	"github.com/macaroni-os/gpu-configurator/pkg/video"
+	"github.com/macaroni-os/gpu-configurator/pkg/vulkan"
+)
+
+const (
+	NvidiaEglVendorFile = "/usr/share/glvnd/egl_vendor.d/10_nvidia.json"
+)
+
+// Select the GPU from the topology. The selector could be the index
+// of the GPU, the bus id (with or without domain) or the vendor.
+// Without selector the first discrete GPU that doesn't drive
+// the outputs is selected.
+func SelectGPU(topology *specs.GPUTopology, selector string) (*specs.GPUNode, error) {
+	if topology == nil || len(topology.GPUs) == 0 {
+		return nil, fmt.Errorf("no GPUs available")
+	}
+
+	if selector == "" {
+		for _, gpu := range topology.GetGPUsByRole(specs.GPURoleDiscrete) {
+			if gpu.BusId != topology.DisplayGPU {
+				return gpu, nil
+			}
+		}
+		return nil, fmt.Errorf("no GPU available for render offload")
+	}
+
+	if idx, err := strconv.Atoi(selector); err == nil {
+		if idx < 0 || idx >= len(topology.GPUs) {
+			return nil, fmt.Errorf("invalid GPU index %d", idx)
+		}
+		return topology.GPUs[idx], nil
+	}
+
+	if gpu := topology.GetGPU(strings.TrimPrefix(selector, "0000:")); gpu != nil {
+		return gpu, nil
+	}
+
+	for _, gpu := range topology.GPUs {
+		if gpu.Vendor == strings.ToLower(selector) {
+			return gpu, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no GPU found for %s", selector)
+}
+
+// Return the environment variables to run a program with the
+// selected GPU for GLX, EGL, Vulkan and VA-API/VDPAU. The video
+// drivers are selected between the drivers of the setup.
+func GetEnvironment(gpu *specs.GPUNode, setup *specs.VideoSetup) (map[string]string, error) {
+	ans := make(map[string]string)
+
+	switch gpu.KernelDriver {
+	case "nvidia":
+		ans["__NV_PRIME_RENDER_OFFLOAD"] = "1"
+		ans["__GLX_VENDOR_LIBRARY_NAME"] = "nvidia"
+		ans["__EGL_VENDOR_LIBRARY_FILENAMES"] = NvidiaEglVendorFile
+		ans["__VK_LAYER_NV_optimus"] = "NVIDIA_only"
+
+	case "amdgpu", "radeon", "i915", "xe", "nouveau":
+		// Mesa drivers: DRI_PRIME is used by GL/EGL and by
+		// the Vulkan device select layer.
+		ans["DRI_PRIME"] = GetDriPrimeValue(gpu.BusId)
+		ans["MESA_VK_DEVICE_SELECT"] = gpu.Id
+
+	case "":
+		return nil, fmt.Errorf("no kernel driver in use for the GPU %s", gpu.BusId)
+
+	default:
+		return nil, fmt.Errorf("kernel driver %s of the GPU %s not supported",
+			gpu.KernelDriver, gpu.BusId)
+	}
+
+	for k, v := range video.GetDrivers(gpu.KernelDriver, setup).GetEnvironment() {
+		ans[k] = v
+	}
+
+	return ans, nil
+}
+
+// Return the DRI_PRIME value for the bus id.
+// Example: 01:00.0 -> pci-0000_01_00_0
+func GetDriPrimeValue(busId string) string {
+	if strings.Count(busId, ":") == 1 {
+		busId = "0000:" + busId
+	}
+	return "pci-" + strings.NewReplacer(":", "_", ".", "_").Replace(busId)
+}
+
+// Return the environment as a sorted list of KEY=VALUE.
+func EnvironmentToList(env map[string]string) []string {
+	ans := []string{}
+	for k, v := range env {
+		ans = append(ans, k+"="+v)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// Return the environment of the command: the offload variables
+// replace the variables already defined in environ.
+func GetCommandEnvironment(environ []string, env map[string]string) []string {
+	ans := vulkan.GetEnv(environ)
+	for k, v := range env {
+		ans[k] = v
+	}
+	return EnvironmentToList(ans)
+}
+
+// Return the content of a prime-run wrapper script that
+// exports the environment and runs the arguments.
+func GetWrapperScript(gpu *specs.GPUNode, env map[string]string) string {
+	var sb strings.Builder
+
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString("# autogenerated file by gpu-configurator\n")
+	sb.WriteString(fmt.Sprintf("# GPU: %s [%s] %s\n", gpu.Name, gpu.Id, gpu.BusId))
+	keys := []string{}
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("export %s=\"%s\"\n", k, env[k]))
+	}
+	sb.WriteString("exec \"$@\"\n")
+
+	return sb.String()
+}
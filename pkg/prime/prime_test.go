@@ -0,0 +1,37 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package prime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetCommandEnvironment(t *testing.T) {
+	environ := []string{
+		"HOME=/home/user",
+		"__GLX_VENDOR_LIBRARY_NAME=mesa",
+		"DRI_PRIME=0",
+		"OPTS=a=b",
+	}
+	env := map[string]string{
+		"__GLX_VENDOR_LIBRARY_NAME": "nvidia",
+		"__NV_PRIME_RENDER_OFFLOAD": "1",
+		"DRI_PRIME":                 "pci-0000_01_00_0",
+	}
+
+	want := []string{
+		"DRI_PRIME=pci-0000_01_00_0",
+		"HOME=/home/user",
+		"OPTS=a=b",
+		"__GLX_VENDOR_LIBRARY_NAME=nvidia",
+		"__NV_PRIME_RENDER_OFFLOAD=1",
+	}
+
+	got := GetCommandEnvironment(environ, env)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("environment = %v, want %v", got, want)
+	}
+}
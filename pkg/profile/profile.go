@@ -0,0 +1,294 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/analyzer"
+	"github.com/macaroni-os/gpu-configurator/pkg/environment"
+	"github.com/macaroni-os/gpu-configurator/pkg/logger"
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+const (
+	ModprobeDir      = "/etc/modprobe.d"
+	ModprobeFileName = "gpu-configurator.conf"
+)
+
+// Converge the system analyzed to the desired state of the profile.
+// All the operations are applied in a single transaction and
+// are available in the plan of the executor.
+func Apply(a *analyzer.Analyzer, p *specs.Profile) error {
+	log := logger.GetDefaultLogger()
+	exec := a.GetBackend().GetExecutor()
+	dryRun := exec.IsDryRun()
+
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = exec.Begin()
+	if err != nil {
+		return err
+	}
+
+	steps := []struct {
+		Description string
+		Run         func(*analyzer.Analyzer, *specs.Profile) error
+	}{
+		{"NVIDIA driver", applyNvidiaVersion},
+		{"NVIDIA GBM library", applyNvidiaGbmLib},
+		{"Vulkan ICD files", applyVulkanIcd},
+		{"Vulkan layers files", applyVulkanLayers},
+		{"EGL loaders", applyEglLoaders},
+		{"OpenCL vendors", applyOpenCLVendors},
+		{"Environment", applyEnvironment},
+		{"Kernel modules options", applyKernelModules},
+	}
+
+	for _, step := range steps {
+		err = step.Run(a, p)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", step.Description, err.Error())
+			if !dryRun {
+				log.Warning("Rolling back the applied operations...")
+			}
+			rerr := exec.Rollback()
+			if rerr != nil {
+				return fmt.Errorf("%s. Rollback failed: %s",
+					err.Error(), rerr.Error())
+			}
+			return err
+		}
+	}
+
+	return exec.Commit()
+}
+
+func applyNvidiaVersion(a *analyzer.Analyzer, p *specs.Profile) error {
+	if p.Nvidia == nil || p.Nvidia.Version == "" {
+		return nil
+	}
+
+	version := p.Nvidia.Version
+	nvidiaSetup := a.GetSystem().Nvidia
+	if !nvidiaSetup.HasVersion(version) {
+		return fmt.Errorf("NVIDIA driver version %s not available", version)
+	}
+
+	// POST: the setup of the active version is reconciled
+	//       without purge.
+	if nvidiaSetup.VersionActive != "" && nvidiaSetup.VersionActive != version {
+		err := a.GetBackend().PurgeNVIDIADriver(nvidiaSetup,
+			specs.NewNVIDIAPurgeOpts())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := a.GetBackend().SetNVIDIAVersion(nvidiaSetup, version)
+	if err != nil {
+		return err
+	}
+	nvidiaSetup.SetVersion(version)
+
+	// POST: the next artifacts are not purged with the driver.
+	a.GetBackend().GetExecutor().SetVersion("")
+
+	if !a.GetBackend().GetExecutor().IsDryRun() {
+		// POST: the files created by the driver setup are
+		//       available for the next steps.
+		a.System = specs.NewSystem()
+		return a.Read()
+	}
+
+	return nil
+}
+
+func applyNvidiaGbmLib(a *analyzer.Analyzer, p *specs.Profile) error {
+	if p.Nvidia == nil || p.Nvidia.GbmLib == nil {
+		return nil
+	}
+
+	plan := specs.NewOperationsPlan()
+	_, err := a.PrepareNVIDIAGbmLib(plan, *p.Nvidia.GbmLib, false)
+	if err != nil {
+		return err
+	}
+
+	return a.GetBackend().GetExecutor().Apply(plan)
+}
+
+type fileLookup func(string) (string, *specs.JsonFile)
+
+// Add the operations to enable and disable the files. The files
+// could be defined with or without the .disabled suffix. A file to
+// enable must be present; a file to disable that is not present
+// is already in the expected state.
+func applyFiles(a *analyzer.Analyzer, files *specs.ProfileFiles, lookup fileLookup) error {
+	if files == nil {
+		return nil
+	}
+
+	find := func(name string) (string, *specs.JsonFile) {
+		name = strings.TrimSuffix(name, specs.DisabledSuffix)
+		dir, f := lookup(name)
+		if f == nil {
+			dir, f = lookup(name + specs.DisabledSuffix)
+		}
+		return dir, f
+	}
+
+	plan := specs.NewOperationsPlan()
+	for _, name := range files.Enabled {
+		dir, f := find(name)
+		if f == nil {
+			return fmt.Errorf("no json file with name %s found", name)
+		}
+		plan.EnableFile(dir, f.Name, f.Disabled)
+	}
+
+	for _, name := range files.Disabled {
+		dir, f := find(name)
+		if f == nil {
+			continue
+		}
+		plan.DisableFile(dir, f.Name, f.Disabled)
+	}
+
+	return a.GetBackend().GetExecutor().Apply(plan)
+}
+
+func applyVulkanIcd(a *analyzer.Analyzer, p *specs.Profile) error {
+	if p.Vulkan == nil {
+		return nil
+	}
+	return applyFiles(a, p.Vulkan.Icd, func(name string) (string, *specs.JsonFile) {
+		dir, f := a.GetSystem().GetVulkanIcdFile(name)
+		if f == nil {
+			return "", nil
+		}
+		return dir.Path, f
+	})
+}
+
+func applyVulkanLayers(a *analyzer.Analyzer, p *specs.Profile) error {
+	if p.Vulkan == nil {
+		return nil
+	}
+	return applyFiles(a, p.Vulkan.Layers, func(name string) (string, *specs.JsonFile) {
+		dir, f := a.GetSystem().GetVulkanLayerFile(name)
+		if f == nil {
+			return "", nil
+		}
+		return dir.Path, &specs.JsonFile{Name: f.Name, Disabled: f.Disabled}
+	})
+}
+
+func applyEglLoaders(a *analyzer.Analyzer, p *specs.Profile) error {
+	return applyFiles(a, p.Egl, func(name string) (string, *specs.JsonFile) {
+		dir, f := a.GetSystem().GetEglLoader(name)
+		if f == nil {
+			return "", nil
+		}
+		return dir.Path, f
+	})
+}
+
+func applyOpenCLVendors(a *analyzer.Analyzer, p *specs.Profile) error {
+	return applyFiles(a, p.OpenCL, func(name string) (string, *specs.JsonFile) {
+		dir, f := a.GetSystem().GetOpenCLVendorFile(name)
+		if f == nil {
+			return "", nil
+		}
+		return dir.Path, &specs.JsonFile{Name: f.Name, Disabled: f.Disabled}
+	})
+}
+
+func applyEnvironment(a *analyzer.Analyzer, p *specs.Profile) error {
+	if p.Environment == nil || len(p.Environment.Variables) == 0 {
+		return nil
+	}
+
+	target := p.Environment.Target
+	if target == "" {
+		target = environment.TargetEnvd
+	}
+	if target == environment.TargetPrint || !environment.ValidTarget(target) {
+		return fmt.Errorf("invalid environment target %s", target)
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	file, err := environment.GetTargetFile(target,
+		a.GetBackend().GetEnvironmentDir(), homeDir)
+	if err != nil {
+		return err
+	}
+
+	env := environment.NewEnvironment()
+	names := []string{}
+	for name := range p.Environment.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env.Set(name, p.Environment.Variables[name], "")
+	}
+
+	plan := specs.NewOperationsPlan()
+	prepareDir(a, plan, filepath.Dir(file))
+	plan.WriteFile(file, env.File(), 0644)
+
+	return a.GetBackend().GetExecutor().Apply(plan)
+}
+
+// Write the options of the kernel modules in the
+// modprobe.d file. Example:
+// options nvidia_drm modeset=1 fbdev=1
+func applyKernelModules(a *analyzer.Analyzer, p *specs.Profile) error {
+	if len(p.KernelModules) == 0 {
+		return nil
+	}
+
+	modules := []string{}
+	for module := range p.KernelModules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	var content strings.Builder
+	content.WriteString("# autogenerated file by gpu-configurator\n")
+	for _, module := range modules {
+		options := []string{}
+		for key, value := range p.KernelModules[module] {
+			options = append(options, key+"="+value)
+		}
+		sort.Strings(options)
+		content.WriteString(fmt.Sprintf("options %s %s\n",
+			module, strings.Join(options, " ")))
+	}
+
+	plan := specs.NewOperationsPlan()
+	prepareDir(a, plan, ModprobeDir)
+	plan.WriteFile(filepath.Join(ModprobeDir, ModprobeFileName),
+		content.String(), 0644)
+
+	return a.GetBackend().GetExecutor().Apply(plan)
+}
+
+func prepareDir(a *analyzer.Analyzer, plan *specs.OperationsPlan, dir string) {
+	if !utils.Exists(rootfs.RealPath(a.GetBackend().GetRootDir(), dir)) {
+		plan.Mkdir(dir)
+	}
+}
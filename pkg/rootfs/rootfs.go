@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package rootfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	maxLinksResolved = 40
+)
+
+// Return the path of the file p of the target system
+// under the root directory.
+func RealPath(rootDir, p string) string {
+	if rootDir == "" || rootDir == "/" {
+		return p
+	}
+	return filepath.Join(rootDir, p)
+}
+
+// Resolve the path p of the target system to the real path
+// on disk. The absolute links are resolved under the root
+// directory instead of the host filesystem.
+func ResolvePath(rootDir, p string) (string, error) {
+	if rootDir == "" || rootDir == "/" {
+		return p, nil
+	}
+
+	current := filepath.Clean(p)
+	for i := 0; i < maxLinksResolved; i++ {
+		realpath := RealPath(rootDir, current)
+		finfo, err := os.Lstat(realpath)
+		if err != nil {
+			return "", err
+		}
+
+		if finfo.Mode()&os.ModeSymlink == 0 {
+			// POST: the parent directories could be links too
+			//       but on the managed paths this is not common.
+			return realpath, nil
+		}
+
+		linked, err := os.Readlink(realpath)
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(linked) {
+			current = filepath.Clean(linked)
+		} else {
+			current = filepath.Join(filepath.Dir(current), linked)
+		}
+
+		if !strings.HasPrefix(current, "/") {
+			current = "/" + current
+		}
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic links for %s", p)
+}
+
+// Check if the path exists also if it's a broken link.
+func Lexists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+// Read a file of the target system following the links
+// inside the root directory.
+func ReadFile(rootDir, p string) ([]byte, error) {
+	resolved, err := ResolvePath(rootDir, p)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(resolved)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package rootfs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs/testutil"
+)
+
+func TestResolvePath(t *testing.T) {
+	root := testutil.NewFakeRoot(t, map[string]string{
+		"/etc/passwd":            "etc/passwd",
+		"/usr/lib64/libfoo.so.1": "usr/lib64/libfoo.so.1",
+		"/opt/nvidia/libnv.so":   "opt/nvidia/libnv.so",
+		"/usr/lib64/libfoo.so":   "->libfoo.so.1",
+		"/usr/lib64/libnv.so":    "->/opt/nvidia/libnv.so",
+		"/usr/lib64/escape-rel":  "->../../../../../etc/passwd",
+		"/usr/lib64/escape-abs":  "->/../../etc/passwd",
+		"/usr/lib64/chain":       "->/usr/lib64/libnv.so",
+		"/usr/lib64/broken":      "->/opt/nvidia/missing.so",
+		"/usr/lib64/loop1":       "->loop2",
+		"/usr/lib64/loop2":       "->loop1",
+	})
+
+	tests := []struct {
+		name    string
+		root    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"regular file", root, "/etc/passwd", filepath.Join(root, "etc/passwd"), false},
+		{"relative link", root, "/usr/lib64/libfoo.so", filepath.Join(root, "usr/lib64/libfoo.so.1"), false},
+		{"absolute link", root, "/usr/lib64/libnv.so", filepath.Join(root, "opt/nvidia/libnv.so"), false},
+		{"chain of links", root, "/usr/lib64/chain", filepath.Join(root, "opt/nvidia/libnv.so"), false},
+		{"relative link escaping the root", root, "/usr/lib64/escape-rel", filepath.Join(root, "etc/passwd"), false},
+		{"absolute link escaping the root", root, "/usr/lib64/escape-abs", filepath.Join(root, "etc/passwd"), false},
+		{"broken link", root, "/usr/lib64/broken", "", true},
+		{"links loop", root, "/usr/lib64/loop1", "", true},
+		{"missing file", root, "/etc/missing", "", true},
+		{"host root", "/", "/usr/lib64/libfoo.so", "/usr/lib64/libfoo.so", false},
+		{"empty root", "", "/etc/passwd", "/etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolvePath(tt.root, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolvePath(%s) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolvePath(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
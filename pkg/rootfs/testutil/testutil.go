@@ -0,0 +1,40 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The prefix of the content that defines a link in NewFakeRoot.
+const LinkPrefix = "->"
+
+// Create a fake root with the files and the links defined.
+// The links have the LinkPrefix prefix in the content.
+func NewFakeRoot(t testing.TB, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+
+	for p, content := range files {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		if len(content) > len(LinkPrefix) && content[:len(LinkPrefix)] == LinkPrefix {
+			err = os.Symlink(content[len(LinkPrefix):], path)
+		} else {
+			err = os.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
@@ -15,6 +15,8 @@ import (
 const (
 	GPUCONF_ENV_PREFIX = "GPUCONF"
 	GPUCONF_VERSION    = "0.1.1"
+
+	DefaultStateDir = "/var/lib/gpu-configurator"
 )
 
 type Config struct {
@@ -27,6 +29,14 @@ type Config struct {
 type CGeneral struct {
 	Debug   bool   `mapstructure:"debug,omitempty" json:"debug,omitempty" yaml:"debug,omitempty"`
 	Backend string `mapstructure:"backend,omitempty" json:"backend,omitempty" yaml:"backend,omitempty"`
+	// Alternate root directory of the system to configure.
+	RootDir string `mapstructure:"root,omitempty" json:"root,omitempty" yaml:"root,omitempty"`
+	// Directory where store the state and the backup files.
+	StateDir string `mapstructure:"state_dir,omitempty" json:"state_dir,omitempty" yaml:"state_dir,omitempty"`
+	// Path of the pci.ids file. If empty the hwdata/pciutils paths are used.
+	PciIdsPath string `mapstructure:"pciids_path,omitempty" json:"pciids_path,omitempty" yaml:"pciids_path,omitempty"`
+	// The sysfs directory used to read the GPU topology. If empty /sys is used.
+	SysfsDir string `mapstructure:"sysfs_dir,omitempty" json:"sysfs_dir,omitempty" yaml:"sysfs_dir,omitempty"`
 }
 
 type CLogging struct {
@@ -88,6 +98,10 @@ func GenDefault(viper *v.Viper) {
 	viper.SetDefault("logging.color", true)
 
 	viper.SetDefault("general.backend", "macaroni")
+	viper.SetDefault("general.root", "")
+	viper.SetDefault("general.state_dir", DefaultStateDir)
+	viper.SetDefault("general.pciids_path", "")
+	viper.SetDefault("general.sysfs_dir", "")
 }
 
 func (g *CGeneral) HasDebug() bool {
@@ -97,3 +111,19 @@ func (g *CGeneral) HasDebug() bool {
 func (g *CGeneral) GetBackendType() string {
 	return g.Backend
 }
+
+func (g *CGeneral) GetRootDir() string {
+	return g.RootDir
+}
+
+func (g *CGeneral) GetStateDir() string {
+	return g.StateDir
+}
+
+func (g *CGeneral) GetPciIdsPath() string {
+	return g.PciIdsPath
+}
+
+func (g *CGeneral) GetSysfsDir() string {
+	return g.SysfsDir
+}
@@ -4,23 +4,81 @@ See AUTHORS and LICENSE for the license details and contributors.
 */
 package specs
 
+import "encoding/json"
+
 type System struct {
 	EglExtPlatformDirs []*EglExternalPlatformFiles `json:"egl_external_platforms_dirs,omitempty" yaml:"egl_external_platforms_dirs,omitempty"`
+	EglVendorDirs      []*EglExternalPlatformFiles `json:"egl_vendor_dirs,omitempty" yaml:"egl_vendor_dirs,omitempty"`
 	VulkanLayersDirs   []*VulkanLayersFiles        `json:"vulkan_layers_dirs,omitempty" yaml:"vulkan_layers_dirs,omitempty"`
 	VulkanICDDirs      []*EglExternalPlatformFiles `json:"vulkan_icd_dirs,omitempty" yaml:"vulkan_icd_dirs,omitempty"`
+	// The system wide settings of the Vulkan loader.
+	VulkanLoaderSettings *VulkanLoaderSettingsFile `json:"vulkan_loader_settings,omitempty" yaml:"vulkan_loader_settings,omitempty"`
 
 	GbmLibraries []*Library `json:"gbm_libs,omitempty" yaml:"gbm_libs,omitempty"`
 
+	OpenCLVendors *OpenCLVendorFiles `json:"opencl_vendors,omitempty" yaml:"opencl_vendors,omitempty"`
+
+	Video *VideoSetup `json:"video,omitempty" yaml:"video,omitempty"`
+
+	Drirc *DrircSetup `json:"drirc,omitempty" yaml:"drirc,omitempty"`
+
 	Nvidia *NVIDIASetup `json:"nvidia,omitempty" yaml:"nvidia,omitempty"`
+
+	Topology *GPUTopology `json:"gpu_topology,omitempty" yaml:"gpu_topology,omitempty"`
+
+	// The issues found on scan the system.
+	Diagnostics []*Diagnostic `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty"`
+}
+
+type Diagnostic struct {
+	Path  string `json:"path" yaml:"path"`
+	Kind  string `json:"kind" yaml:"kind"`
+	Error string `json:"error" yaml:"error"`
+}
+
+type GPUTopology struct {
+	GPUs []*GPUNode `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+	// The bus id of the GPU used by the firmware.
+	BootVGA string `json:"boot_vga,omitempty" yaml:"boot_vga,omitempty"`
+	// The bus id of the GPU that drives the connected outputs.
+	DisplayGPU string `json:"display_gpu,omitempty" yaml:"display_gpu,omitempty"`
+	Hybrid     bool   `json:"hybrid,omitempty" yaml:"hybrid,omitempty"`
+	// The setup suggested for hybrid systems: offload or primary.
+	SuggestedMode string `json:"suggested_mode,omitempty" yaml:"suggested_mode,omitempty"`
+}
+
+type GPUNode struct {
+	BusId        string       `json:"bus_id" yaml:"bus_id"`
+	Id           string       `json:"id,omitempty" yaml:"id,omitempty"`
+	Name         string       `json:"name,omitempty" yaml:"name,omitempty"`
+	Vendor       string       `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Role         string       `json:"role,omitempty" yaml:"role,omitempty"`
+	KernelDriver string       `json:"kernel_driver,omitempty" yaml:"kernel_driver,omitempty"`
+	BootVGA      bool         `json:"boot_vga,omitempty" yaml:"boot_vga,omitempty"`
+	Card         string       `json:"card,omitempty" yaml:"card,omitempty"`
+	RenderNode   string       `json:"render_node,omitempty" yaml:"render_node,omitempty"`
+	Outputs      []*DRMOutput `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+type DRMOutput struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status,omitempty" yaml:"status,omitempty"`
+	Enabled bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 }
 
 type NVIDIASetup struct {
-	Drivers              []*NVIDIADriver `json:"drivers,omitempty" yaml:"drivers,omitempty"`
-	VersionActive        string          `json:"version_active,omitempty" yaml:"version_active,omitempty"`
+	Drivers       []*NVIDIADriver `json:"drivers,omitempty" yaml:"drivers,omitempty"`
+	VersionActive string          `json:"version_active,omitempty" yaml:"version_active,omitempty"`
+	// The version of the env.d file also if not installed.
+	VersionConfigured    string          `json:"version_configured,omitempty" yaml:"version_configured,omitempty"`
 	KModuleAvailable     []*KernelModule `json:"kernel_modules,omitempty" yaml:"kernel_modules,omitempty"`
 	KOpenModuleAvailable []*KernelModule `json:"kernel_open_modules,omitempty" yaml:"kernel_open_modules,omitempty"`
 }
 
+type NVIDIAPurgeOpts struct {
+	KeepConfd bool `json:"keep_confd,omitempty" yaml:"keep_confd,omitempty"`
+}
+
 type NVIDIADriver struct {
 	Path              string `json:"path" yaml:"path"`
 	Version           string `json:"version" yaml:"version"`
@@ -37,37 +95,270 @@ type EglExternalPlatformFiles struct {
 	Files map[string]*JsonFile `json:"files,omitempty" yaml:"files,omitempty"`
 }
 
+// The glvnd EGL vendor in the order of priority used by libEGL.
+type EglVendor struct {
+	Name        string `json:"name" yaml:"name"`
+	File        string `json:"file" yaml:"file"`
+	LibraryPath string `json:"library_path,omitempty" yaml:"library_path,omitempty"`
+	// The variable or the search path that defines the file.
+	Source string `json:"source" yaml:"source"`
+}
+
 type JsonFile struct {
-	Name     string   `json:"name" yaml:"name"`
-	Disabled bool     `json:"disabled,omitempty" yaml:"disabled,omitempty"`
-	File     *ICDJson `json:"file,omitempty" yaml:"file,omitempty"`
+	Name     string `json:"name" yaml:"name"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// The architecture of the ICD library: 32, 64 or empty if unknown.
+	Arch string   `json:"arch,omitempty" yaml:"arch,omitempty"`
+	File *ICDJson `json:"file,omitempty" yaml:"file,omitempty"`
 }
 
 type ICDJson struct {
 	FileFormatVersion string      `json:"file_format_version" yaml:"file_format_version"`
 	ICD               ICDJsonData `json:"ICD" yaml:"ICD"`
+
+	// The fields not managed are preserved.
+	Extra map[string]json.RawMessage `json:"-" yaml:"-"`
 }
 
 type VulkanLayersFile struct {
-	Name     string                 `json:"name" yaml:"name"`
-	Disabled bool                   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
-	Content  map[string]interface{} `json:"content,omitempty" yaml:"content,omitempty"`
+	Name     string `json:"name" yaml:"name"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// The file is under an implicit_layer.d directory.
+	Implicit bool                 `json:"implicit,omitempty" yaml:"implicit,omitempty"`
+	Manifest *VulkanLayerManifest `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+}
+
+// The Vulkan layer manifest with a single layer (layer)
+// or with multiple layers (layers, file_format_version >= 1.0.1).
+type VulkanLayerManifest struct {
+	FileFormatVersion string         `json:"file_format_version" yaml:"file_format_version"`
+	Layer             *VulkanLayer   `json:"layer,omitempty" yaml:"layer,omitempty"`
+	Layers            []*VulkanLayer `json:"layers,omitempty" yaml:"layers,omitempty"`
+}
+
+type VulkanLayer struct {
+	Name string `json:"name" yaml:"name"`
+	// GLOBAL or INSTANCE (deprecated).
+	Type                  string `json:"type" yaml:"type"`
+	LibraryPath           string `json:"library_path,omitempty" yaml:"library_path,omitempty"`
+	ApiVersion            string `json:"api_version" yaml:"api_version"`
+	ImplementationVersion string `json:"implementation_version" yaml:"implementation_version"`
+	Description           string `json:"description" yaml:"description"`
+
+	InstanceExtensions []*VulkanExtension `json:"instance_extensions,omitempty" yaml:"instance_extensions,omitempty"`
+	DeviceExtensions   []*VulkanExtension `json:"device_extensions,omitempty" yaml:"device_extensions,omitempty"`
+
+	EnableEnvironment  map[string]string `json:"enable_environment,omitempty" yaml:"enable_environment,omitempty"`
+	DisableEnvironment map[string]string `json:"disable_environment,omitempty" yaml:"disable_environment,omitempty"`
+
+	// The layers aggregated by a meta layer.
+	ComponentLayers []string `json:"component_layers,omitempty" yaml:"component_layers,omitempty"`
+}
+
+type VulkanExtension struct {
+	Name        string `json:"name" yaml:"name"`
+	SpecVersion string `json:"spec_version" yaml:"spec_version"`
+	// The device extensions entrypoints.
+	Entrypoints []string `json:"entrypoints,omitempty" yaml:"entrypoints,omitempty"`
+}
+
+type VulkanLoaderSettingsFile struct {
+	Path     string                `json:"path" yaml:"path"`
+	Settings *VulkanLoaderSettings `json:"settings,omitempty" yaml:"settings,omitempty"`
+}
+
+// The vk_loader_settings.json file used by the Vulkan loader
+// (>= 1.3.284) to configure the layers without the manifests changes.
+type VulkanLoaderSettings struct {
+	FileFormatVersion string                       `json:"file_format_version" yaml:"file_format_version"`
+	SettingsArray     []*VulkanLoaderSettingsEntry `json:"settings_array,omitempty" yaml:"settings_array,omitempty"`
+	// The single settings format of the first loader versions.
+	Settings *VulkanLoaderSettingsEntry `json:"settings,omitempty" yaml:"settings,omitempty"`
+
+	// The fields not managed are preserved.
+	Extra map[string]json.RawMessage `json:"-" yaml:"-"`
+}
+
+type VulkanLoaderSettingsEntry struct {
+	// The settings are applied only to the executables
+	// with the keys. Without keys the settings are global.
+	AppKeys []string                     `json:"app_keys,omitempty" yaml:"app_keys,omitempty"`
+	Layers  []*VulkanLoaderSettingsLayer `json:"layers,omitempty" yaml:"layers,omitempty"`
+
+	// The fields not managed (ex. stderr_log) are preserved.
+	Extra map[string]json.RawMessage `json:"-" yaml:"-"`
+}
+
+type VulkanLoaderSettingsLayer struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// on, off, auto or unordered_layer_location.
+	Control                 string `json:"control" yaml:"control"`
+	TreatAsImplicitManifest bool   `json:"treat_as_implicit_manifest" yaml:"treat_as_implicit_manifest"`
 }
 
 type ICDJsonData struct {
 	LibraryPath string `json:"library_path" yaml:"library_path"`
 	ApiVersion  string `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	// The architecture of the library: 32 or 64.
+	LibraryArch         string `json:"library_arch,omitempty" yaml:"library_arch,omitempty"`
+	IsPortabilityDriver bool   `json:"is_portability_driver,omitempty" yaml:"is_portability_driver,omitempty"`
+
+	// The fields not managed are preserved.
+	Extra map[string]json.RawMessage `json:"-" yaml:"-"`
+}
+
+// The OpenCL ICD registry used by the ICD loader (ocl-icd).
+type OpenCLVendorFiles struct {
+	Path  string                       `json:"path" yaml:"path"`
+	Files map[string]*OpenCLVendorFile `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+type OpenCLVendorFile struct {
+	Name     string `json:"name" yaml:"name"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// The library of the platform defined in the .icd file.
+	LibraryPath      string `json:"library_path,omitempty" yaml:"library_path,omitempty"`
+	LibraryAvailable bool   `json:"library_available" yaml:"library_available"`
+}
+
+// The Mesa drirc files in the order used by Mesa.
+type DrircSetup struct {
+	Files []*DrircFile `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+type DrircFile struct {
+	Path string `json:"path" yaml:"path"`
+	// The file is a default of the distribution (drirc.d).
+	Default bool           `json:"default,omitempty" yaml:"default,omitempty"`
+	Options []*DrircOption `json:"options,omitempty" yaml:"options,omitempty"`
+	Config  *DrircConfig   `json:"-" yaml:"-"`
+}
+
+// The drirc XML document. The nodes are the tokens of the document
+// with the driconf root element.
+type DrircConfig struct {
+	Nodes []*DrircNode
+}
+
+// A node of the drirc XML document. The elements have the name,
+// the attributes in the original order and the children nodes.
+// The other tokens (text, comments, directives and processing
+// instructions) are kept as raw content to write the file
+// without the loss of the content not managed.
+type DrircNode struct {
+	Name  string
+	Attrs []*DrircAttr
+	Nodes []*DrircNode
+	Raw   string
+}
+
+type DrircAttr struct {
+	Name  string
+	Value string
+}
+
+// The sections where an option is defined. The application
+// and the engine are exclusive.
+type DrircScope struct {
+	// The attributes of the device (driver, kernel_driver, device, screen).
+	Device      map[string]string `json:"device,omitempty" yaml:"device,omitempty"`
+	Application map[string]string `json:"application,omitempty" yaml:"application,omitempty"`
+	Engine      map[string]string `json:"engine,omitempty" yaml:"engine,omitempty"`
+}
+
+type DrircOption struct {
+	Name       string `json:"name" yaml:"name"`
+	Value      string `json:"value" yaml:"value"`
+	DrircScope `yaml:",inline"`
+	File       string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// The video acceleration drivers installed.
+type VideoSetup struct {
+	VADrivers    []*VideoDriver `json:"va_drivers,omitempty" yaml:"va_drivers,omitempty"`
+	VDPAUDrivers []*VideoDriver `json:"vdpau_drivers,omitempty" yaml:"vdpau_drivers,omitempty"`
+}
+
+type VideoDriver struct {
+	// The name used by LIBVA_DRIVER_NAME or VDPAU_DRIVER.
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
 }
 
 type Library struct {
 	Name       string `json:"library" yaml:"library"`
 	Disabled   bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
-	LinkedFile string `json:"linked_libpath,omitempty" yaml:"linked_libpath,omitempty'`
+	LinkedFile string `json:"linked_libpath,omitempty" yaml:"linked_libpath,omitempty"`
 }
 
 type KernelModule struct {
 	Path          string            `json:"path,omitempty" yaml:"path,omitempty"`
 	KernelVersion string            `json:"kernel_version,omitempty" yaml:"kernel_version,omitempty"`
+	DriverVersion string            `json:"driver_version,omitempty" yaml:"driver_version,omitempty"`
 	Fields        map[string]string `json:"fields,omitempty" yaml:"fields,omitempty"`
 	Name          string            `json:"name,omitempty" yaml:"name,omitempty"`
 }
+
+type FileOperation struct {
+	Type    string `json:"type" yaml:"type"`
+	Path    string `json:"path" yaml:"path"`
+	Target  string `json:"target,omitempty" yaml:"target,omitempty"`
+	Source  string `json:"source,omitempty" yaml:"source,omitempty"`
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+	Mode    uint32 `json:"-" yaml:"-"`
+	// The status of the artifact: created, updated, unchanged, removed.
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+type OperationsPlan struct {
+	Operations []*FileOperation `json:"operations" yaml:"operations"`
+
+	// The artifacts not managed by the operations.
+	Diagnostics []*Diagnostic `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty"`
+}
+
+type ManagedArtifact struct {
+	Path      string `json:"path" yaml:"path"`
+	Type      string `json:"type" yaml:"type"`
+	Target    string `json:"target,omitempty" yaml:"target,omitempty"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	Checksum  string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	Timestamp string `json:"timestamp" yaml:"timestamp"`
+}
+
+type StateManifest struct {
+	Artifacts []*ManagedArtifact `json:"artifacts" yaml:"artifacts"`
+}
+
+// The desired state of the GPU configuration.
+type Profile struct {
+	Nvidia        *ProfileNvidia               `json:"nvidia,omitempty" yaml:"nvidia,omitempty"`
+	Vulkan        *ProfileVulkan               `json:"vulkan,omitempty" yaml:"vulkan,omitempty"`
+	Egl           *ProfileFiles                `json:"egl,omitempty" yaml:"egl,omitempty"`
+	OpenCL        *ProfileFiles                `json:"opencl,omitempty" yaml:"opencl,omitempty"`
+	Environment   *ProfileEnvironment          `json:"environment,omitempty" yaml:"environment,omitempty"`
+	KernelModules map[string]map[string]string `json:"kernel_modules,omitempty" yaml:"kernel_modules,omitempty"`
+}
+
+type ProfileNvidia struct {
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Enable/Disable the NVIDIA GBM library. If not set
+	// the library is not touched.
+	GbmLib *bool `json:"gbm_lib,omitempty" yaml:"gbm_lib,omitempty"`
+}
+
+type ProfileVulkan struct {
+	Icd    *ProfileFiles `json:"icd,omitempty" yaml:"icd,omitempty"`
+	Layers *ProfileFiles `json:"layers,omitempty" yaml:"layers,omitempty"`
+}
+
+type ProfileFiles struct {
+	Enabled  []string `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Disabled []string `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
+
+type ProfileEnvironment struct {
+	Target    string            `json:"target,omitempty" yaml:"target,omitempty"`
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
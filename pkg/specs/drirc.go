@@ -0,0 +1,571 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	DrircRootElement        = "driconf"
+	DrircDeviceElement      = "device"
+	DrircApplicationElement = "application"
+	DrircEngineElement      = "engine"
+	DrircOptionElement      = "option"
+
+	// The drirc file of the user under the home directory.
+	DrircUserFile = ".drirc"
+	DrircIndent   = "    "
+
+	// Write the options in the system drirc file.
+	DrircTargetSystem = "system"
+	// Write the options in the drirc file of the user.
+	DrircTargetUser = "user"
+)
+
+// The order of the attributes of the new elements.
+var drircAttrsOrder = []string{
+	"name", "value",
+	"driver", "kernel_driver", "device", "screen",
+	"executable", "executable_regexp", "sha1",
+	"application_name_match", "application_versions",
+	"engine_name_match", "engine_versions",
+}
+
+var (
+	drircTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	drircAttrEscaper = strings.NewReplacer(
+		"&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+)
+
+func ValidDrircTarget(target string) bool {
+	switch target {
+	case DrircTargetSystem, DrircTargetUser:
+		return true
+	default:
+		return false
+	}
+}
+
+func NewDrircConfig() *DrircConfig {
+	return &DrircConfig{
+		Nodes: []*DrircNode{
+			{Raw: `<?xml version="1.0" standalone="yes"?>`},
+			{Raw: "\n"},
+			{
+				Name:  DrircRootElement,
+				Attrs: []*DrircAttr{},
+				Nodes: []*DrircNode{{Raw: "\n"}},
+			},
+			{Raw: "\n"},
+		},
+	}
+}
+
+func drircName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+func NewDrircConfigFromXml(data []byte) (*DrircConfig, error) {
+	ans := &DrircConfig{Nodes: []*DrircNode{}}
+	stack := []*DrircNode{}
+
+	add := func(n *DrircNode) {
+		if len(stack) == 0 {
+			ans.Nodes = append(ans.Nodes, n)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Nodes = append(parent.Nodes, n)
+		}
+	}
+
+	// POST: RawToken doesn't translate the name space prefixes
+	//       and the names are written as read.
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &DrircNode{
+				Name:  drircName(t.Name),
+				Attrs: []*DrircAttr{},
+				Nodes: []*DrircNode{},
+			}
+			for _, a := range t.Attr {
+				node.Attrs = append(node.Attrs, &DrircAttr{
+					Name:  drircName(a.Name),
+					Value: a.Value,
+				})
+			}
+			add(node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].Name != drircName(t.Name) {
+				return nil, fmt.Errorf("unexpected end element %s", drircName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			add(&DrircNode{Raw: drircTextEscaper.Replace(string(t))})
+		case xml.Comment:
+			add(&DrircNode{Raw: "<!--" + string(t) + "-->"})
+		case xml.ProcInst:
+			raw := "<?" + t.Target
+			if len(t.Inst) > 0 {
+				raw += " " + string(t.Inst)
+			}
+			add(&DrircNode{Raw: raw + "?>"})
+		case xml.Directive:
+			add(&DrircNode{Raw: "<!" + string(t) + ">"})
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("element %s not closed", stack[len(stack)-1].Name)
+	}
+
+	if ans.GetRoot() == nil {
+		return nil, fmt.Errorf("no %s element found", DrircRootElement)
+	}
+
+	return ans, nil
+}
+
+func (c *DrircConfig) Xml() []byte {
+	var sb strings.Builder
+	for _, n := range c.Nodes {
+		n.write(&sb)
+	}
+	return []byte(sb.String())
+}
+
+func (n *DrircNode) write(sb *strings.Builder) {
+	if n.Name == "" {
+		sb.WriteString(n.Raw)
+		return
+	}
+
+	sb.WriteString("<" + n.Name)
+	for _, a := range n.Attrs {
+		sb.WriteString(fmt.Sprintf(" %s=\"%s\"", a.Name, drircAttrEscaper.Replace(a.Value)))
+	}
+	if len(n.Nodes) == 0 {
+		sb.WriteString(" />")
+		return
+	}
+	sb.WriteString(">")
+	for _, child := range n.Nodes {
+		child.write(sb)
+	}
+	sb.WriteString("</" + n.Name + ">")
+}
+
+func (c *DrircConfig) GetRoot() *DrircNode {
+	for _, n := range c.Nodes {
+		if n.Name == DrircRootElement {
+			return n
+		}
+	}
+	return nil
+}
+
+func newDrircElement(name string, attrs map[string]string) *DrircNode {
+	ans := &DrircNode{
+		Name:  name,
+		Attrs: []*DrircAttr{},
+		Nodes: []*DrircNode{},
+	}
+
+	names := []string{}
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return drircAttrIndex(names[i]) < drircAttrIndex(names[j]) ||
+			(drircAttrIndex(names[i]) == drircAttrIndex(names[j]) && names[i] < names[j])
+	})
+	for _, k := range names {
+		ans.Attrs = append(ans.Attrs, &DrircAttr{Name: k, Value: attrs[k]})
+	}
+
+	return ans
+}
+
+func drircAttrIndex(name string) int {
+	for idx, n := range drircAttrsOrder {
+		if n == name {
+			return idx
+		}
+	}
+	return len(drircAttrsOrder)
+}
+
+func (n *DrircNode) GetAttr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *DrircNode) GetAttrs() map[string]string {
+	ans := make(map[string]string)
+	for _, a := range n.Attrs {
+		ans[a.Name] = a.Value
+	}
+	return ans
+}
+
+func (n *DrircNode) SetAttr(name, value string) {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			a.Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, &DrircAttr{Name: name, Value: value})
+}
+
+func (n *DrircNode) GetElements(name string) []*DrircNode {
+	ans := []*DrircNode{}
+	for _, child := range n.Nodes {
+		if child.Name == name {
+			ans = append(ans, child)
+		}
+	}
+	return ans
+}
+
+func (n *DrircNode) isSpace() bool {
+	return n.Name == "" && strings.TrimSpace(n.Raw) == ""
+}
+
+func (n *DrircNode) hasElements() bool {
+	for _, child := range n.Nodes {
+		if child.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if the node has comments or text.
+func (n *DrircNode) hasContent() bool {
+	for _, child := range n.Nodes {
+		if child.Name == "" && !child.isSpace() {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the element with the name and with the same attributes.
+func (n *DrircNode) getElement(name string, attrs map[string]string) *DrircNode {
+	for _, child := range n.GetElements(name) {
+		if equalDrircAttrs(child.GetAttrs(), attrs) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Add the element with the indentation of the depth of the
+// children. The depth of the root element is 0.
+func (n *DrircNode) addElement(child *DrircNode, depth int) {
+	indent := &DrircNode{Raw: "\n" + strings.Repeat(DrircIndent, depth+1)}
+
+	last := len(n.Nodes) - 1
+	if last >= 0 && n.Nodes[last].isSpace() {
+		// POST: the element is added before the indentation
+		//       of the end element.
+		n.Nodes = append(n.Nodes[:last],
+			append([]*DrircNode{indent, child}, n.Nodes[last:]...)...)
+		return
+	}
+
+	n.Nodes = append(n.Nodes, indent, child,
+		&DrircNode{Raw: "\n" + strings.Repeat(DrircIndent, depth)})
+}
+
+// Remove the element and the indentation before it.
+func (n *DrircNode) removeElement(child *DrircNode) {
+	for idx, c := range n.Nodes {
+		if c != child {
+			continue
+		}
+		if idx > 0 && n.Nodes[idx-1].isSpace() {
+			n.Nodes = append(n.Nodes[:idx-1], n.Nodes[idx+1:]...)
+		} else {
+			n.Nodes = append(n.Nodes[:idx], n.Nodes[idx+1:]...)
+		}
+		return
+	}
+}
+
+func equalDrircAttrs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *DrircScope) getSection() (string, map[string]string, error) {
+	if len(s.Application) > 0 && len(s.Engine) > 0 {
+		return "", nil, fmt.Errorf("application and engine not admitted together")
+	}
+	if len(s.Engine) > 0 {
+		return DrircEngineElement, s.Engine, nil
+	}
+	if len(s.Application) > 0 {
+		return DrircApplicationElement, s.Application, nil
+	}
+	return "", nil, fmt.Errorf("the options require an application or an engine")
+}
+
+// Return a string that identifies the sections of the scope.
+// The attributes not used by Mesa are ignored.
+func (s *DrircScope) Key() string {
+	key := func(m map[string]string) string {
+		names := []string{}
+		for k := range m {
+			if drircAttrIndex(k) < len(drircAttrsOrder) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		for idx, k := range names {
+			names[idx] = fmt.Sprintf("%s=%q", k, m[k])
+		}
+		return strings.Join(names, " ")
+	}
+
+	return fmt.Sprintf("device[%s] application[%s] engine[%s]",
+		key(s.Device), key(s.Application), key(s.Engine))
+}
+
+// Return a description of the sections of the scope.
+// Example: driver=radeonsi, application name=all
+func (s *DrircScope) String() string {
+	ans := []string{}
+	for _, t := range []struct {
+		Prefix string
+		Attrs  map[string]string
+	}{
+		{"", s.Device},
+		{"application ", s.Application},
+		{"engine ", s.Engine},
+	} {
+		if len(t.Attrs) == 0 {
+			continue
+		}
+		attrs := []string{}
+		for k, v := range t.Attrs {
+			attrs = append(attrs, k+"="+v)
+		}
+		sort.Strings(attrs)
+		ans = append(ans, t.Prefix+strings.Join(attrs, " "))
+	}
+	if len(s.Device) == 0 {
+		ans = append([]string{"all devices"}, ans...)
+	}
+	return strings.Join(ans, ", ")
+}
+
+func getDrircAttrsOrNil(n *DrircNode) map[string]string {
+	if ans := n.GetAttrs(); len(ans) > 0 {
+		return ans
+	}
+	return nil
+}
+
+// Return the options defined in the applications and in
+// the engines of the devices in the order of the file.
+func (c *DrircConfig) GetOptions() []*DrircOption {
+	ans := []*DrircOption{}
+
+	root := c.GetRoot()
+	if root == nil {
+		return ans
+	}
+
+	for _, device := range root.GetElements(DrircDeviceElement) {
+		for _, section := range device.Nodes {
+			if section.Name != DrircApplicationElement &&
+				section.Name != DrircEngineElement {
+				continue
+			}
+
+			scope := DrircScope{Device: getDrircAttrsOrNil(device)}
+			if section.Name == DrircApplicationElement {
+				scope.Application = section.GetAttrs()
+			} else {
+				scope.Engine = section.GetAttrs()
+			}
+
+			for _, option := range section.GetElements(DrircOptionElement) {
+				name, _ := option.GetAttr("name")
+				value, _ := option.GetAttr("value")
+				ans = append(ans, &DrircOption{
+					Name:       name,
+					Value:      value,
+					DrircScope: scope,
+				})
+			}
+		}
+	}
+
+	return ans
+}
+
+// Set the value of the option in the sections of the scope. The
+// sections are created if not present. Return false if the option
+// is already defined with the value.
+func (c *DrircConfig) SetOption(scope *DrircScope, name, value string) (bool, error) {
+	sectionName, sectionAttrs, err := scope.getSection()
+	if err != nil {
+		return false, err
+	}
+
+	root := c.GetRoot()
+	if root == nil {
+		return false, fmt.Errorf("no %s element found", DrircRootElement)
+	}
+
+	device := root.getElement(DrircDeviceElement, scope.Device)
+	if device == nil {
+		device = newDrircElement(DrircDeviceElement, scope.Device)
+		root.addElement(device, 0)
+	}
+
+	section := device.getElement(sectionName, sectionAttrs)
+	if section == nil {
+		section = newDrircElement(sectionName, sectionAttrs)
+		device.addElement(section, 1)
+	}
+
+	// POST: with multiple definitions the last option is used.
+	var option *DrircNode
+	for _, o := range section.GetElements(DrircOptionElement) {
+		if n, _ := o.GetAttr("name"); n == name {
+			option = o
+		}
+	}
+
+	if option == nil {
+		section.addElement(newDrircElement(DrircOptionElement, map[string]string{
+			"name":  name,
+			"value": value,
+		}), 2)
+		return true, nil
+	}
+
+	if v, _ := option.GetAttr("value"); v == value {
+		return false, nil
+	}
+	option.SetAttr("value", value)
+
+	return true, nil
+}
+
+// Remove the option from the sections of the scope. The sections
+// without elements are removed. Return false if the option
+// is not present.
+func (c *DrircConfig) UnsetOption(scope *DrircScope, name string) (bool, error) {
+	sectionName, sectionAttrs, err := scope.getSection()
+	if err != nil {
+		return false, err
+	}
+
+	root := c.GetRoot()
+	if root == nil {
+		return false, nil
+	}
+
+	device := root.getElement(DrircDeviceElement, scope.Device)
+	if device == nil {
+		return false, nil
+	}
+
+	section := device.getElement(sectionName, sectionAttrs)
+	if section == nil {
+		return false, nil
+	}
+
+	ans := false
+	for _, o := range section.GetElements(DrircOptionElement) {
+		if n, _ := o.GetAttr("name"); n == name {
+			section.removeElement(o)
+			ans = true
+		}
+	}
+
+	if ans && !section.hasElements() && !section.hasContent() {
+		device.removeElement(section)
+		if !device.hasElements() && !device.hasContent() {
+			root.removeElement(device)
+		}
+	}
+
+	return ans, nil
+}
+
+// Return the options of the files with the values used by Mesa.
+// The files are parsed in order and the options of the same
+// sections of the following files override the previous values.
+func (s *DrircSetup) GetEffectiveOptions(withDefaults bool) []*DrircOption {
+	ans := []*DrircOption{}
+	options := make(map[string]*DrircOption)
+
+	for _, f := range s.Files {
+		if f.Default && !withDefaults {
+			continue
+		}
+		for _, o := range f.Options {
+			key := o.DrircScope.Key() + " " + o.Name
+			if prev, present := options[key]; present {
+				prev.Value = o.Value
+				prev.File = o.File
+				continue
+			}
+			option := *o
+			options[key] = &option
+			ans = append(ans, &option)
+		}
+	}
+
+	return ans
+}
+
+func NewDrircFile(path string, config *DrircConfig, isDefault bool) *DrircFile {
+	ans := &DrircFile{
+		Path:    path,
+		Default: isDefault,
+		Config:  config,
+		Options: config.GetOptions(),
+	}
+	for _, o := range ans.Options {
+		o.File = path
+	}
+	return ans
+}
@@ -4,6 +4,8 @@ See AUTHORS and LICENSE for the license details and contributors.
 */
 package specs
 
+import "strings"
+
 func NewEglExternalPlatformFiles(dir string) *EglExternalPlatformFiles {
 	return &EglExternalPlatformFiles{
 		Path:  dir,
@@ -14,3 +16,36 @@ func NewEglExternalPlatformFiles(dir string) *EglExternalPlatformFiles {
 func (e *EglExternalPlatformFiles) AddFile(file string, jsonfile *JsonFile) {
 	e.Files[file] = jsonfile
 }
+
+// Return the name of the vendor from the name of the
+// vendor file without the priority prefix.
+// Example: 10_nvidia.json -> nvidia
+func GetEglVendorName(n string) string {
+	n = strings.TrimSuffix(strings.TrimSuffix(n, DisabledSuffix), ".json")
+	if p := GetEglVendorPriority(n); p != "" {
+		return strings.TrimPrefix(n[len(p):], "_")
+	}
+	return n
+}
+
+// Return the numeric prefix of the vendor file used
+// to sort the vendors or empty if not present.
+func GetEglVendorPriority(n string) string {
+	idx := 0
+	for idx < len(n) && n[idx] >= '0' && n[idx] <= '9' {
+		idx++
+	}
+	return n[0:idx]
+}
+
+// Return the vendor files with the file name or with the
+// vendor name.
+func (e *EglExternalPlatformFiles) GetVendorFiles(name string) []*JsonFile {
+	ans := []*JsonFile{}
+	for _, f := range e.Files {
+		if f.Name == name || GetEglVendorName(f.Name) == name {
+			ans = append(ans, f)
+		}
+	}
+	return ans
+}
@@ -6,9 +6,33 @@ package specs
 
 import (
 	"encoding/json"
+	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	IcdArch32 = "32"
+	IcdArch64 = "64"
 )
 
+// The suffixes used on the names of the ICD files
+// of the multilib systems.
+var icdArchSuffixes = map[string]string{
+	".i386":    IcdArch32,
+	".i686":    IcdArch32,
+	".x86":     IcdArch32,
+	".armv7l":  IcdArch32,
+	".x86_64":  IcdArch64,
+	".aarch64": IcdArch64,
+}
+
+var icdJsonFields = []string{"file_format_version", "ICD"}
+var icdJsonDataFields = []string{
+	"library_path", "api_version", "library_arch", "is_portability_driver",
+}
+
 func NewJsonFile(n string, f *ICDJson) *JsonFile {
 	ans := &JsonFile{
 		Name:     n,
@@ -32,3 +56,159 @@ func NewICDJson(data []byte) (*ICDJson, error) {
 
 	return ans, nil
 }
+
+// Return the name of the ICD file without the .json extension
+// and the architecture suffix.
+// Example: radeon_icd.x86_64.json -> radeon_icd
+func GetIcdBaseName(n string) string {
+	n = strings.TrimSuffix(strings.TrimSuffix(n, DisabledSuffix), ".json")
+	for suffix := range icdArchSuffixes {
+		if strings.HasSuffix(n, suffix) {
+			return strings.TrimSuffix(n, suffix)
+		}
+	}
+	return n
+}
+
+// Return the architecture from the suffix of the ICD file name
+// or empty if not available.
+func GetIcdArchFromName(n string) string {
+	n = strings.TrimSuffix(strings.TrimSuffix(n, DisabledSuffix), ".json")
+	for suffix, arch := range icdArchSuffixes {
+		if strings.HasSuffix(n, suffix) {
+			return arch
+		}
+	}
+	return ""
+}
+
+// Return the JSON files with the name or, if the name is without
+// the architecture suffix, with the base name. Only the files of the
+// architecture arch are returned. An empty arch matches all the files.
+func (e *EglExternalPlatformFiles) GetFiles(name, arch string) []*JsonFile {
+	ans := []*JsonFile{}
+	for _, f := range e.Files {
+		if f.Name != name && (GetIcdArchFromName(name) != "" ||
+			GetIcdBaseName(f.Name) != GetIcdBaseName(name)) {
+			continue
+		}
+		if arch != "" && f.Arch != arch {
+			continue
+		}
+		ans = append(ans, f)
+	}
+	return ans
+}
+
+func (i *ICDJson) UnmarshalJSON(data []byte) error {
+	type icdJson ICDJson
+	if err := json.Unmarshal(data, (*icdJson)(i)); err != nil {
+		return err
+	}
+
+	extra, err := getExtraFields(data, icdJsonFields)
+	if err != nil {
+		return err
+	}
+	i.Extra = extra
+
+	return nil
+}
+
+func (i ICDJson) MarshalJSON() ([]byte, error) {
+	type icdJson ICDJson
+	return marshalWithExtraFields(icdJson(i), i.Extra)
+}
+
+func (i ICDJson) MarshalYAML() (interface{}, error) {
+	type icdJson ICDJson
+	return yamlWithExtraFields(icdJson(i), i.Extra)
+}
+
+func (d *ICDJsonData) UnmarshalJSON(data []byte) error {
+	type icdJsonData ICDJsonData
+	if err := json.Unmarshal(data, (*icdJsonData)(d)); err != nil {
+		return err
+	}
+
+	extra, err := getExtraFields(data, icdJsonDataFields)
+	if err != nil {
+		return err
+	}
+	d.Extra = extra
+
+	return nil
+}
+
+func (d ICDJsonData) MarshalJSON() ([]byte, error) {
+	type icdJsonData ICDJsonData
+	return marshalWithExtraFields(icdJsonData(d), d.Extra)
+}
+
+func (d ICDJsonData) MarshalYAML() (interface{}, error) {
+	type icdJsonData ICDJsonData
+	return yamlWithExtraFields(icdJsonData(d), d.Extra)
+}
+
+// Return the fields of the JSON object that are not
+// between the known fields.
+func getExtraFields(data []byte, known []string) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, k := range known {
+		delete(fields, k)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return fields, nil
+}
+
+func marshalWithExtraFields(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, value := range extra {
+		fields[k] = value
+	}
+
+	return json.Marshal(fields)
+}
+
+func yamlWithExtraFields(v interface{}, extra map[string]json.RawMessage) (interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &ans); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var value interface{}
+		if err := json.Unmarshal(extra[k], &value); err != nil {
+			return nil, err
+		}
+		ans = append(ans, yaml.MapItem{Key: k, Value: value})
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGetFiles(t *testing.T) {
+	dir := NewEglExternalPlatformFiles("/usr/share/vulkan/icd.d")
+	for _, f := range []struct{ name, arch string }{
+		{"radeon_icd.x86_64.json", IcdArch64},
+		{"radeon_icd.i686.json.disabled", IcdArch32},
+		{"intel_icd.json", IcdArch64},
+		{"nvidia_icd.json", ""},
+	} {
+		jsonfile := NewJsonFile(f.name, nil)
+		jsonfile.Arch = f.arch
+		dir.AddFile(f.name, jsonfile)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		arch string
+		want []string
+	}{
+		{"exact name with arch suffix", "radeon_icd.x86_64.json", "", []string{"radeon_icd.x86_64.json"}},
+		{"exact name of the disabled file", "radeon_icd.i686.json", "", []string{"radeon_icd.i686.json"}},
+		{"exact name with another arch", "radeon_icd.x86_64.json", IcdArch32, []string{}},
+		{"base name", "radeon_icd", "", []string{"radeon_icd.i686.json", "radeon_icd.x86_64.json"}},
+		{"base name with json", "radeon_icd.json", "", []string{"radeon_icd.i686.json", "radeon_icd.x86_64.json"}},
+		{"base name filtered by arch", "radeon_icd", IcdArch32, []string{"radeon_icd.i686.json"}},
+		{"file without arch suffix", "intel_icd.json", "", []string{"intel_icd.json"}},
+		{"unknown file", "lvp_icd.x86_64.json", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, f := range dir.GetFiles(tt.file, tt.arch) {
+				got = append(got, f.Name)
+			}
+			sort.Strings(got)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetFiles(%s, %s) = %v, want %v", tt.file, tt.arch, got, tt.want)
+			}
+			for idx := range got {
+				if got[idx] != tt.want[idx] {
+					t.Errorf("GetFiles(%s, %s) = %v, want %v", tt.file, tt.arch, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	VulkanLoaderSettingsVersion = "1.0.0"
+
+	VulkanLayerControlOn   = "on"
+	VulkanLayerControlOff  = "off"
+	VulkanLayerControlAuto = "auto"
+	// The position of the layers not listed in the settings.
+	VulkanLayerControlUnordered = "unordered_layer_location"
+)
+
+var vulkanLoaderSettingsFields = []string{
+	"file_format_version", "settings_array", "settings",
+}
+var vulkanLoaderSettingsEntryFields = []string{"app_keys", "layers"}
+
+func NewVulkanLoaderSettings() *VulkanLoaderSettings {
+	return &VulkanLoaderSettings{
+		FileFormatVersion: VulkanLoaderSettingsVersion,
+		SettingsArray:     []*VulkanLoaderSettingsEntry{},
+	}
+}
+
+func NewVulkanLoaderSettingsFromJson(data []byte) (*VulkanLoaderSettings, error) {
+	ans := &VulkanLoaderSettings{}
+	if err := json.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func (s *VulkanLoaderSettings) Json() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Return the settings without app_keys used by all the
+// applications. If create is true and the settings are not
+// present, they are created with the unordered_layer_location
+// entry to keep the layers not listed available.
+func (s *VulkanLoaderSettings) GetGlobalSettings(create bool) *VulkanLoaderSettingsEntry {
+	if s.Settings != nil && len(s.Settings.AppKeys) == 0 {
+		return s.Settings
+	}
+	for _, e := range s.SettingsArray {
+		if len(e.AppKeys) == 0 {
+			return e
+		}
+	}
+
+	if !create {
+		return nil
+	}
+
+	ans := &VulkanLoaderSettingsEntry{
+		Layers: []*VulkanLoaderSettingsLayer{
+			{Control: VulkanLayerControlUnordered},
+		},
+	}
+	s.SettingsArray = append(s.SettingsArray, ans)
+
+	return ans
+}
+
+func (e *VulkanLoaderSettingsEntry) GetLayer(name string) *VulkanLoaderSettingsLayer {
+	for _, l := range e.Layers {
+		if l.Name == name && l.Control != VulkanLayerControlUnordered {
+			return l
+		}
+	}
+	return nil
+}
+
+func (e *VulkanLoaderSettingsEntry) HasUnorderedLocation() bool {
+	for _, l := range e.Layers {
+		if l.Control == VulkanLayerControlUnordered {
+			return true
+		}
+	}
+	return false
+}
+
+// Set the control of the layer. A new layer is added before the
+// unordered_layer_location entry to be loaded before the layers
+// not listed. Return false if the layer is already configured.
+func (e *VulkanLoaderSettingsEntry) SetLayer(name, path, control string, implicit bool) bool {
+	if l := e.GetLayer(name); l != nil {
+		if l.Control == control && l.Path == path &&
+			l.TreatAsImplicitManifest == implicit {
+			return false
+		}
+		l.Path = path
+		l.Control = control
+		l.TreatAsImplicitManifest = implicit
+		return true
+	}
+
+	layer := &VulkanLoaderSettingsLayer{
+		Name:                    name,
+		Path:                    path,
+		Control:                 control,
+		TreatAsImplicitManifest: implicit,
+	}
+
+	for idx, l := range e.Layers {
+		if l.Control == VulkanLayerControlUnordered {
+			e.Layers = append(e.Layers[:idx],
+				append([]*VulkanLoaderSettingsLayer{layer}, e.Layers[idx:]...)...)
+			return true
+		}
+	}
+	e.Layers = append(e.Layers, layer)
+
+	return true
+}
+
+// Remove the layer from the settings. The loader manages the
+// layer as not configured. Return false if the layer is not present.
+func (e *VulkanLoaderSettingsEntry) RemoveLayer(name string) bool {
+	for idx, l := range e.Layers {
+		if l.Name == name && l.Control != VulkanLayerControlUnordered {
+			e.Layers = append(e.Layers[:idx], e.Layers[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Move the layers with the names on top in the order defined.
+// The layers must be already present in the settings.
+func (e *VulkanLoaderSettingsEntry) SetLayersOrder(names []string) error {
+	ordered := []*VulkanLoaderSettingsLayer{}
+	moved := make(map[*VulkanLoaderSettingsLayer]bool)
+
+	for _, n := range names {
+		l := e.GetLayer(n)
+		if l == nil {
+			return fmt.Errorf("layer %s not available in the loader settings", n)
+		}
+		if moved[l] {
+			return fmt.Errorf("layer %s defined multiple times", n)
+		}
+		ordered = append(ordered, l)
+		moved[l] = true
+	}
+
+	for _, l := range e.Layers {
+		if !moved[l] {
+			ordered = append(ordered, l)
+		}
+	}
+	e.Layers = ordered
+
+	return nil
+}
+
+func (s *VulkanLoaderSettings) UnmarshalJSON(data []byte) error {
+	type vulkanLoaderSettings VulkanLoaderSettings
+	if err := json.Unmarshal(data, (*vulkanLoaderSettings)(s)); err != nil {
+		return err
+	}
+
+	extra, err := getExtraFields(data, vulkanLoaderSettingsFields)
+	if err != nil {
+		return err
+	}
+	s.Extra = extra
+
+	return nil
+}
+
+func (s VulkanLoaderSettings) MarshalJSON() ([]byte, error) {
+	type vulkanLoaderSettings VulkanLoaderSettings
+	return marshalWithExtraFields(vulkanLoaderSettings(s), s.Extra)
+}
+
+func (s VulkanLoaderSettings) MarshalYAML() (interface{}, error) {
+	type vulkanLoaderSettings VulkanLoaderSettings
+	return yamlWithExtraFields(vulkanLoaderSettings(s), s.Extra)
+}
+
+func (e *VulkanLoaderSettingsEntry) UnmarshalJSON(data []byte) error {
+	type vulkanLoaderSettingsEntry VulkanLoaderSettingsEntry
+	if err := json.Unmarshal(data, (*vulkanLoaderSettingsEntry)(e)); err != nil {
+		return err
+	}
+
+	extra, err := getExtraFields(data, vulkanLoaderSettingsEntryFields)
+	if err != nil {
+		return err
+	}
+	e.Extra = extra
+
+	return nil
+}
+
+func (e VulkanLoaderSettingsEntry) MarshalJSON() ([]byte, error) {
+	type vulkanLoaderSettingsEntry VulkanLoaderSettingsEntry
+	return marshalWithExtraFields(vulkanLoaderSettingsEntry(e), e.getExtraFields())
+}
+
+func (e VulkanLoaderSettingsEntry) MarshalYAML() (interface{}, error) {
+	type vulkanLoaderSettingsEntry VulkanLoaderSettingsEntry
+	return yamlWithExtraFields(vulkanLoaderSettingsEntry(e), e.getExtraFields())
+}
+
+// An empty list of layers disables all the layers and
+// it must be preserved.
+func (e VulkanLoaderSettingsEntry) getExtraFields() map[string]json.RawMessage {
+	if e.Layers == nil || len(e.Layers) > 0 {
+		return e.Extra
+	}
+
+	ans := map[string]json.RawMessage{"layers": json.RawMessage("[]")}
+	for k, v := range e.Extra {
+		ans[k] = v
+	}
+	return ans
+}
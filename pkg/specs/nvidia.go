@@ -4,6 +4,13 @@ See AUTHORS and LICENSE for the license details and contributors.
 */
 package specs
 
+const (
+	NVIDIAGbmLibName = "nvidia-drm_gbm.so"
+	// Some applications search for nvidia_gbm.so (for
+	// example the electron applications).
+	NVIDIAGbmLibNameShort = "nvidia_gbm.so"
+)
+
 func NewNVIDIASetup() *NVIDIASetup {
 	return &NVIDIASetup{
 		Drivers:       []*NVIDIADriver{},
@@ -11,6 +18,12 @@ func NewNVIDIASetup() *NVIDIASetup {
 	}
 }
 
+func NewNVIDIAPurgeOpts() *NVIDIAPurgeOpts {
+	return &NVIDIAPurgeOpts{
+		KeepConfd: true,
+	}
+}
+
 func (n *NVIDIASetup) SetVersion(v string) { n.VersionActive = v }
 
 func (n *NVIDIASetup) HasVersion(v string) bool {
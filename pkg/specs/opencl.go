@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"strings"
+)
+
+const (
+	OpenCLVendorSuffix = ".icd"
+)
+
+func NewOpenCLVendorFiles(dir string) *OpenCLVendorFiles {
+	return &OpenCLVendorFiles{
+		Path:  dir,
+		Files: make(map[string]*OpenCLVendorFile, 0),
+	}
+}
+
+func NewOpenCLVendorFile(n string) *OpenCLVendorFile {
+	ans := &OpenCLVendorFile{
+		Name:     n,
+		Disabled: false,
+	}
+
+	if strings.HasSuffix(n, DisabledSuffix) {
+		ans.Disabled = true
+		ans.Name = strings.TrimSuffix(n, DisabledSuffix)
+	}
+
+	return ans
+}
+
+// Return the library defined in the content of the .icd file.
+// The file contains the name or the path of the library of
+// the platform. Example: libnvidia-opencl.so.1
+func GetOpenCLVendorLibrary(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// Return the vendor files with the name or with the name
+// without the .icd extension (ex. nvidia for nvidia.icd).
+func (o *OpenCLVendorFiles) GetFiles(name string) []*OpenCLVendorFile {
+	ans := []*OpenCLVendorFile{}
+	for _, f := range o.Files {
+		if f.Name == name || strings.TrimSuffix(f.Name, OpenCLVendorSuffix) == name {
+			ans = append(ans, f)
+		}
+	}
+	return ans
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Create the link Path that points to Target.
+	OperationCreateLink = "link"
+	// Write Content to the file Path.
+	OperationWriteFile = "write"
+	// Copy the file Source to Path.
+	OperationCopyFile = "copy"
+	// Rename the file Path to Target.
+	OperationRename = "rename"
+	// Remove the file or the link Path.
+	OperationRemove = "remove"
+	// Create the directory Path and the missing parents.
+	OperationMkdir = "mkdir"
+
+	// The artifact is been created.
+	StatusCreated = "created"
+	// The artifact is been replaced or modified.
+	StatusUpdated = "updated"
+	// The artifact is already in the expected state.
+	StatusUnchanged = "unchanged"
+	// The artifact is been removed.
+	StatusRemoved = "removed"
+
+	// The suffix of the configuration files disabled.
+	DisabledSuffix = ".disabled"
+)
+
+func NewOperationsPlan() *OperationsPlan {
+	return &OperationsPlan{
+		Operations: []*FileOperation{},
+	}
+}
+
+func (p *OperationsPlan) Yaml() ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+func (p *OperationsPlan) Json() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *OperationsPlan) Add(op *FileOperation) {
+	p.Operations = append(p.Operations, op)
+}
+
+func (p *OperationsPlan) Merge(plan *OperationsPlan) {
+	p.Operations = append(p.Operations, plan.Operations...)
+	p.Diagnostics = append(p.Diagnostics, plan.Diagnostics...)
+}
+
+// Register an artifact that is not possible to manage.
+func (p *OperationsPlan) AddDiagnostic(path, kind string, err error) {
+	p.Diagnostics = append(p.Diagnostics, &Diagnostic{
+		Path:  path,
+		Kind:  kind,
+		Error: err.Error(),
+	})
+}
+
+func (p *OperationsPlan) CreateLink(path, target string) {
+	p.Add(&FileOperation{
+		Type:   OperationCreateLink,
+		Path:   path,
+		Target: target,
+	})
+}
+
+func (p *OperationsPlan) WriteFile(path, content string, mode uint32) {
+	p.Add(&FileOperation{
+		Type:    OperationWriteFile,
+		Path:    path,
+		Content: content,
+		Mode:    mode,
+	})
+}
+
+func (p *OperationsPlan) CopyFile(source, path string) {
+	p.Add(&FileOperation{
+		Type:   OperationCopyFile,
+		Path:   path,
+		Source: source,
+	})
+}
+
+func (p *OperationsPlan) Rename(path, target string) {
+	p.Add(&FileOperation{
+		Type:   OperationRename,
+		Path:   path,
+		Target: target,
+	})
+}
+
+func (p *OperationsPlan) Remove(path string) {
+	p.Add(&FileOperation{
+		Type: OperationRemove,
+		Path: path,
+	})
+}
+
+func (p *OperationsPlan) Mkdir(path string) {
+	p.Add(&FileOperation{
+		Type: OperationMkdir,
+		Path: path,
+	})
+}
+
+// Add the operation to enable the file name of the directory dir
+// renaming the .disabled file. Return false if the file is already
+// enabled.
+func (p *OperationsPlan) EnableFile(dir, name string, disabled bool) bool {
+	if !disabled {
+		return false
+	}
+	fileabs := filepath.Join(dir, name)
+	p.Rename(fileabs+DisabledSuffix, fileabs)
+	return true
+}
+
+// Add the operation to disable the file name of the directory dir
+// with the .disabled suffix. Return false if the file is already
+// disabled.
+func (p *OperationsPlan) DisableFile(dir, name string, disabled bool) bool {
+	if disabled {
+		return false
+	}
+	fileabs := filepath.Join(dir, name)
+	p.Rename(fileabs, fileabs+DisabledSuffix)
+	return true
+}
+
+// Add the operation to remove the file name of the directory dir
+// enabled or disabled.
+func (p *OperationsPlan) PurgeFile(dir, name string, disabled bool) {
+	fileabs := filepath.Join(dir, name)
+	if disabled {
+		fileabs = fileabs + DisabledSuffix
+	}
+	p.Remove(fileabs)
+}
+
+// Return the number of operations for every status.
+func (p *OperationsPlan) CountByStatus() map[string]int {
+	ans := make(map[string]int)
+	for _, op := range p.Operations {
+		ans[op.Status]++
+	}
+	return ans
+}
+
+func (o *FileOperation) IsUnchanged() bool {
+	return o.Status == StatusUnchanged
+}
+
+func (o *FileOperation) String() string {
+	switch o.Type {
+	case OperationCreateLink:
+		return fmt.Sprintf("create link %s -> %s", o.Path, o.Target)
+	case OperationWriteFile:
+		return fmt.Sprintf("write file %s (%d bytes)", o.Path, len(o.Content))
+	case OperationCopyFile:
+		return fmt.Sprintf("copy file %s to %s", o.Source, o.Path)
+	case OperationRename:
+		return fmt.Sprintf("rename %s to %s", o.Path, o.Target)
+	case OperationRemove:
+		return fmt.Sprintf("remove %s", o.Path)
+	case OperationMkdir:
+		return fmt.Sprintf("create directory %s", o.Path)
+	default:
+		return fmt.Sprintf("%s %s", o.Type, o.Path)
+	}
+}
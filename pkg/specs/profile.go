@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+func NewProfile() *Profile {
+	return &Profile{}
+}
+
+func ProfileFromYaml(data []byte) (*Profile, error) {
+	ans := NewProfile()
+	if err := yaml.UnmarshalStrict(data, ans); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+func ProfileFromFile(f string) (*Profile, error) {
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ans, err := ProfileFromYaml(data)
+	if err != nil {
+		return nil, fmt.Errorf("error on parse profile %s: %s", f, err.Error())
+	}
+	return ans, nil
+}
+
+func (p *Profile) Yaml() ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+func (p *Profile) Json() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Check that a file is not both enabled and disabled.
+func (f *ProfileFiles) Validate() error {
+	for _, e := range f.Enabled {
+		for _, d := range f.Disabled {
+			if e == d {
+				return fmt.Errorf("file %s both enabled and disabled", e)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Profile) Validate() error {
+	filesList := []*ProfileFiles{p.Egl, p.OpenCL}
+	if p.Vulkan != nil {
+		filesList = append(filesList, p.Vulkan.Icd, p.Vulkan.Layers)
+	}
+	for _, files := range filesList {
+		if files == nil {
+			continue
+		}
+		if err := files.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for module, options := range p.KernelModules {
+		if len(options) == 0 {
+			return fmt.Errorf("no options defined for kernel module %s", module)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"encoding/json"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	StateManifestFile = "state.yaml"
+)
+
+func NewStateManifest() *StateManifest {
+	return &StateManifest{
+		Artifacts: []*ManagedArtifact{},
+	}
+}
+
+func StateManifestFromYaml(data []byte) (*StateManifest, error) {
+	ans := NewStateManifest()
+	if err := yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+func (m *StateManifest) Yaml() ([]byte, error) {
+	return yaml.Marshal(m)
+}
+
+func (m *StateManifest) Json() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Write the manifest to the file f.
+func (m *StateManifest) WriteFile(f string) error {
+	data, err := m.Yaml()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, data, 0644)
+}
+
+func (m *StateManifest) GetArtifact(path string) *ManagedArtifact {
+	for idx := range m.Artifacts {
+		if m.Artifacts[idx].Path == path {
+			return m.Artifacts[idx]
+		}
+	}
+	return nil
+}
+
+// Add the artifact or replace the artifact with the same path.
+func (m *StateManifest) Record(a *ManagedArtifact) {
+	for idx := range m.Artifacts {
+		if m.Artifacts[idx].Path == a.Path {
+			m.Artifacts[idx] = a
+			return
+		}
+	}
+	m.Artifacts = append(m.Artifacts, a)
+}
+
+func (m *StateManifest) Forget(path string) {
+	artifacts := []*ManagedArtifact{}
+	for idx := range m.Artifacts {
+		if m.Artifacts[idx].Path != path {
+			artifacts = append(artifacts, m.Artifacts[idx])
+		}
+	}
+	m.Artifacts = artifacts
+}
+
+// Return the artifacts related to a driver version. An
+// empty version returns all the artifacts with a version.
+func (m *StateManifest) GetArtifactsByVersion(v string) []*ManagedArtifact {
+	ans := []*ManagedArtifact{}
+	for idx := range m.Artifacts {
+		if m.Artifacts[idx].Version == "" {
+			continue
+		}
+		if v == "" || m.Artifacts[idx].Version == v {
+			ans = append(ans, m.Artifacts[idx])
+		}
+	}
+	return ans
+}
@@ -6,19 +6,60 @@ package specs
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// The directory exists but it's not readable.
+	DiagnosticUnreadableDir = "unreadable-dir"
+	// The file or the link target is not readable.
+	DiagnosticUnreadableFile = "unreadable-file"
+	// The file content is not valid.
+	DiagnosticMalformedFile = "malformed-file"
+	// The file is parsed but it doesn't respect the specification.
+	DiagnosticInvalidManifest = "invalid-manifest"
+	// The version of the file doesn't match the expected version.
+	DiagnosticVersionMismatch = "version-mismatch"
+	// The artifact is not created because a path is missing.
+	DiagnosticSkippedArtifact = "skipped-artifact"
+)
+
+// An issue found by the backend on scan a path that
+// doesn't abort the scan.
+type ScanError struct {
+	Path string
+	Kind string
+	Err  error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+}
+
+func (e *ScanError) Unwrap() error { return e.Err }
+
 func NewSystem() *System {
 	return &System{
 		EglExtPlatformDirs: []*EglExternalPlatformFiles{},
+		EglVendorDirs:      []*EglExternalPlatformFiles{},
 		VulkanLayersDirs:   []*VulkanLayersFiles{},
 		VulkanICDDirs:      []*EglExternalPlatformFiles{},
 		GbmLibraries:       []*Library{},
+		Diagnostics:        []*Diagnostic{},
 	}
 }
 
+// Register an issue found on scan the path.
+func (s *System) AddDiagnostic(path, kind string, err error) {
+	s.Diagnostics = append(s.Diagnostics, &Diagnostic{
+		Path:  path,
+		Kind:  kind,
+		Error: err.Error(),
+	})
+}
+
 func (s *System) Yaml() ([]byte, error) {
 	return yaml.Marshal(s)
 }
@@ -77,3 +118,13 @@ func (s *System) GetVulkanLayerFile(jsonfile string) (*VulkanLayersFiles, *Vulka
 
 	return nil, nil
 }
+
+func (s *System) GetOpenCLVendorFile(vendorfile string) (*OpenCLVendorFiles, *OpenCLVendorFile) {
+	if s.OpenCLVendors != nil {
+		if f, present := s.OpenCLVendors.Files[vendorfile]; present {
+			return s.OpenCLVendors, f
+		}
+	}
+
+	return nil, nil
+}
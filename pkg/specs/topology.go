@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+const (
+	GPURoleIntegrated = "integrated"
+	GPURoleDiscrete   = "discrete"
+	GPURoleVirtual    = "virtual"
+	GPURoleUnknown    = "unknown"
+
+	PrimeModeOffload = "offload"
+	PrimeModePrimary = "primary"
+
+	DRMOutputConnected = "connected"
+)
+
+func NewGPUTopology() *GPUTopology {
+	return &GPUTopology{
+		GPUs: []*GPUNode{},
+	}
+}
+
+func (t *GPUTopology) GetGPU(busId string) *GPUNode {
+	for idx := range t.GPUs {
+		if t.GPUs[idx].BusId == busId {
+			return t.GPUs[idx]
+		}
+	}
+	return nil
+}
+
+// Return the GPUs with the selected role.
+func (t *GPUTopology) GetGPUsByRole(role string) []*GPUNode {
+	ans := []*GPUNode{}
+	for idx := range t.GPUs {
+		if t.GPUs[idx].Role == role {
+			ans = append(ans, t.GPUs[idx])
+		}
+	}
+	return ans
+}
+
+func (n *GPUNode) HasConnectedOutputs() bool {
+	for idx := range n.Outputs {
+		if n.Outputs[idx].Status == DRMOutputConnected {
+			return true
+		}
+	}
+	return false
+}
+
+// Elaborate the boot VGA, the display GPU and the suggested
+// mode from the GPUs data.
+func (t *GPUTopology) Evaluate() {
+	t.BootVGA = ""
+	t.DisplayGPU = ""
+	t.Hybrid = false
+	t.SuggestedMode = ""
+
+	for _, gpu := range t.GPUs {
+		if gpu.BootVGA {
+			t.BootVGA = gpu.BusId
+		}
+		if t.DisplayGPU == "" && gpu.HasConnectedOutputs() {
+			t.DisplayGPU = gpu.BusId
+		}
+	}
+
+	if t.DisplayGPU == "" {
+		t.DisplayGPU = t.BootVGA
+	}
+
+	// POST: the hybrid setup is with an integrated GPU
+	//       and at least a discrete GPU.
+	if len(t.GetGPUsByRole(GPURoleIntegrated)) > 0 && len(t.GetGPUsByRole(GPURoleDiscrete)) > 0 {
+		t.Hybrid = true
+
+		display := t.GetGPU(t.DisplayGPU)
+		if display != nil && display.Role == GPURoleDiscrete {
+			t.SuggestedMode = PrimeModePrimary
+		} else {
+			t.SuggestedMode = PrimeModeOffload
+		}
+	}
+}
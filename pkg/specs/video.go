@@ -0,0 +1,72 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"regexp"
+)
+
+var (
+	// Example: iHD_drv_video.so
+	regexVADriver = regexp.MustCompile(`^(.+)_drv_video\.so$`)
+	// Example: libvdpau_radeonsi.so.1.0.0
+	regexVDPAUDriver = regexp.MustCompile(`^libvdpau_(.+)\.so(\.[0-9.]+)?$`)
+)
+
+func NewVideoSetup() *VideoSetup {
+	return &VideoSetup{
+		VADrivers:    []*VideoDriver{},
+		VDPAUDrivers: []*VideoDriver{},
+	}
+}
+
+// Return the name of the VA-API driver from the name of the
+// library or empty if the file is not a VA-API driver.
+func GetVADriverName(file string) string {
+	if m := regexVADriver.FindStringSubmatch(file); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Return the name of the VDPAU driver from the name of the
+// library or empty if the file is not a VDPAU driver.
+func GetVDPAUDriverName(file string) string {
+	if m := regexVDPAUDriver.FindStringSubmatch(file); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func getVideoDriver(drivers []*VideoDriver, name string) *VideoDriver {
+	for _, d := range drivers {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func (v *VideoSetup) GetVADriver(name string) *VideoDriver {
+	return getVideoDriver(v.VADrivers, name)
+}
+
+func (v *VideoSetup) GetVDPAUDriver(name string) *VideoDriver {
+	return getVideoDriver(v.VDPAUDrivers, name)
+}
+
+// Add the VA-API driver if not already present. The first
+// directory with the driver is used by libva.
+func (v *VideoSetup) AddVADriver(name, path string) {
+	if v.GetVADriver(name) == nil {
+		v.VADrivers = append(v.VADrivers, &VideoDriver{Name: name, Path: path})
+	}
+}
+
+func (v *VideoSetup) AddVDPAUDriver(name, path string) {
+	if v.GetVDPAUDriver(name) == nil {
+		v.VDPAUDrivers = append(v.VDPAUDrivers, &VideoDriver{Name: name, Path: path})
+	}
+}
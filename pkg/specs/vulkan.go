@@ -4,7 +4,16 @@ See AUTHORS and LICENSE for the license details and contributors.
 */
 package specs
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	VulkanLayerTypeGlobal   = "GLOBAL"
+	VulkanLayerTypeInstance = "INSTANCE"
+)
 
 func NewVulkanLayersFiles(dir string) *VulkanLayersFiles {
 	return &VulkanLayersFiles{
@@ -17,7 +26,6 @@ func NewVulkanLayersFile(n string) *VulkanLayersFile {
 	ans := &VulkanLayersFile{
 		Name:     n,
 		Disabled: false,
-		Content:  make(map[string]interface{}, 0),
 	}
 
 	if strings.HasSuffix(n, ".disabled") {
@@ -27,3 +35,89 @@ func NewVulkanLayersFile(n string) *VulkanLayersFile {
 
 	return ans
 }
+
+func NewVulkanLayerManifest(data []byte) (*VulkanLayerManifest, error) {
+	ans := &VulkanLayerManifest{}
+	if err := json.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// Return the layers defined in the manifest with both
+// the single and the multiple layers format.
+func (m *VulkanLayerManifest) GetLayers() []*VulkanLayer {
+	ans := []*VulkanLayer{}
+	if m.Layer != nil {
+		ans = append(ans, m.Layer)
+	}
+	return append(ans, m.Layers...)
+}
+
+// Validate the manifest. The implicit layers must define
+// the disable_environment.
+func (m *VulkanLayerManifest) Validate(implicit bool) error {
+	if m.FileFormatVersion == "" {
+		return fmt.Errorf("missing file_format_version")
+	}
+
+	if m.Layer != nil && len(m.Layers) > 0 {
+		return fmt.Errorf("both layer and layers defined")
+	}
+
+	layers := m.GetLayers()
+	if len(layers) == 0 {
+		return fmt.Errorf("no layers defined")
+	}
+
+	for _, l := range layers {
+		if err := l.Validate(implicit); err != nil {
+			if l.Name != "" {
+				return fmt.Errorf("layer %s: %s", l.Name, err.Error())
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *VulkanLayer) IsMetaLayer() bool {
+	return len(l.ComponentLayers) > 0
+}
+
+func (l *VulkanLayer) Validate(implicit bool) error {
+	if l.Name == "" {
+		return fmt.Errorf("missing layer name")
+	}
+
+	switch l.Type {
+	case VulkanLayerTypeGlobal, VulkanLayerTypeInstance:
+	case "":
+		return fmt.Errorf("missing type")
+	default:
+		return fmt.Errorf("invalid type %s", l.Type)
+	}
+
+	if l.ApiVersion == "" {
+		return fmt.Errorf("missing api_version")
+	}
+	if l.ImplementationVersion == "" {
+		return fmt.Errorf("missing implementation_version")
+	}
+
+	if l.IsMetaLayer() {
+		if l.LibraryPath != "" {
+			return fmt.Errorf("library_path defined on meta layer")
+		}
+	} else if l.LibraryPath == "" {
+		return fmt.Errorf("missing library_path")
+	}
+
+	if implicit && len(l.DisableEnvironment) == 0 {
+		return fmt.Errorf("missing disable_environment on implicit layer")
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package video
+
+import (
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const (
+	VADriverEnv    = "LIBVA_DRIVER_NAME"
+	VDPAUDriverEnv = "VDPAU_DRIVER"
+	// The backend of nvidia-vaapi-driver.
+	NvdBackendEnv = "NVD_BACKEND"
+
+	NvdBackendDirect = "direct"
+	NvdBackendEgl    = "egl"
+
+	// The VA-API driver of nvidia-vaapi-driver.
+	NvidiaVADriver = "nvidia"
+)
+
+type Drivers struct {
+	VADriver    string `json:"va_driver,omitempty" yaml:"va_driver,omitempty"`
+	VDPAUDriver string `json:"vdpau_driver,omitempty" yaml:"vdpau_driver,omitempty"`
+	NvdBackend  string `json:"nvd_backend,omitempty" yaml:"nvd_backend,omitempty"`
+}
+
+// The VA-API and VDPAU drivers of the kernel drivers in
+// order of preference.
+var videoDrivers = map[string][2][]string{
+	"nvidia":     {{NvidiaVADriver}, {"nvidia"}},
+	"amdgpu":     {{"radeonsi"}, {"radeonsi"}},
+	"radeon":     {{"r600", "radeonsi"}, {"r600", "radeonsi"}},
+	"i915":       {{"iHD", "i965"}, {"va_gl"}},
+	"xe":         {{"iHD"}, {"va_gl"}},
+	"nouveau":    {{"nouveau"}, {"nouveau"}},
+	"virtio_gpu": {{"virtio_gpu"}, {}},
+}
+
+func ValidNvdBackend(backend string) bool {
+	switch backend {
+	case NvdBackendDirect, NvdBackendEgl:
+		return true
+	default:
+		return false
+	}
+}
+
+// Return the VA-API and VDPAU drivers candidates of the kernel driver.
+func GetCandidates(kernelDriver string) ([]string, []string) {
+	if c, ok := videoDrivers[kernelDriver]; ok {
+		return c[0], c[1]
+	}
+	return []string{}, []string{}
+}
+
+func selectDriver(candidates []string, installed func(string) bool) string {
+	for _, c := range candidates {
+		if installed == nil || installed(c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// Return the VA-API and VDPAU drivers to use with the kernel
+// driver. If the setup is available only the drivers installed
+// are selected, otherwise the preferred drivers are returned.
+func GetDrivers(kernelDriver string, setup *specs.VideoSetup) *Drivers {
+	va, vdpau := GetCandidates(kernelDriver)
+
+	var vaInstalled, vdpauInstalled func(string) bool
+	if setup != nil {
+		vaInstalled = func(n string) bool { return setup.GetVADriver(n) != nil }
+		vdpauInstalled = func(n string) bool { return setup.GetVDPAUDriver(n) != nil }
+	}
+
+	ans := &Drivers{
+		VADriver:    selectDriver(va, vaInstalled),
+		VDPAUDriver: selectDriver(vdpau, vdpauInstalled),
+	}
+	if ans.VADriver == NvidiaVADriver {
+		// POST: the egl backend is broken with the driver 525+.
+		ans.NvdBackend = NvdBackendDirect
+	}
+
+	return ans
+}
+
+// Return the environment variables of the drivers.
+func (d *Drivers) GetEnvironment() map[string]string {
+	ans := make(map[string]string)
+	if d.VADriver != "" {
+		ans[VADriverEnv] = d.VADriver
+	}
+	if d.VDPAUDriver != "" {
+		ans[VDPAUDriverEnv] = d.VDPAUDriver
+	}
+	if d.VADriver == NvidiaVADriver && d.NvdBackend != "" {
+		ans[NvdBackendEnv] = d.NvdBackend
+	}
+	return ans
+}
@@ -0,0 +1,515 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package vulkan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	IcdDir           = "icd.d"
+	ImplicitLayerDir = "implicit_layer.d"
+	ExplicitLayerDir = "explicit_layer.d"
+
+	LayerTypeImplicit = "implicit"
+	LayerTypeExplicit = "explicit"
+
+	SourceSearchPath = "search path"
+
+	DefaultXdgConfigDirs = "/etc/xdg"
+	DefaultXdgDataDirs   = "/usr/local/share:/usr/share"
+	SysconfDir           = "/etc"
+)
+
+type ResolvedIcd struct {
+	Path        string `json:"path" yaml:"path"`
+	LibraryPath string `json:"library_path,omitempty" yaml:"library_path,omitempty"`
+	ApiVersion  string `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	// The variable or the search path that defines the file.
+	Source  string `json:"source" yaml:"source"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	// The reason because the ICD is not loaded.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+type ResolvedLayer struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Path        string `json:"path" yaml:"path"`
+	LibraryPath string `json:"library_path,omitempty" yaml:"library_path,omitempty"`
+	ApiVersion  string `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	Source      string `json:"source" yaml:"source"`
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	// The reason of the state of the layer.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+type Resolution struct {
+	// The variables of the environment used by the loader.
+	Env               map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	IcdDirs           []string          `json:"icd_dirs" yaml:"icd_dirs"`
+	ImplicitLayerDirs []string          `json:"implicit_layer_dirs" yaml:"implicit_layer_dirs"`
+	ExplicitLayerDirs []string          `json:"explicit_layer_dirs" yaml:"explicit_layer_dirs"`
+	Icds              []*ResolvedIcd    `json:"icds" yaml:"icds"`
+	Layers            []*ResolvedLayer  `json:"layers" yaml:"layers"`
+	// The loader settings file used.
+	LoaderSettings      string `json:"loader_settings,omitempty" yaml:"loader_settings,omitempty"`
+	LoaderSettingsError string `json:"loader_settings_error,omitempty" yaml:"loader_settings_error,omitempty"`
+}
+
+// Simulate the discovery of the ICD and layers manifests
+// of the Vulkan loader on Linux for the environment.
+type Resolver struct {
+	RootDir string
+	Env     map[string]string
+
+	used map[string]string
+}
+
+func NewResolver(rootDir string, env map[string]string) *Resolver {
+	if env == nil {
+		env = make(map[string]string)
+	}
+	return &Resolver{
+		RootDir: rootDir,
+		Env:     env,
+		used:    make(map[string]string),
+	}
+}
+
+// Return the environment from the list of variables
+// in the KEY=VALUE format.
+func GetEnv(environ []string) map[string]string {
+	ans := make(map[string]string)
+	for _, e := range environ {
+		words := strings.SplitN(e, "=", 2)
+		if len(words) == 2 {
+			ans[words[0]] = words[1]
+		}
+	}
+	return ans
+}
+
+func (r *Resolver) getenv(name string) string {
+	v, ok := r.Env[name]
+	if ok {
+		r.used[name] = v
+	}
+	return v
+}
+
+func (r *Resolver) lookupenv(name string) bool {
+	v, ok := r.Env[name]
+	if ok {
+		r.used[name] = v
+	}
+	return ok
+}
+
+// Return the directories of the manifests of the kind
+// in the order used by the loader:
+//
+//	$XDG_CONFIG_HOME or $HOME/.config
+//	$XDG_CONFIG_DIRS or /etc/xdg
+//	/etc
+//	$XDG_DATA_HOME or $HOME/.local/share
+//	$XDG_DATA_DIRS or /usr/local/share:/usr/share
+func (r *Resolver) GetSearchDirs(kind string) []string {
+	ans := []string{}
+	home := r.getenv("HOME")
+
+	configHome := r.getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	configDirs := r.getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = DefaultXdgConfigDirs
+	}
+	dataHome := r.getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local/share")
+	}
+	dataDirs := r.getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = DefaultXdgDataDirs
+	}
+
+	bases := []string{}
+	if configHome != "" {
+		bases = append(bases, configHome)
+	}
+	bases = append(bases, splitList(configDirs, ":")...)
+	bases = append(bases, SysconfDir)
+	if dataHome != "" {
+		bases = append(bases, dataHome)
+	}
+	bases = append(bases, splitList(dataDirs, ":")...)
+
+	for _, b := range bases {
+		ans = append(ans, filepath.Join(b, "vulkan", kind))
+	}
+
+	return ans
+}
+
+func splitList(s, sep string) []string {
+	ans := []string{}
+	for _, e := range strings.Split(s, sep) {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			ans = append(ans, e)
+		}
+	}
+	return ans
+}
+
+// Return the JSON files of the path. If the path is a directory
+// the JSON files are returned sorted by name.
+func (r *Resolver) getManifests(p string) []string {
+	ans := []string{}
+
+	resolved, err := rootfs.ResolvePath(r.RootDir, p)
+	if err != nil {
+		return ans
+	}
+
+	finfo, err := os.Stat(resolved)
+	if err != nil {
+		return ans
+	}
+	if !finfo.IsDir() {
+		return append(ans, p)
+	}
+
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return ans
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			ans = append(ans, filepath.Join(p, e.Name()))
+		}
+	}
+	sort.Strings(ans)
+
+	return ans
+}
+
+// Match the name like the loader: only a leading and/or a trailing
+// asterisk are supported for a suffix, prefix or substring match,
+// everything else is compared literally. The loader ignores the case.
+func matchPatterns(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if matchPattern(strings.ToLower(p), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(p, name string) bool {
+	prefix := strings.HasPrefix(p, "*")
+	suffix := len(p) > 1 && strings.HasSuffix(p, "*")
+	s := strings.TrimSuffix(strings.TrimPrefix(p, "*"), "*")
+
+	switch {
+	case prefix && suffix:
+		return strings.Contains(name, s)
+	case prefix:
+		return strings.HasSuffix(name, s)
+	case suffix:
+		return strings.HasPrefix(name, s)
+	default:
+		return name == s
+	}
+}
+
+func (r *Resolver) Resolve() *Resolution {
+	ans := &Resolution{
+		IcdDirs:           []string{},
+		ImplicitLayerDirs: []string{},
+		ExplicitLayerDirs: []string{},
+		Icds:              []*ResolvedIcd{},
+		Layers:            []*ResolvedLayer{},
+	}
+
+	r.resolveIcds(ans)
+	r.resolveLayers(ans)
+	ans.Env = r.used
+
+	return ans
+}
+
+type manifestSource struct {
+	Path   string
+	Source string
+}
+
+// Return the manifests from the override and the additional
+// variables or from the search directories.
+func (r *Resolver) getSources(overrideVars []string, addVar string,
+	searchDirs []string, dirs *[]string) []*manifestSource {
+	ans := []*manifestSource{}
+
+	for _, v := range overrideVars {
+		if r.getenv(v) == "" {
+			continue
+		}
+		for _, p := range splitList(r.getenv(v), ":") {
+			*dirs = append(*dirs, p)
+			for _, m := range r.getManifests(p) {
+				ans = append(ans, &manifestSource{Path: m, Source: v})
+			}
+		}
+		// POST: the search paths are ignored.
+		return ans
+	}
+
+	if addVar != "" && r.getenv(addVar) != "" {
+		for _, p := range splitList(r.getenv(addVar), ":") {
+			*dirs = append(*dirs, p)
+			for _, m := range r.getManifests(p) {
+				ans = append(ans, &manifestSource{Path: m, Source: addVar})
+			}
+		}
+	}
+
+	for _, d := range searchDirs {
+		*dirs = append(*dirs, d)
+		for _, m := range r.getManifests(d) {
+			ans = append(ans, &manifestSource{Path: m, Source: SourceSearchPath})
+		}
+	}
+
+	return ans
+}
+
+func (r *Resolver) resolveIcds(res *Resolution) {
+	sources := r.getSources(
+		[]string{"VK_DRIVER_FILES", "VK_ICD_FILENAMES"}, "VK_ADD_DRIVER_FILES",
+		r.GetSearchDirs(IcdDir), &res.IcdDirs)
+
+	selectPatterns := splitList(r.getenv("VK_LOADER_DRIVERS_SELECT"), ",")
+	disablePatterns := splitList(r.getenv("VK_LOADER_DRIVERS_DISABLE"), ",")
+
+	loaded := make(map[string]bool)
+	for _, s := range sources {
+		icd := &ResolvedIcd{
+			Path:    s.Path,
+			Source:  s.Source,
+			Enabled: true,
+		}
+		res.Icds = append(res.Icds, icd)
+
+		if _, present := loaded[s.Path]; present {
+			icd.Enabled = false
+			icd.Reason = "duplicated manifest"
+			continue
+		}
+		loaded[s.Path] = true
+
+		content, err := rootfs.ReadFile(r.RootDir, s.Path)
+		if err == nil {
+			var data *specs.ICDJson
+			data, err = specs.NewICDJson(content)
+			if err == nil {
+				icd.LibraryPath = data.ICD.LibraryPath
+				icd.ApiVersion = data.ICD.ApiVersion
+			}
+		}
+		if err != nil {
+			icd.Enabled = false
+			icd.Reason = fmt.Sprintf("invalid manifest: %s", err.Error())
+			continue
+		}
+
+		name := filepath.Base(s.Path)
+		if len(selectPatterns) > 0 && !matchPatterns(selectPatterns, name) {
+			icd.Enabled = false
+			icd.Reason = "not selected by VK_LOADER_DRIVERS_SELECT"
+		} else if matchPatterns(disablePatterns, name) ||
+			matchPatterns(disablePatterns, "~all~") {
+			icd.Enabled = false
+			icd.Reason = "disabled by VK_LOADER_DRIVERS_DISABLE"
+		}
+	}
+}
+
+func (r *Resolver) readLayers(p string) ([]*specs.VulkanLayer, error) {
+	content, err := rootfs.ReadFile(r.RootDir, p)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := specs.NewVulkanLayerManifest(content)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := manifest.GetLayers()
+	if len(ans) == 0 {
+		return nil, fmt.Errorf("no layers defined")
+	}
+
+	return ans, nil
+}
+
+func (r *Resolver) resolveLayers(res *Resolution) {
+	implicitSources := r.getSources(
+		[]string{"VK_IMPLICIT_LAYER_PATH"}, "VK_ADD_IMPLICIT_LAYER_PATH",
+		r.GetSearchDirs(ImplicitLayerDir), &res.ImplicitLayerDirs)
+	explicitSources := r.getSources(
+		[]string{"VK_LAYER_PATH"}, "VK_ADD_LAYER_PATH",
+		r.GetSearchDirs(ExplicitLayerDir), &res.ExplicitLayerDirs)
+
+	instanceLayers := splitList(r.getenv("VK_INSTANCE_LAYERS"), ":")
+	enablePatterns := splitList(r.getenv("VK_LOADER_LAYERS_ENABLE"), ",")
+	disablePatterns := splitList(r.getenv("VK_LOADER_LAYERS_DISABLE"), ",")
+	settings := r.readLoaderSettings(res)
+
+	names := make(map[string]bool)
+	for _, t := range []struct {
+		Type    string
+		Sources []*manifestSource
+	}{
+		{LayerTypeImplicit, implicitSources},
+		{LayerTypeExplicit, explicitSources},
+	} {
+		for _, s := range t.Sources {
+			layers, err := r.readLayers(s.Path)
+			if err != nil {
+				res.Layers = append(res.Layers, &ResolvedLayer{
+					Type:   t.Type,
+					Path:   s.Path,
+					Source: s.Source,
+					Reason: fmt.Sprintf("invalid manifest: %s", err.Error()),
+				})
+				continue
+			}
+
+			for _, l := range layers {
+				layer := &ResolvedLayer{
+					Name:        l.Name,
+					Type:        t.Type,
+					Path:        s.Path,
+					LibraryPath: l.LibraryPath,
+					ApiVersion:  l.ApiVersion,
+					Source:      s.Source,
+				}
+				res.Layers = append(res.Layers, layer)
+
+				// POST: the first layer found with a name is used.
+				if _, present := names[l.Name]; present {
+					layer.Reason = "shadowed by a previous layer with the same name"
+					continue
+				}
+				names[l.Name] = true
+
+				if t.Type == LayerTypeImplicit {
+					r.evaluateImplicitLayer(layer, l)
+				} else {
+					layer.Reason = "not enabled by VK_INSTANCE_LAYERS"
+					for _, il := range instanceLayers {
+						if il == l.Name {
+							layer.Enabled = true
+							layer.Reason = "enabled by VK_INSTANCE_LAYERS"
+							break
+						}
+					}
+				}
+
+				// POST: the layers forced by the loader settings
+				//       ignore the environment filters.
+				switch getLayerControl(settings, l.Name) {
+				case specs.VulkanLayerControlOn:
+					layer.Enabled = true
+					layer.Reason = "forced on by the loader settings"
+					continue
+				case specs.VulkanLayerControlOff:
+					layer.Enabled = false
+					layer.Reason = "forced off by the loader settings"
+					if settings.GetLayer(l.Name) == nil {
+						layer.Reason = "not listed in the loader settings"
+					}
+					continue
+				}
+
+				if layer.Enabled && (matchPatterns(disablePatterns, l.Name) ||
+					matchPatterns(disablePatterns, "~all~") ||
+					matchPatterns(disablePatterns, "~"+t.Type+"~")) {
+					layer.Enabled = false
+					layer.Reason = "disabled by VK_LOADER_LAYERS_DISABLE"
+				}
+
+				// POST: the enable filter has priority on the disable filter.
+				if !layer.Enabled && matchPatterns(enablePatterns, l.Name) {
+					layer.Enabled = true
+					layer.Reason = "enabled by VK_LOADER_LAYERS_ENABLE"
+				}
+			}
+		}
+	}
+}
+
+// The implicit layers are enabled unless a variable of the
+// disable_environment is defined. With enable_environment the
+// variable must have the value defined.
+func (r *Resolver) evaluateImplicitLayer(layer *ResolvedLayer, l *specs.VulkanLayer) {
+	for k := range l.DisableEnvironment {
+		if r.lookupenv(k) {
+			layer.Reason = fmt.Sprintf("disabled by %s", k)
+			return
+		}
+	}
+
+	for k, v := range l.EnableEnvironment {
+		if r.getenv(k) != v {
+			layer.Reason = fmt.Sprintf("requires %s=%s", k, v)
+			return
+		}
+	}
+
+	layer.Enabled = true
+}
+
+func (r *Resolution) Yaml() ([]byte, error) {
+	return yaml.Marshal(r)
+}
+
+func (r *Resolution) Json() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *Resolution) GetEnabledIcds() []*ResolvedIcd {
+	ans := []*ResolvedIcd{}
+	for _, icd := range r.Icds {
+		if icd.Enabled {
+			ans = append(ans, icd)
+		}
+	}
+	return ans
+}
+
+func (r *Resolution) GetEnabledLayers() []*ResolvedLayer {
+	ans := []*ResolvedLayer{}
+	for _, l := range r.Layers {
+		if l.Enabled {
+			ans = append(ans, l)
+		}
+	}
+	return ans
+}
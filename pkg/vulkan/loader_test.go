@@ -0,0 +1,40 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package vulkan
+
+import (
+	"testing"
+)
+
+func TestMatchPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"nvidia_icd.json", "nvidia_icd.json", true},
+		{"nvidia_icd.json", "nvidia_icd.x86_64.json", false},
+		{"NVIDIA_icd.json", "nvidia_icd.json", true},
+		{"nvidia*", "nvidia_icd.json", true},
+		{"nvidia*", "radeon_icd.json", false},
+		{"*_icd.json", "radeon_icd.x86_64.json", false},
+		{"*x86_64.json", "radeon_icd.x86_64.json", true},
+		{"*radeon*", "radeon_icd.x86_64.json", true},
+		{"*intel*", "radeon_icd.x86_64.json", false},
+		{"*", "VK_LAYER_MESA_device_select", true},
+		{"~all~", "~all~", true},
+		{"~all~", "VK_LAYER_MESA_device_select", false},
+		// POST: the glob syntax is compared literally.
+		{"VK_LAYER_?ESA_device_select", "VK_LAYER_MESA_device_select", false},
+		{"[rl]*", "radeon_icd.json", false},
+		{"VK_LAYER_*_device_select", "VK_LAYER_MESA_device_select", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPatterns([]string{tt.pattern}, tt.name); got != tt.want {
+			t.Errorf("%q on %q = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
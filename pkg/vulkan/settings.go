@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package vulkan
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/rootfs"
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+
+	"github.com/macaroni-os/macaronictl/pkg/utils"
+)
+
+const (
+	LoaderSettingsDir      = "loader_settings.d"
+	LoaderSettingsFileName = "vk_loader_settings.json"
+
+	// Write the loader settings under /etc/vulkan/loader_settings.d
+	SettingsTargetSystem = "system"
+	// Write the loader settings under ~/.local/share/vulkan/loader_settings.d
+	SettingsTargetUser = "user"
+)
+
+func ValidSettingsTarget(target string) bool {
+	return target == SettingsTargetSystem || target == SettingsTargetUser
+}
+
+// Return the per-user loader settings file from $XDG_DATA_HOME
+// or $HOME/.local/share.
+func GetUserLoaderSettingsFile(env map[string]string) (string, error) {
+	dataHome := env["XDG_DATA_HOME"]
+	if dataHome == "" {
+		if env["HOME"] == "" {
+			return "", fmt.Errorf("home directory not available")
+		}
+		dataHome = filepath.Join(env["HOME"], ".local/share")
+	}
+	return filepath.Join(dataHome, "vulkan", LoaderSettingsDir, LoaderSettingsFileName), nil
+}
+
+// Return the loader settings files in the order used by the
+// loader. Only the first file available is used.
+func (r *Resolver) GetLoaderSettingsFiles() []string {
+	ans := []string{}
+
+	dataHome := r.getenv("XDG_DATA_HOME")
+	if dataHome == "" && r.getenv("HOME") != "" {
+		dataHome = filepath.Join(r.getenv("HOME"), ".local/share")
+	}
+	if dataHome != "" {
+		ans = append(ans,
+			filepath.Join(dataHome, "vulkan", LoaderSettingsDir, LoaderSettingsFileName))
+	}
+
+	return append(ans,
+		filepath.Join(SysconfDir, "vulkan", LoaderSettingsDir, LoaderSettingsFileName))
+}
+
+// Return the global settings of the first loader settings
+// file available or nil.
+func (r *Resolver) readLoaderSettings(res *Resolution) *specs.VulkanLoaderSettingsEntry {
+	for _, f := range r.GetLoaderSettingsFiles() {
+		resolved, err := rootfs.ResolvePath(r.RootDir, f)
+		if err != nil || !utils.Exists(resolved) {
+			continue
+		}
+
+		res.LoaderSettings = f
+		content, err := rootfs.ReadFile(r.RootDir, f)
+		if err != nil {
+			res.LoaderSettingsError = err.Error()
+			return nil
+		}
+		settings, err := specs.NewVulkanLoaderSettingsFromJson(content)
+		if err != nil {
+			res.LoaderSettingsError = err.Error()
+			return nil
+		}
+
+		return settings.GetGlobalSettings(false)
+	}
+
+	return nil
+}
+
+// Return the control of the layer defined by the loader settings.
+// Without the unordered_layer_location entry the layers not listed
+// are not loaded.
+func getLayerControl(settings *specs.VulkanLoaderSettingsEntry, name string) string {
+	if settings == nil {
+		return specs.VulkanLayerControlAuto
+	}
+	if l := settings.GetLayer(name); l != nil {
+		return l.Control
+	}
+	if !settings.HasUnorderedLocation() {
+		return specs.VulkanLayerControlOff
+	}
+	return specs.VulkanLayerControlAuto
+}
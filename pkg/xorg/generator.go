@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package xorg
+
+import (
+	"fmt"
+
+	"github.com/macaroni-os/gpu-configurator/pkg/specs"
+)
+
+const (
+	// The NVIDIA GPU renders and drives the outputs. The outputs
+	// of the integrated GPU are used through PRIME output.
+	ModeNvidiaPrimary = "nvidia-primary"
+	// The integrated GPU drives the outputs and the discrete
+	// GPU is used for render offload.
+	ModeOffload = "offload"
+	// Like offload but the outputs connected to the discrete
+	// GPU are available to the integrated GPU.
+	ModeReversePrime = "reverse-prime"
+
+	igpuIdentifier = "iGPU"
+	dgpuIdentifier = "dGPU"
+)
+
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeNvidiaPrimary, ModeOffload, ModeReversePrime:
+		return true
+	default:
+		return false
+	}
+}
+
+// Return the Xorg driver of the GPU.
+func GetXorgDriver(gpu *specs.GPUNode) string {
+	switch gpu.KernelDriver {
+	case "nvidia":
+		return "nvidia"
+	case "amdgpu":
+		return "amdgpu"
+	case "radeon":
+		return "radeon"
+	default:
+		return "modesetting"
+	}
+}
+
+func newDeviceSection(identifier string, gpu *specs.GPUNode) (*Section, error) {
+	busId, err := BusIdToXorg(gpu.BusId)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSection("Device").
+		Add("Identifier", identifier).
+		Add("Driver", GetXorgDriver(gpu)).
+		Add("BusID", busId), nil
+}
+
+// Generate the Xorg configuration of the integrated GPU igpu
+// and the discrete GPU dgpu for the selected mode.
+func Generate(mode string, igpu, dgpu *specs.GPUNode) (*Config, error) {
+	if igpu == nil || dgpu == nil {
+		return nil, fmt.Errorf("the mode %s requires an integrated and a discrete GPU", mode)
+	}
+
+	ans := NewConfig()
+	ans.Comments = append(ans.Comments,
+		fmt.Sprintf("mode: %s", mode),
+		fmt.Sprintf("%s: %s [%s] %s", igpuIdentifier, igpu.Name, igpu.Id, igpu.BusId),
+		fmt.Sprintf("%s: %s [%s] %s", dgpuIdentifier, dgpu.Name, dgpu.Id, dgpu.BusId),
+	)
+
+	idev, err := newDeviceSection(igpuIdentifier, igpu)
+	if err != nil {
+		return nil, err
+	}
+	ddev, err := newDeviceSection(dgpuIdentifier, dgpu)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := NewSection("ServerLayout").Add("Identifier", "layout")
+
+	switch mode {
+	case ModeNvidiaPrimary:
+		if dgpu.KernelDriver != "nvidia" {
+			return nil, fmt.Errorf("the mode %s requires the nvidia driver on %s",
+				mode, dgpu.BusId)
+		}
+		ans.Comments = append(ans.Comments,
+			"Run `xrandr --setprovideroutputsource modesetting NVIDIA-0 && xrandr --auto`",
+			"at the start of the X session to use the outputs of the iGPU.")
+
+		layout.Add("Screen", "0", dgpuIdentifier).
+			Add("Inactive", igpuIdentifier)
+		ans.AddSection(layout)
+
+		ddev.AddOption("AllowEmptyInitialConfiguration")
+		ans.AddSection(ddev)
+		ans.AddSection(NewSection("Screen").
+			Add("Identifier", dgpuIdentifier).
+			Add("Device", dgpuIdentifier).
+			AddOption("AllowEmptyInitialConfiguration"))
+		ans.AddSection(idev)
+
+		ans.AddSection(NewSection("OutputClass").
+			Add("Identifier", "nvidia").
+			Add("MatchDriver", "nvidia-drm").
+			Add("Driver", "nvidia").
+			AddOption("AllowEmptyInitialConfiguration").
+			AddOption("PrimaryGPU", "yes"))
+
+	case ModeOffload, ModeReversePrime:
+		layout.Add("Screen", "0", igpuIdentifier).
+			Add("Inactive", dgpuIdentifier)
+		if dgpu.KernelDriver == "nvidia" {
+			layout.AddOption("AllowNVIDIAGPUScreens")
+		}
+		ans.AddSection(layout)
+
+		ans.AddSection(idev)
+		ans.AddSection(NewSection("Screen").
+			Add("Identifier", igpuIdentifier).
+			Add("Device", igpuIdentifier))
+
+		if mode == ModeReversePrime && dgpu.KernelDriver == "nvidia" {
+			ddev.AddOption("AllowPRIMEDisplayOffloadSink", "true")
+		}
+		ans.AddSection(ddev)
+
+	default:
+		return nil, fmt.Errorf("invalid mode %s", mode)
+	}
+
+	return ans, nil
+}
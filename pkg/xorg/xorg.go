@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package xorg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultXorgConfdDir = "/etc/X11/xorg.conf.d"
+	DefaultXorgConfFile = "20-gpu-configurator.conf"
+)
+
+// A section of the Xorg configuration.
+type Section struct {
+	Name    string
+	Entries []string
+}
+
+type Config struct {
+	Comments []string
+	Sections []*Section
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Comments: []string{},
+		Sections: []*Section{},
+	}
+}
+
+func NewSection(name string) *Section {
+	return &Section{
+		Name:    name,
+		Entries: []string{},
+	}
+}
+
+func (c *Config) AddSection(s *Section) *Section {
+	c.Sections = append(c.Sections, s)
+	return s
+}
+
+// Add an entry with the key and the values quoted
+// when they are strings.
+func (s *Section) Add(key string, values ...string) *Section {
+	entry := key
+	for _, v := range values {
+		if _, err := strconv.Atoi(v); err == nil {
+			entry += " " + v
+		} else {
+			entry += fmt.Sprintf(" \"%s\"", v)
+		}
+	}
+	s.Entries = append(s.Entries, entry)
+	return s
+}
+
+func (s *Section) AddOption(option string, values ...string) *Section {
+	return s.Add("Option", append([]string{option}, values...)...)
+}
+
+func (c *Config) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("# autogenerated file by gpu-configurator\n")
+	for _, comment := range c.Comments {
+		sb.WriteString("# " + comment + "\n")
+	}
+
+	for _, s := range c.Sections {
+		sb.WriteString("\n")
+		sb.WriteString(fmt.Sprintf("Section \"%s\"\n", s.Name))
+		for _, entry := range s.Entries {
+			sb.WriteString("    " + entry + "\n")
+		}
+		sb.WriteString("EndSection\n")
+	}
+
+	return sb.String()
+}
+
+// Convert the PCI bus id in hex format (ex. 0000:0a:00.1)
+// to the decimal format used by Xorg (ex. PCI:10:0:1).
+func BusIdToXorg(busId string) (string, error) {
+	domain := int64(0)
+	words := strings.Split(busId, ":")
+	switch len(words) {
+	case 2:
+	case 3:
+		d, err := strconv.ParseInt(words[0], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid domain on bus id %s", busId)
+		}
+		domain = d
+		words = words[1:]
+	default:
+		return "", fmt.Errorf("invalid bus id %s", busId)
+	}
+
+	devfn := strings.Split(words[1], ".")
+	if len(devfn) != 2 {
+		return "", fmt.Errorf("invalid bus id %s", busId)
+	}
+
+	values := []int64{}
+	for _, v := range []string{words[0], devfn[0], devfn[1]} {
+		n, err := strconv.ParseInt(v, 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid bus id %s", busId)
+		}
+		values = append(values, n)
+	}
+
+	if domain > 0 {
+		return fmt.Sprintf("PCI:%d@%d:%d:%d",
+			values[0], domain, values[1], values[2]), nil
+	}
+
+	return fmt.Sprintf("PCI:%d:%d:%d", values[0], values[1], values[2]), nil
+}